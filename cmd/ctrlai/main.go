@@ -26,6 +26,8 @@
 //	ctrlai agents       - List/inspect agents
 //	ctrlai kill         - Kill an agent (emergency stop)
 //	ctrlai revive       - Revive a killed agent
+//	ctrlai approvals    - List tool calls waiting for approval
+//	ctrlai approve/deny - Resolve a pending approval
 //	ctrlai rules        - Manage guardrail rules
 //	ctrlai audit        - Query/verify the audit log
 //	ctrlai config       - View/edit proxy configuration
@@ -33,6 +35,8 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -42,6 +46,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -50,9 +55,11 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/ctrlai/ctrlai/internal/agent"
+	"github.com/ctrlai/ctrlai/internal/approval"
 	"github.com/ctrlai/ctrlai/internal/audit"
 	"github.com/ctrlai/ctrlai/internal/config"
 	"github.com/ctrlai/ctrlai/internal/dashboard"
+	"github.com/ctrlai/ctrlai/internal/delegate"
 	"github.com/ctrlai/ctrlai/internal/engine"
 	"github.com/ctrlai/ctrlai/internal/proxy"
 )
@@ -129,6 +136,9 @@ func init() {
 	rootCmd.AddCommand(agentsCmd)
 	rootCmd.AddCommand(killCmd)
 	rootCmd.AddCommand(reviveCmd)
+	rootCmd.AddCommand(approvalsCmd)
+	rootCmd.AddCommand(approveCmd)
+	rootCmd.AddCommand(denyCmd)
 	rootCmd.AddCommand(rulesCmd)
 	rootCmd.AddCommand(auditCmd)
 	rootCmd.AddCommand(configCmd)
@@ -219,6 +229,12 @@ func runStart(cmd *cobra.Command, args []string) error {
 	fmt.Printf("[ctrlai] Loaded %d rules (%d builtin + %d custom)\n",
 		ruleEngine.TotalRules(), ruleEngine.BuiltinCount(), ruleEngine.CustomCount())
 
+	// Per-rule hit counters survive restarts via rule_stats.yaml. A
+	// corrupt file only costs the history, so it isn't fatal.
+	if err := ruleEngine.LoadStats(filepath.Join(configDir, "rule_stats.yaml")); err != nil {
+		fmt.Fprintf(os.Stderr, "[ctrlai] Warning: %v\n", err)
+	}
+
 	// --- Step 3: Initialize the audit log ---
 	// The audit log is a hash-chained append-only JSONL file with a SQLite
 	// index for fast queries. Each entry's hash = SHA-256(prev_hash + seq +
@@ -252,6 +268,18 @@ func runStart(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to initialize kill switch: %w", err)
 	}
 
+	// Lint warnings on rules reload name agents missing from the registry.
+	ruleEngine.SetAgentSource(registryAgentIDs(registry))
+
+	// --- Step 4b: Initialize the approval queue ---
+	// Tool calls matched by an "ask" rule are held here until an operator
+	// approves or denies them (dashboard, /api/approvals, or
+	// `ctrlai approve|deny`). In-memory only — a restart drops pending asks.
+	approvals := approval.NewQueue(
+		time.Duration(cfg.Approvals.TimeoutMs)*time.Millisecond,
+		cfg.Approvals.Default,
+	)
+
 	// --- Step 5: Create the proxy server with tuned HTTP transport ---
 	// The upstream HTTP client is tuned for low-latency LLM proxying:
 	//   - Connection pooling: reuse TCP connections to upstream LLM providers
@@ -305,9 +333,17 @@ func runStart(cmd *cobra.Command, args []string) error {
 			KillSwitch: killSwitch,
 			Engine:     ruleEngine,
 			RulesPath:  filepath.Join(configDir, "rules.yaml"),
+			Approvals:  approvals,
 		})
 	}
 
+	// Keys that may sign X-Ctrl-Rules bundles. A bad key is a startup
+	// error rather than a silently ignored header.
+	bundleKeys, err := ruleBundleKeys(cfg)
+	if err != nil {
+		return fmt.Errorf("invalid runtimeRules key: %w", err)
+	}
+
 	// Wire the proxy with an optional audit event broadcast callback
 	// so the dashboard WebSocket live feed receives events in real time.
 	proxyOpts := proxy.Options{
@@ -317,6 +353,18 @@ func runStart(cmd *cobra.Command, args []string) error {
 		Registry:       registry,
 		KillSwitch:     killSwitch,
 		UpstreamClient: upstreamClient,
+		Approvals:      approvals,
+		RuleBundleKeys: bundleKeys,
+	}
+	// Tool calls matched by a "delegate" rule are decided by the policy
+	// service at delegate.url. Without one they are blocked.
+	if cfg.Delegate.URL != "" {
+		proxyOpts.Delegate = delegate.NewClient(
+			cfg.Delegate.URL,
+			time.Duration(cfg.Delegate.TimeoutMs)*time.Millisecond,
+			cfg.Delegate.OnError,
+			time.Duration(cfg.Delegate.CacheTtlMs)*time.Millisecond,
+		)
 	}
 	if dash != nil {
 		proxyOpts.OnAuditEvent = func(e audit.Entry) {
@@ -411,14 +459,19 @@ func runStart(cmd *cobra.Command, args []string) error {
 	// This is what makes `ctrlai kill` take effect instantly without
 	// restarting the proxy — the CLI writes killed.yaml, the watcher
 	// picks up the change, and the kill switch state updates in memory.
+	// Host lists referenced by url_host_in_list are watched too; a change
+	// reloads the rules, which re-reads only the lists that changed.
+	reloadRules := func() {
+		if reloadErr := ruleEngine.Reload(filepath.Join(configDir, "rules.yaml")); reloadErr != nil {
+			fmt.Fprintf(os.Stderr, "[ctrlai] Warning: failed to reload rules: %v\n", reloadErr)
+		} else {
+			fmt.Println("[ctrlai] Rules reloaded")
+		}
+	}
 	watcher, err := config.NewWatcher(configDir, config.WatchTargets{
-		OnRulesChange: func() {
-			if reloadErr := ruleEngine.Reload(filepath.Join(configDir, "rules.yaml")); reloadErr != nil {
-				fmt.Fprintf(os.Stderr, "[ctrlai] Warning: failed to reload rules: %v\n", reloadErr)
-			} else {
-				fmt.Println("[ctrlai] Rules reloaded")
-			}
-		},
+		OnRulesChange: reloadRules,
+		OnListChange:  reloadRules,
+		ListFiles:     ruleEngine.ListFiles,
 		OnKillSwitchChange: func() {
 			if reloadErr := killSwitch.Reload(); reloadErr != nil {
 				fmt.Fprintf(os.Stderr, "[ctrlai] Warning: failed to reload kill switch: %v\n", reloadErr)
@@ -440,6 +493,24 @@ func runStart(cmd *cobra.Command, args []string) error {
 	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer stop()
 
+	// Flush rule hit counters periodically so a crash loses at most one
+	// interval. SaveStats is a no-op when nothing has matched since.
+	statsDone := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(ruleStatsInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-statsDone:
+				return
+			case <-ticker.C:
+				if saveErr := ruleEngine.SaveStats(); saveErr != nil {
+					fmt.Fprintf(os.Stderr, "[ctrlai] Warning: failed to save rule stats: %v\n", saveErr)
+				}
+			}
+		}
+	}()
+
 	// Start listening in a goroutine so we can block on the signal context.
 	errCh := make(chan error, 1)
 	go func() {
@@ -479,15 +550,23 @@ func runStart(cmd *cobra.Command, args []string) error {
 	// Log proxy shutdown in the audit chain.
 	auditLog.LogLifecycle("proxy_stop", nil)
 
-	// Persist agent stats to disk before exiting.
+	// Persist agent and rule stats to disk before exiting.
 	if saveErr := registry.Save(); saveErr != nil {
 		fmt.Fprintf(os.Stderr, "[ctrlai] Warning: failed to save agent registry: %v\n", saveErr)
 	}
+	close(statsDone)
+	if saveErr := ruleEngine.SaveStats(); saveErr != nil {
+		fmt.Fprintf(os.Stderr, "[ctrlai] Warning: failed to save rule stats: %v\n", saveErr)
+	}
 
 	fmt.Println("[ctrlai] Stopped")
 	return nil
 }
 
+// ruleStatsInterval is how often the running proxy writes rule hit
+// counters to rule_stats.yaml.
+const ruleStatsInterval = time.Minute
+
 // spawnDaemon re-executes the ctrlai binary as a detached background process.
 // The parent process prints the child PID and exits immediately.
 //
@@ -813,6 +892,7 @@ func runAgents(cmd *cobra.Command, args []string) error {
 		fmt.Printf("  Requests:   %d\n", a.Stats.TotalRequests)
 		fmt.Printf("  Tool calls: %d\n", a.Stats.TotalToolCalls)
 		fmt.Printf("  Blocked:    %d\n", a.Stats.BlockedToolCalls)
+		printAgentRateLimits(agentID)
 		return nil
 	}
 
@@ -835,6 +915,60 @@ func runAgents(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// printAgentRateLimits shows the agent's rate limit usage from the live
+// proxy. Counters only exist in the running proxy, so nothing is printed
+// when it isn't reachable. Buckets not keyed by agent are shared by all
+// agents and are shown too.
+func printAgentRateLimits(agentID string) {
+	addr, err := liveProxyAddr()
+	if err != nil {
+		return
+	}
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(addr + "/api/status")
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	var status struct {
+		RateLimits []engine.RateLimitStatus `json:"rate_limits"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&status) != nil {
+		return
+	}
+
+	var lines []string
+	for _, rl := range status.RateLimits {
+		if rl.Agent != "" && rl.Agent != agentID {
+			continue
+		}
+		line := fmt.Sprintf("    %-25s %d/%d per %s", rl.Rule, rl.Count, rl.Max, rl.Per)
+		if rl.Tool != "" {
+			line += " tool=" + rl.Tool
+		}
+		if rl.Scope == engine.ScopeRuntime {
+			line += " (runtime)"
+		}
+		if rl.Agent == "" {
+			line += " (shared)"
+		}
+		if rl.Limited {
+			line += " LIMITED"
+		}
+		lines = append(lines, line)
+	}
+
+	if len(lines) == 0 {
+		fmt.Println("  Rate limits: none active")
+		return
+	}
+	fmt.Println("  Rate limits:")
+	for _, l := range lines {
+		fmt.Println(l)
+	}
+}
+
 // ============================================================================
 // ctrlai kill — Kill an agent (emergency stop)
 // ============================================================================
@@ -952,6 +1086,147 @@ func runRevive(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
+// ============================================================================
+// ctrlai approvals / approve / deny — Resolve "ask" rules
+// ============================================================================
+
+// approvalsCmd lists tool calls currently held by "ask" rules.
+// Pending approvals live in the running proxy's memory, so this (like
+// approve/deny) talks to the live proxy over HTTP.
+var approvalsCmd = &cobra.Command{
+	Use:   "approvals",
+	Short: "List tool calls waiting for approval",
+	Long: `List tool calls matched by an "ask" rule that are waiting for an operator
+decision. The LLM response is held by the proxy until the call is approved,
+denied, or the approvals.timeoutMs from config.yaml expires.
+
+Queries the live proxy — requires the dashboard API to be enabled.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runApprovals(cmd, args)
+	},
+}
+
+// approveBy is the approver name recorded in the audit log (--by flag).
+var approveBy string
+
+// approveCmd approves a pending tool call; the held response is released
+// unchanged.
+var approveCmd = &cobra.Command{
+	Use:   "approve <approval-id>",
+	Short: "Approve a tool call held by an ask rule",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return resolveApproval(args[0], "approve")
+	},
+}
+
+// denyCmd denies a pending tool call; it is stripped from the held response
+// like a blocked call.
+var denyCmd = &cobra.Command{
+	Use:   "deny <approval-id>",
+	Short: "Deny a tool call held by an ask rule",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return resolveApproval(args[0], "deny")
+	},
+}
+
+func init() {
+	defaultApprover := os.Getenv("USER")
+	if defaultApprover == "" {
+		defaultApprover = "user"
+	}
+	approveCmd.Flags().StringVar(&approveBy, "by", defaultApprover, "Approver name recorded in the audit log")
+	denyCmd.Flags().StringVar(&approveBy, "by", defaultApprover, "Approver name recorded in the audit log")
+}
+
+// runApprovals fetches and prints pending approvals from the live proxy.
+func runApprovals(cmd *cobra.Command, args []string) error {
+	addr, err := liveProxyAddr()
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(addr + "/api/approvals")
+	if err != nil {
+		return fmt.Errorf("proxy is not running at %s", addr)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read approvals: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("listing approvals failed: %s", strings.TrimSpace(string(body)))
+	}
+
+	var pending []approval.Request
+	if err := json.Unmarshal(body, &pending); err != nil {
+		return fmt.Errorf("failed to parse approvals: %w", err)
+	}
+
+	if len(pending) == 0 {
+		fmt.Println("[ctrlai] No tool calls waiting for approval")
+		return nil
+	}
+
+	fmt.Printf("%-18s %-15s %-12s %-25s %s\n", "ID", "AGENT", "TOOL", "RULE", "EXPIRES")
+	fmt.Printf("%-18s %-15s %-12s %-25s %s\n", "--", "-----", "----", "----", "-------")
+	for _, p := range pending {
+		fmt.Printf("%-18s %-15s %-12s %-25s %s\n",
+			p.ID, p.Agent, p.Tool, p.Rule, p.ExpiresAt.Local().Format(time.RFC3339))
+	}
+	return nil
+}
+
+// resolveApproval posts an approve/deny decision to the live proxy.
+func resolveApproval(id, decision string) error {
+	addr, err := liveProxyAddr()
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(map[string]string{
+		"id":       id,
+		"decision": decision,
+		"approver": approveBy,
+	})
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Post(addr+"/api/approvals", "application/json", strings.NewReader(string(payload)))
+	if err != nil {
+		return fmt.Errorf("proxy is not running at %s", addr)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("failed to %s %s: %s", decision, id, strings.TrimSpace(string(body)))
+	}
+
+	if decision == "approve" {
+		fmt.Printf("[ctrlai] Approved %s (by %s)\n", id, approveBy)
+	} else {
+		fmt.Printf("[ctrlai] Denied %s (by %s)\n", id, approveBy)
+	}
+	return nil
+}
+
+// liveProxyAddr returns the base URL of the running proxy from config.yaml.
+func liveProxyAddr() (string, error) {
+	cfg, err := config.Load(filepath.Join(configDir, "config.yaml"))
+	if err != nil {
+		return "", fmt.Errorf("failed to load config: %w", err)
+	}
+	return fmt.Sprintf("http://%s:%d", cfg.Server.Host, cfg.Server.Port), nil
+}
+
 // ============================================================================
 // ctrlai rules — Manage guardrail rules
 // ============================================================================
@@ -975,6 +1250,37 @@ func init() {
 	rulesCmd.AddCommand(rulesAddCmd)
 	rulesCmd.AddCommand(rulesRemoveCmd)
 	rulesCmd.AddCommand(rulesTestCmd)
+	rulesCmd.AddCommand(rulesReplayCmd)
+	rulesCmd.AddCommand(rulesLintCmd)
+	rulesCmd.AddCommand(rulesExplainCmd)
+	rulesCmd.AddCommand(rulesSignCmd)
+
+	rulesTestCmd.Flags().StringVar(&rulesTestAgent, "agent", "", "Evaluate as this agent ID")
+	rulesTestCmd.Flags().StringVar(&rulesTestSuite, "suite", "", "Run every case in a test suite YAML file")
+	rulesTestCmd.Flags().StringVar(&rulesTestRules, "rules", "", "Rules file to test instead of ~/.ctrlai/rules.yaml")
+
+	rulesReplayCmd.Flags().StringVar(&rulesReplayRules, "rules", "", "Candidate rules file to replay against (required)")
+	rulesReplayCmd.Flags().StringVar(&rulesReplaySince, "since", "7d", "Replay tool calls since duration (e.g., 24h, 7d)")
+	rulesReplayCmd.Flags().StringVar(&rulesReplayAgent, "agent", "", "Only replay this agent's tool calls")
+	rulesReplayCmd.Flags().StringVar(&rulesReplayFormat, "format", "table", "Output format: table or json")
+	rulesReplayCmd.Flags().IntVar(&rulesReplayLimit, "limit", 20, "Maximum changed calls to list in table output (0 = all)")
+	_ = rulesReplayCmd.MarkFlagRequired("rules")
+
+	rulesLintCmd.Flags().StringVar(&rulesLintRules, "rules", "", "Rules file to lint instead of ~/.ctrlai/rules.yaml")
+
+	rulesExplainCmd.Flags().StringVar(&rulesExplainAgent, "agent", "", "Evaluate as this agent ID")
+	rulesExplainCmd.Flags().StringVar(&rulesExplainRules, "rules", "", "Rules file to explain instead of ~/.ctrlai/rules.yaml")
+	rulesExplainCmd.Flags().StringVar(&rulesExplainFormat, "format", "text", "Output format: text or json")
+	rulesExplainCmd.Flags().BoolVar(&rulesExplainAll, "all", false, "Also show rules after the deciding one")
+
+	rulesSignCmd.Flags().StringVar(&rulesSignKey, "key", "", "Signing key file (required)")
+	rulesSignCmd.Flags().StringVar(&rulesSignKeyID, "key-id", "", "Key ID as configured in runtimeRules.keys (required)")
+	rulesSignCmd.Flags().StringVar(&rulesSignAlg, "alg", engine.BundleEd25519, "Signing algorithm: ed25519 or hmac-sha256")
+	rulesSignCmd.Flags().StringVar(&rulesSignOrg, "org", "", "Org the bundle is for")
+	rulesSignCmd.Flags().StringVar(&rulesSignID, "id", "", "Bundle ID recorded in the audit log (default: derived from time and content)")
+	rulesSignCmd.Flags().DurationVar(&rulesSignTTL, "ttl", 24*time.Hour, "How long the bundle is valid")
+	_ = rulesSignCmd.MarkFlagRequired("key")
+	_ = rulesSignCmd.MarkFlagRequired("key-id")
 }
 
 // rulesListCmd shows all active rules (both built-in and custom).
@@ -987,20 +1293,46 @@ var rulesListCmd = &cobra.Command{
 			return fmt.Errorf("failed to load rules: %w", err)
 		}
 
+		// Hit counters are written by the running proxy.
+		if err := ruleEngine.LoadStats(filepath.Join(configDir, "rule_stats.yaml")); err != nil {
+			fmt.Fprintf(os.Stderr, "[ctrlai] Warning: %v\n", err)
+		}
+
 		rules := ruleEngine.ListRules()
 		if len(rules) == 0 {
 			fmt.Println("No rules configured.")
 			return nil
 		}
 
-		fmt.Printf("%-25s %-10s %-10s %s\n", "NAME", "TYPE", "ACTION", "DESCRIPTION")
-		fmt.Printf("%-25s %-10s %-10s %s\n", "----", "----", "------", "-----------")
+		if ruleEngine.Monitor() {
+			fmt.Println("[ctrlai] Global monitor mode is ON — no rule is enforced")
+		}
+		fmt.Printf("%-25s %-10s %-10s %-10s %-8s %-8s %-17s %s\n", "NAME", "TYPE", "ACTION", "MODE", "MATCHES", "BLOCKS", "LAST MATCH", "DESCRIPTION")
+		fmt.Printf("%-25s %-10s %-10s %-10s %-8s %-8s %-17s %s\n", "----", "----", "------", "----", "-------", "------", "----------", "-----------")
 		for _, r := range rules {
 			ruleType := "custom"
-			if r.Builtin {
+			switch {
+			case r.Default:
+				ruleType = "default"
+			case r.Runtime:
+				ruleType = "runtime"
+			case r.Builtin:
 				ruleType = "builtin"
 			}
-			fmt.Printf("%-25s %-10s %-10s %s\n", r.Name, ruleType, r.Action, r.Message)
+			name := r.Name
+			action, mode := r.Action, r.Mode
+			if r.Runtime {
+				action, mode = "-", "-" // Only known to the request that sent it.
+			}
+			matches, blocks, last := "-", "-", "-"
+			if !r.Default {
+				matches, blocks = fmt.Sprint(r.Stats.Matches), fmt.Sprint(r.Stats.Blocks)
+			}
+			if !r.Stats.LastMatch.IsZero() {
+				last = r.Stats.LastMatch.Local().Format("2006-01-02 15:04")
+			}
+			fmt.Printf("%-25s %-10s %-10s %-10s %-8s %-8s %-17s %s\n",
+				name, ruleType, action, mode, matches, blocks, last, r.Message)
 		}
 		return nil
 	},
@@ -1065,38 +1397,547 @@ var rulesRemoveCmd = &cobra.Command{
 	},
 }
 
+// rulesTestAgent is the agent ID the test call is evaluated as (--agent).
+var rulesTestAgent string
+
+// rulesTestSuite is a test suite YAML file to run instead of a single call (--suite).
+var rulesTestSuite string
+
+// rulesTestRules overrides the rules file under test (--rules).
+var rulesTestRules string
+
 // rulesTestCmd tests a tool call JSON against the current rule set.
 // This lets users verify rules without running a live agent.
 // Example: ctrlai rules test '{"name":"exec","arguments":{"command":"cat /etc/passwd"}}'
 var rulesTestCmd = &cobra.Command{
-	Use:   "test <json>",
+	Use:   "test [json]",
 	Short: "Test a tool call against rules",
 	Long: `Test a tool call JSON string against the current rule set to see
 whether it would be blocked or allowed. Useful for verifying rules.
 
+Use --agent to evaluate as a specific agent, so agent-specific rules and
+its default_action override apply.
+
+Use --suite to run a whole file of cases instead. Each case names the
+agent, tool, and arguments, and the decision (and optionally the rule)
+it should get. A pass/fail table is printed and the command exits
+non-zero if any case fails, so suites can run in CI.
+
+Use --rules to test a candidate rules file without touching
+~/.ctrlai/rules.yaml.
+
 Example:
-  ctrlai rules test '{"name":"exec","arguments":{"command":"cat /etc/passwd"}}'`,
-	Args: cobra.ExactArgs(1),
+  ctrlai rules test '{"name":"exec","arguments":{"command":"cat /etc/passwd"}}'
+  ctrlai rules test --agent prod-bot '{"name":"exec","arguments":{"command":"ls"}}'
+  ctrlai rules test --suite rules_test.yaml --rules ./rules.yaml`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if rulesTestSuite != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		ruleEngine, err := engine.New(filepath.Join(configDir, "rules.yaml"))
+		rulesPath := rulesTestRules
+		if rulesPath == "" {
+			rulesPath = filepath.Join(configDir, "rules.yaml")
+		}
+		ruleEngine, err := engine.New(rulesPath)
 		if err != nil {
 			return fmt.Errorf("failed to load rules: %w", err)
 		}
 
-		decision, err := ruleEngine.TestJSON(args[0])
+		if rulesTestSuite != "" {
+			// A failing case is a test result, not a usage mistake.
+			cmd.SilenceUsage = true
+			return runRulesTestSuite(ruleEngine, rulesTestSuite)
+		}
+
+		decision, err := ruleEngine.TestJSONForAgent(args[0], rulesTestAgent)
 		if err != nil {
 			return fmt.Errorf("failed to test tool call: %w", err)
 		}
 
-		if decision.Action == "block" {
+		for _, wb := range decision.WouldBlock {
+			fmt.Printf("[ctrlai] WOULD BLOCK by monitor rule %q: %s\n", wb.Rule, wb.Message)
+		}
+
+		switch {
+		case decision.Default:
+			verb := map[string]string{"block": "BLOCKED", "ask": "ASK", "allow": "ALLOWED"}[decision.Action]
+			fmt.Printf("[ctrlai] %s by default_action: %s\n", verb, decision.Message)
+		case decision.Action == "block":
 			fmt.Printf("[ctrlai] BLOCKED by rule %q: %s\n", decision.Rule, decision.Message)
-		} else {
+		case decision.Action == "ask":
+			fmt.Printf("[ctrlai] ASK by rule %q (held for operator approval): %s\n", decision.Rule, decision.Message)
+		case decision.Action == "delegate":
+			fmt.Printf("[ctrlai] DELEGATE by rule %q (decided by the policy service): %s\n", decision.Rule, decision.Message)
+		case decision.Action == "redact":
+			redacted, _ := json.Marshal(decision.Args)
+			fmt.Printf("[ctrlai] REDACTED by rule %q: %s\n", decision.Rule, decision.Message)
+			fmt.Printf("[ctrlai] Arguments sent: %s\n", redacted)
+		case decision.Action == "rewrite":
+			rewritten, _ := json.Marshal(decision.Args)
+			fmt.Printf("[ctrlai] REWRITTEN by rule %q: %s\n", decision.Rule, decision.Message)
+			fmt.Printf("[ctrlai] Arguments sent: %s\n", rewritten)
+		case decision.Rule != "":
+			fmt.Printf("[ctrlai] ALLOWED by rule %q\n", decision.Rule)
+		default:
 			fmt.Println("[ctrlai] ALLOWED (no rule matched)")
 		}
 		return nil
 	},
 }
 
+// runRulesTestSuite runs a test suite file and prints a pass/fail table.
+// Returns an error if any case fails, so the process exits non-zero.
+func runRulesTestSuite(ruleEngine *engine.Engine, path string) error {
+	suite, err := engine.LoadTestSuite(path)
+	if err != nil {
+		return err
+	}
+
+	results := ruleEngine.RunSuite(suite)
+
+	fmt.Printf("%-6s %-35s %-12s %-12s %-40s %s\n", "RESULT", "CASE", "AGENT", "TOOL", "EXPECTED", "GOT")
+	fmt.Printf("%-6s %-35s %-12s %-12s %-40s %s\n", "------", "----", "-----", "----", "--------", "---")
+	failed := 0
+	for _, r := range results {
+		status := "PASS"
+		if !r.Pass {
+			status = "FAIL"
+			failed++
+		}
+		expected := r.Case.Decision
+		if r.Case.Rule != "" {
+			expected += " (" + r.Case.Rule + ")"
+		}
+		got := r.Decision
+		if r.Rule != "" {
+			got += " (" + r.Rule + ")"
+		}
+		agent := r.Case.Agent
+		if agent == "" {
+			agent = "-"
+		}
+		fmt.Printf("%-6s %-35s %-12s %-12s %-40s %s\n", status, r.Case.Name, agent, r.Case.Tool, expected, got)
+	}
+
+	fmt.Printf("\n[ctrlai] %d passed, %d failed\n", len(results)-failed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d test cases failed", failed, len(results))
+	}
+	return nil
+}
+
+// Explain flags.
+var (
+	rulesExplainAgent  string
+	rulesExplainRules  string
+	rulesExplainFormat string
+	rulesExplainAll    bool
+)
+
+// rulesExplainCmd prints the rule-by-rule evaluation trace for a tool call.
+var rulesExplainCmd = &cobra.Command{
+	Use:   "explain <json>",
+	Short: "Show why a tool call is blocked or allowed, rule by rule",
+	Long: `Evaluate a tool call JSON string and print every rule checked, in
+order, with the outcome of each match field — e.g. that the tool matched
+but the path glob **/.env failed against /app/x.
+
+Rules after the one that decided the call are hidden unless --all is set.
+Nothing is recorded: rate limits are checked without counting the call.
+
+Examples:
+  ctrlai rules explain --agent main '{"name":"read","arguments":{"path":"/app/.env"}}'
+  ctrlai rules explain --format json '{"name":"exec","arguments":{"command":"ls"}}'`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if rulesExplainFormat != "text" && rulesExplainFormat != "json" {
+			return fmt.Errorf("unknown format %q (want text or json)", rulesExplainFormat)
+		}
+		rulesPath := rulesExplainRules
+		if rulesPath == "" {
+			rulesPath = filepath.Join(configDir, "rules.yaml")
+		}
+		ruleEngine, err := engine.New(rulesPath)
+		if err != nil {
+			return fmt.Errorf("failed to load rules: %w", err)
+		}
+
+		ex, err := ruleEngine.ExplainJSON(args[0], rulesExplainAgent)
+		if err != nil {
+			return fmt.Errorf("failed to explain tool call: %w", err)
+		}
+
+		if rulesExplainFormat == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(ex)
+		}
+		printExplanation(ex, rulesExplainAll)
+		return nil
+	},
+}
+
+// printExplanation prints an evaluation trace as an indented list.
+func printExplanation(ex engine.Explanation, all bool) {
+	agent := ex.Agent
+	if agent == "" {
+		agent = "(none)"
+	}
+	fmt.Printf("[ctrlai] Tool %q, agent %s\n\n", ex.Tool, agent)
+
+	hidden := 0
+	for i, rt := range ex.Rules {
+		if rt.Outcome == engine.OutcomeNotReached && !all {
+			hidden++
+			continue
+		}
+		name := rt.Rule
+		kind := "custom"
+		if rt.Builtin {
+			kind = "builtin"
+		}
+		fmt.Printf("%3d. %-30s %-8s %-6s -> %s\n", i+1, name, kind, rt.Action, strings.ToUpper(rt.Outcome))
+		printFieldTraces(rt.Fields, "       ")
+	}
+	if hidden > 0 {
+		fmt.Printf("\n     ... %d more rule(s) not reached (use --all to show)\n", hidden)
+	}
+
+	fmt.Println()
+	msg := ""
+	if ex.Message != "" {
+		msg = ": " + ex.Message
+	}
+	switch {
+	case ex.Default:
+		fmt.Printf("[ctrlai] %s by default_action%s\n", strings.ToUpper(ex.Decision), msg)
+	case ex.Rule != "":
+		fmt.Printf("[ctrlai] %s by rule %q%s\n", strings.ToUpper(ex.Decision), ex.Rule, msg)
+	default:
+		fmt.Println("[ctrlai] ALLOW (no rule matched)")
+	}
+}
+
+// printFieldTraces prints match field outcomes, recursing into nested
+// all/any/not blocks.
+func printFieldTraces(fields []engine.FieldTrace, indent string) {
+	for _, f := range fields {
+		mark := "ok  "
+		if !f.Matched {
+			mark = "FAIL"
+		}
+		switch {
+		case len(f.Children) > 0:
+			fmt.Printf("%s%s %s:\n", indent, mark, f.Field)
+			for i, child := range f.Children {
+				fmt.Printf("%s     [%d]\n", indent, i)
+				printFieldTraces(child, indent+"         ")
+			}
+		case f.Value == "":
+			fmt.Printf("%s%s %s %s (no value)\n", indent, mark, f.Field, f.Pattern)
+		default:
+			fmt.Printf("%s%s %s %s against %q\n", indent, mark, f.Field, f.Pattern, f.Value)
+		}
+	}
+}
+
+// rulesLintRules overrides the rules file to lint (--rules).
+var rulesLintRules string
+
+// rulesLintCmd checks the rule set for rules that can never fire or that
+// match far more than intended.
+var rulesLintCmd = &cobra.Command{
+	Use:   "lint",
+	Short: "Check rules for shadowed, duplicate and overly broad rules",
+	Long: `Check the rule set for common mistakes:
+
+  shadowed        an earlier rule (often a built-in) matches every call
+                  this rule does, so it can never fire
+  duplicate_name  two rules share a name
+  empty_match     a rule with no conditions matches every tool call
+  unknown_agent   a rule or agents: entry names an agent that is not in
+                  the agent registry (agents.yaml)
+  empty_regex     a regex matches the empty string, so it matches anything
+
+The same checks run when the proxy reloads rules.yaml (logged as
+warnings) and are served at /api/rules/lint. Exits non-zero if any issue
+is found.
+
+Examples:
+  ctrlai rules lint
+  ctrlai rules lint --rules candidate.yaml`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rulesPath := rulesLintRules
+		if rulesPath == "" {
+			rulesPath = filepath.Join(configDir, "rules.yaml")
+		}
+		ruleEngine, err := engine.New(rulesPath)
+		if err != nil {
+			return fmt.Errorf("failed to load rules: %w", err)
+		}
+
+		registry, err := agent.NewRegistry(filepath.Join(configDir, "agents.yaml"))
+		if err != nil {
+			return fmt.Errorf("failed to load agent registry: %w", err)
+		}
+		ruleEngine.SetAgentSource(registryAgentIDs(registry))
+
+		issues := ruleEngine.Lint()
+		if len(issues) == 0 {
+			fmt.Println("[ctrlai] No issues found")
+			return nil
+		}
+
+		fmt.Printf("%-30s %-15s %s\n", "RULE", "CHECK", "MESSAGE")
+		fmt.Printf("%-30s %-15s %s\n", "----", "-----", "-------")
+		for _, issue := range issues {
+			name := issue.Rule
+			if issue.Builtin {
+				name += " (builtin)"
+			}
+			fmt.Printf("%-30s %-15s %s\n", name, issue.Check, issue.Message)
+		}
+
+		// Issues are findings, not a usage mistake.
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%d rule issue(s) found", len(issues))
+	},
+}
+
+// Sign flags.
+var (
+	rulesSignKey   string
+	rulesSignKeyID string
+	rulesSignAlg   string
+	rulesSignOrg   string
+	rulesSignID    string
+	rulesSignTTL   time.Duration
+)
+
+// rulesSignCmd signs a rules file into an X-Ctrl-Rules header value.
+var rulesSignCmd = &cobra.Command{
+	Use:   "sign <rules.yaml>",
+	Short: "Sign a rules file for the X-Ctrl-Rules header",
+	Long: `Sign a rules file (rules and builtin toggles, as in rules.yaml) into a
+bundle for the X-Ctrl-Rules request header. The proxy only uses bundles
+signed by a key listed under runtimeRules.keys in config.yaml and not yet
+expired; anything else is ignored and rules.yaml applies.
+
+The header value is printed to stdout. The key file holds an Ed25519
+private key (PKCS #8 PEM, or base64 of the 32-byte seed) or, with
+--alg hmac-sha256, the base64 secret from config.yaml.
+
+Examples:
+  openssl genpkey -algorithm ed25519 -out rules-signing.pem
+  openssl pkey -in rules-signing.pem -pubout    # publicKey for config.yaml
+  ctrlai rules sign org-rules.yaml --key rules-signing.pem --key-id acme-2026 --org acme
+  curl -H "X-Ctrl-Rules: $(ctrlai rules sign org-rules.yaml --key k.pem --key-id acme-2026 --ttl 1h)" ...`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rulesYAML, err := os.ReadFile(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to read rules: %w", err)
+		}
+		key, err := os.ReadFile(rulesSignKey)
+		if err != nil {
+			return fmt.Errorf("failed to read key: %w", err)
+		}
+		if rulesSignTTL <= 0 {
+			return fmt.Errorf("--ttl must be positive")
+		}
+
+		now := time.Now()
+		id := rulesSignID
+		if id == "" {
+			sum := sha256.Sum256(rulesYAML)
+			id = now.UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(sum[:4])
+		}
+		bundle := engine.RuleBundle{
+			ID:        id,
+			Org:       rulesSignOrg,
+			KeyID:     rulesSignKeyID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(rulesSignTTL).Unix(),
+			Rules:     string(rulesYAML),
+		}
+		value, err := engine.SignRuleBundle(bundle, rulesSignAlg, key)
+		if err != nil {
+			return fmt.Errorf("failed to sign rules: %w", err)
+		}
+
+		fmt.Fprintf(os.Stderr, "[ctrlai] Bundle %s signed with key %s, expires %s\n",
+			id, rulesSignKeyID, now.Add(rulesSignTTL).UTC().Format(time.RFC3339))
+		fmt.Println(value)
+		return nil
+	},
+}
+
+// ruleBundleKeys builds the X-Ctrl-Rules verification keys from
+// config.yaml's runtimeRules.keys.
+func ruleBundleKeys(cfg *config.Config) ([]engine.BundleKey, error) {
+	var keys []engine.BundleKey
+	for _, k := range cfg.RuntimeRules.Keys {
+		material := k.PublicKey
+		if k.Algorithm == engine.BundleHMACSHA256 {
+			material = k.Secret
+		}
+		key, err := engine.NewBundleKey(k.ID, k.Org, k.Algorithm, material)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// registryAgentIDs returns a function listing the registry's agent IDs,
+// for engine.SetAgentSource.
+func registryAgentIDs(registry *agent.Registry) func() []string {
+	return func() []string {
+		agents := registry.List()
+		ids := make([]string, 0, len(agents))
+		for _, a := range agents {
+			ids = append(ids, a.ID)
+		}
+		return ids
+	}
+}
+
+// Replay flags.
+var (
+	rulesReplayRules  string
+	rulesReplaySince  string
+	rulesReplayAgent  string
+	rulesReplayFormat string
+	rulesReplayLimit  int
+)
+
+// rulesReplayCmd re-evaluates historical tool calls from the audit log
+// against a candidate rules file and reports what would change.
+var rulesReplayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Replay audit history against a candidate rules file",
+	Long: `Replay recorded tool calls from the audit log against a candidate
+rules file and report every call whose decision or matching rule would
+change, grouped by rule and agent. Use it to check the blast radius of a
+rules.yaml change before applying it.
+
+Nothing is modified: the candidate is loaded into a separate engine and
+the audit log is only read. Calls decided by X-Ctrl-Rules header rules
+can't be reproduced and show up as changes.
+
+Examples:
+  ctrlai rules replay --rules candidate.yaml --since 7d
+  ctrlai rules replay --rules candidate.yaml --agent main --format json > replay.json`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if rulesReplayFormat != "table" && rulesReplayFormat != "json" {
+			return fmt.Errorf("unknown format %q (want table or json)", rulesReplayFormat)
+		}
+
+		candidate, err := engine.New(rulesReplayRules)
+		if err != nil {
+			return fmt.Errorf("failed to load candidate rules: %w", err)
+		}
+
+		auditLog, err := audit.New(filepath.Join(configDir, "audit"))
+		if err != nil {
+			return fmt.Errorf("failed to open audit log: %w", err)
+		}
+		defer auditLog.Close()
+
+		entries, err := auditLog.Query(audit.QueryParams{
+			Agent: rulesReplayAgent,
+			Type:  "tool_call",
+			Since: rulesReplaySince,
+		})
+		if err != nil {
+			return fmt.Errorf("audit query failed: %w", err)
+		}
+
+		// Replay in the order the calls were made, so rate limits play out.
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+
+		calls := make([]engine.ReplayCall, 0, len(entries))
+		for _, e := range entries {
+			// Monitor matches are logged as extra entries next to the real decision.
+			if e.Decision == "would_block" {
+				continue
+			}
+			calls = append(calls, engine.ReplayCall{
+				Seq: e.Seq, Timestamp: e.Timestamp, Agent: e.Agent, Tool: e.Tool,
+				Arguments: e.Arguments, Decision: e.Decision, Rule: e.Rule,
+			})
+		}
+
+		report := candidate.Replay(calls)
+
+		if rulesReplayFormat == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(report)
+		}
+		printReplayReport(report, rulesReplayLimit)
+		return nil
+	},
+}
+
+// printReplayReport prints a replay report as tables: changes grouped by
+// rule and agent, then the individual calls (up to limit).
+func printReplayReport(report engine.ReplayReport, limit int) {
+	fmt.Printf("[ctrlai] Replayed %d tool calls: %d would change", report.Total, report.Changed)
+	if report.Skipped > 0 {
+		fmt.Printf(" (%d skipped: no stored arguments)", report.Skipped)
+	}
+	fmt.Println()
+	if report.Changed == 0 {
+		return
+	}
+
+	fmt.Println()
+	fmt.Printf("%-30s %-15s %-8s %s\n", "RULE", "AGENT", "CHANGES", "TRANSITIONS")
+	fmt.Printf("%-30s %-15s %-8s %s\n", "----", "-----", "-------", "-----------")
+	for _, g := range report.Groups {
+		transitions := make([]string, 0, len(g.Transitions))
+		for t, n := range g.Transitions {
+			transitions = append(transitions, fmt.Sprintf("%s (%d)", t, n))
+		}
+		sort.Strings(transitions)
+		rule := g.Rule
+		if rule == "" {
+			rule = "(no rule)"
+		}
+		fmt.Printf("%-30s %-15s %-8d %s\n", rule, g.Agent, g.Changes, strings.Join(transitions, ", "))
+	}
+
+	fmt.Println()
+	fmt.Printf("%-8s %-20s %-15s %-12s %-35s %s\n", "SEQ", "TIME", "AGENT", "TOOL", "BEFORE", "AFTER")
+	fmt.Printf("%-8s %-20s %-15s %-12s %-35s %s\n", "---", "----", "-----", "----", "------", "-----")
+	for i, c := range report.Changes {
+		if limit > 0 && i == limit {
+			fmt.Printf("... %d more (use --limit 0 or --format json to see all)\n", len(report.Changes)-limit)
+			break
+		}
+		ts := c.Timestamp
+		if t, err := time.Parse(time.RFC3339Nano, c.Timestamp); err == nil {
+			ts = t.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%-8d %-20s %-15s %-12s %-35s %s\n", c.Seq, ts, c.Agent, c.Tool,
+			formatReplayOutcome(c.Before), formatReplayOutcome(c.After))
+	}
+}
+
+// formatReplayOutcome renders an outcome as "decision (rule)".
+func formatReplayOutcome(o engine.ReplayOutcome) string {
+	if o.Rule == "" {
+		return o.Decision
+	}
+	return fmt.Sprintf("%s (%s)", o.Decision, o.Rule)
+}
+
 // ============================================================================
 // ctrlai audit — Query and verify the audit log
 // ============================================================================
@@ -1214,8 +2055,8 @@ Examples:
 
 func init() {
 	auditQueryCmd.Flags().StringVar(&auditQueryAgent, "agent", "", "Filter by agent ID")
-	auditQueryCmd.Flags().StringVar(&auditQueryDecision, "decision", "", "Filter by decision (allow/block)")
-	auditQueryCmd.Flags().StringVar(&auditQuerySince, "since", "", "Show entries since duration (e.g., 1h, 30m, 24h)")
+	auditQueryCmd.Flags().StringVar(&auditQueryDecision, "decision", "", "Filter by decision (allow/block/ask/would_block)")
+	auditQueryCmd.Flags().StringVar(&auditQuerySince, "since", "", "Show entries since duration (e.g., 1h, 30m, 24h, 7d)")
 	auditQueryCmd.Flags().IntVar(&auditQueryLimit, "limit", 50, "Maximum number of entries to return")
 }
 
@@ -1285,9 +2126,9 @@ func init() {
 // printAuditEntry formats and prints a single audit entry to stdout.
 func printAuditEntry(e audit.Entry) {
 	decision := e.Decision
-	// Uppercase blocked decisions for terminal visibility.
-	if decision == "block" {
-		decision = "BLOCK"
+	// Uppercase blocked/held decisions for terminal visibility.
+	if decision == "block" || decision == "ask" || decision == "redact" || decision == "rewrite" || decision == "would_block" {
+		decision = strings.ToUpper(decision)
 	}
 	if e.Tool != "" {
 		fmt.Printf("[%s] agent=%-10s tool=%-12s decision=%-6s rule=%s\n",
@@ -0,0 +1,213 @@
+// Package approval implements the operator approval queue used by "ask" rules.
+//
+// When a tool call matches a rule with action "ask", the proxy holds the
+// LLM response, submits a Request to the Queue, and blocks until an operator
+// approves or denies it (via the dashboard, the REST API, or
+// `ctrlai approve|deny <id>`) or the configured timeout expires.
+//
+// The queue is purely in-memory — pending approvals do not survive a proxy
+// restart, and the held response is lost with the connection anyway.
+package approval
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Reasons recorded on an Outcome, describing how the request was resolved.
+const (
+	ReasonApproved  = "approved"
+	ReasonDenied    = "denied"
+	ReasonTimeout   = "timeout"
+	ReasonCancelled = "cancelled"
+)
+
+// DefaultTimeout is used when the queue is created with a zero timeout.
+const DefaultTimeout = 2 * time.Minute
+
+// Request is a single tool call waiting for an operator decision.
+// Serialized as-is by GET /api/approvals.
+type Request struct {
+	ID        string    `json:"id"`
+	Agent     string    `json:"agent"`
+	Provider  string    `json:"provider"`
+	Model     string    `json:"model,omitempty"`
+	Tool      string    `json:"tool"`
+	Arguments any       `json:"arguments,omitempty"`
+	Rule      string    `json:"rule"`
+	Message   string    `json:"message,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Outcome is the resolution of a Request.
+//
+// Approver is the operator that made the decision ("system" for timeouts
+// and cancellations). Reason is one of the Reason* constants.
+type Outcome struct {
+	Approved bool   `json:"approved"`
+	Approver string `json:"approver"`
+	Reason   string `json:"reason"`
+}
+
+// pending tracks a submitted request and the channel its outcome is
+// delivered on. The channel is buffered (size 1) so Resolve never blocks.
+// Entries stay in the map until Wait collects the outcome, so a decision
+// made before the proxy starts waiting is not lost.
+type pending struct {
+	req      Request
+	done     chan Outcome
+	resolved bool
+}
+
+// Queue holds tool calls awaiting approval. Thread-safe — the proxy
+// submits and waits from request goroutines while the dashboard and CLI
+// resolve from API handlers.
+type Queue struct {
+	mu           sync.Mutex
+	pending      map[string]*pending
+	timeout      time.Duration
+	defaultAllow bool // Outcome applied when the timeout expires.
+	now          func() time.Time
+}
+
+// NewQueue creates an approval queue.
+//
+// Parameters:
+//   - timeout:       How long Wait blocks before applying the default (0 = DefaultTimeout)
+//   - defaultAction: "allow" or "block" — outcome when nobody answers in time.
+//     Anything other than "allow" fails closed to block.
+func NewQueue(timeout time.Duration, defaultAction string) *Queue {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Queue{
+		pending:      make(map[string]*pending),
+		timeout:      timeout,
+		defaultAllow: defaultAction == "allow",
+		now:          time.Now,
+	}
+}
+
+// Timeout returns the configured wait timeout.
+func (q *Queue) Timeout() time.Duration {
+	return q.timeout
+}
+
+// Submit queues a request and returns it with ID, CreatedAt, and ExpiresAt
+// filled in. The caller must follow up with Wait to collect the outcome.
+func (q *Queue) Submit(req Request) Request {
+	now := q.now().UTC()
+	req.ID = newID()
+	req.CreatedAt = now
+	req.ExpiresAt = now.Add(q.timeout)
+
+	q.mu.Lock()
+	q.pending[req.ID] = &pending{req: req, done: make(chan Outcome, 1)}
+	q.mu.Unlock()
+
+	return req
+}
+
+// Wait blocks until the request is resolved, the timeout expires, or ctx
+// is cancelled (client disconnected). On timeout the configured default is
+// applied; on cancellation the call is denied.
+func (q *Queue) Wait(ctx context.Context, id string) Outcome {
+	q.mu.Lock()
+	p, ok := q.pending[id]
+	q.mu.Unlock()
+	if !ok {
+		return Outcome{Approved: false, Approver: "system", Reason: ReasonCancelled}
+	}
+	defer func() {
+		q.mu.Lock()
+		delete(q.pending, id)
+		q.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(time.Until(p.req.ExpiresAt))
+	defer timer.Stop()
+
+	select {
+	case out := <-p.done:
+		return out
+	case <-timer.C:
+		out := Outcome{Approved: q.defaultAllow, Approver: "system", Reason: ReasonTimeout}
+		if q.finish(id, out) {
+			return out
+		}
+		// Lost the race against Resolve — its outcome is already buffered.
+		return <-p.done
+	case <-ctx.Done():
+		out := Outcome{Approved: false, Approver: "system", Reason: ReasonCancelled}
+		if q.finish(id, out) {
+			return out
+		}
+		return <-p.done
+	}
+}
+
+// Resolve records an operator decision for a pending request.
+// Returns an error if the ID is unknown or was already resolved.
+func (q *Queue) Resolve(id string, approve bool, approver string) error {
+	if approver == "" {
+		approver = "user"
+	}
+	reason := ReasonDenied
+	if approve {
+		reason = ReasonApproved
+	}
+	if !q.finish(id, Outcome{Approved: approve, Approver: approver, Reason: reason}) {
+		return fmt.Errorf("approval %q not found or already resolved", id)
+	}
+	return nil
+}
+
+// List returns all pending requests, oldest first.
+func (q *Queue) List() []Request {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	out := make([]Request, 0, len(q.pending))
+	for _, p := range q.pending {
+		if !p.resolved {
+			out = append(out, p.req)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].CreatedAt.Before(out[j].CreatedAt)
+	})
+	return out
+}
+
+// finish marks a pending request resolved and delivers its outcome.
+// Returns false if the request is unknown or was already resolved.
+func (q *Queue) finish(id string, out Outcome) bool {
+	q.mu.Lock()
+	p, ok := q.pending[id]
+	if ok && p.resolved {
+		ok = false
+	}
+	if ok {
+		p.resolved = true
+	}
+	q.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+	p.done <- out
+	return true
+}
+
+// newID returns a short random approval ID like "apr_3f9c1a2b7d4e".
+func newID() string {
+	b := make([]byte, 6)
+	_, _ = rand.Read(b)
+	return "apr_" + hex.EncodeToString(b)
+}
@@ -0,0 +1,107 @@
+package approval
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueue_SubmitList(t *testing.T) {
+	q := NewQueue(time.Minute, "block")
+	req := q.Submit(Request{Agent: "a1", Tool: "exec", Rule: "ask_exec"})
+
+	if !strings.HasPrefix(req.ID, "apr_") {
+		t.Errorf("expected apr_ prefix, got %q", req.ID)
+	}
+	if !req.ExpiresAt.After(req.CreatedAt) {
+		t.Error("ExpiresAt should be after CreatedAt")
+	}
+
+	list := q.List()
+	if len(list) != 1 || list[0].ID != req.ID {
+		t.Fatalf("expected 1 pending request, got %+v", list)
+	}
+}
+
+func TestQueue_Approve(t *testing.T) {
+	q := NewQueue(time.Minute, "block")
+	req := q.Submit(Request{Agent: "a1", Tool: "exec"})
+
+	go func() {
+		if err := q.Resolve(req.ID, true, "alice"); err != nil {
+			t.Error(err)
+		}
+	}()
+
+	out := q.Wait(context.Background(), req.ID)
+	if !out.Approved || out.Approver != "alice" || out.Reason != ReasonApproved {
+		t.Errorf("unexpected outcome: %+v", out)
+	}
+	if len(q.List()) != 0 {
+		t.Error("resolved request should be removed from the queue")
+	}
+}
+
+func TestQueue_Deny(t *testing.T) {
+	q := NewQueue(time.Minute, "allow")
+	req := q.Submit(Request{Agent: "a1", Tool: "exec"})
+
+	if err := q.Resolve(req.ID, false, ""); err != nil {
+		t.Fatal(err)
+	}
+	out := q.Wait(context.Background(), req.ID)
+	if out.Approved || out.Reason != ReasonDenied || out.Approver != "user" {
+		t.Errorf("unexpected outcome: %+v", out)
+	}
+}
+
+func TestQueue_ResolveTwice(t *testing.T) {
+	q := NewQueue(time.Minute, "block")
+	req := q.Submit(Request{Agent: "a1", Tool: "exec"})
+
+	if err := q.Resolve(req.ID, true, "u"); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Resolve(req.ID, false, "u"); err == nil {
+		t.Error("second resolve should fail")
+	}
+	if err := q.Resolve("apr_unknown", true, "u"); err == nil {
+		t.Error("unknown ID should fail")
+	}
+}
+
+func TestQueue_TimeoutDefault(t *testing.T) {
+	tests := []struct {
+		defaultAction string
+		wantApproved  bool
+	}{
+		{"block", false},
+		{"allow", true},
+		{"", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.defaultAction, func(t *testing.T) {
+			q := NewQueue(10*time.Millisecond, tt.defaultAction)
+			req := q.Submit(Request{Agent: "a1", Tool: "exec"})
+
+			out := q.Wait(context.Background(), req.ID)
+			if out.Approved != tt.wantApproved || out.Reason != ReasonTimeout {
+				t.Errorf("unexpected outcome: %+v", out)
+			}
+		})
+	}
+}
+
+func TestQueue_ContextCancelled(t *testing.T) {
+	q := NewQueue(time.Minute, "allow")
+	req := q.Submit(Request{Agent: "a1", Tool: "exec"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	out := q.Wait(ctx, req.ID)
+	if out.Approved || out.Reason != ReasonCancelled {
+		t.Errorf("cancelled wait should deny, got %+v", out)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -28,15 +29,65 @@ type Entry struct {
 	Agent     string `json:"agent"`
 	Provider  string `json:"provider,omitempty"`
 	Model     string `json:"model,omitempty"`
-	Type      string `json:"type"`                // "tool_call", "kill", "lifecycle"
+	Type      string `json:"type"`                // "tool_call", "approval", "kill", "lifecycle"
 	Tool      string `json:"tool,omitempty"`
 	Arguments any    `json:"arguments,omitempty"`
 	Decision  string `json:"decision"`
 	Rule      string `json:"rule,omitempty"`
 	Message   string `json:"message,omitempty"`
 	LatencyUs int64  `json:"latency_us,omitempty"`
-	PrevHash  string `json:"prev_hash"`
-	Hash      string `json:"hash"`
+
+	// Default is set when no rule matched and the decision came from the
+	// configured default_action (Rule is then "default_action").
+	Default bool `json:"default,omitempty"`
+
+	// CanonicalPath is the "path" argument after canonicalization (~ and
+	// $HOME expanded, . and .. resolved), as the rules saw it.
+	CanonicalPath string `json:"canonical_path,omitempty"`
+
+	// Explain is the rule evaluation trace for a blocked call, when
+	// audit.explainBlocks is enabled in config.yaml.
+	Explain any `json:"explain,omitempty"`
+
+	// SequenceSeqs are the seqs of the earlier calls that completed the
+	// sequence rule deciding this call, oldest first.
+	SequenceSeqs []uint64 `json:"sequence_seqs,omitempty"`
+
+	// TaintSeq is the seq of the sensitive call whose result this call's
+	// arguments carried, when a tainted: rule decided it.
+	TaintSeq uint64 `json:"taint_seq,omitempty"`
+
+	// Secrets lists what a contains_secret rule found: detector type and
+	// location for each credential, never the credential itself.
+	Secrets any `json:"secrets,omitempty"`
+
+	// PII lists what a contains_pii rule found, in the same form.
+	PII any `json:"pii,omitempty"`
+
+	// RuleBundle is the ID of the signed X-Ctrl-Rules bundle whose rules
+	// decided the call, empty when rules.yaml did.
+	RuleBundle string `json:"rule_bundle,omitempty"`
+
+	// DecisionSource is set on calls a "delegate" rule decided: "delegate"
+	// (the policy service answered), "delegate_cache" (a cached answer),
+	// or "delegate_fallback" (the service failed and onError applied).
+	DecisionSource string `json:"decision_source,omitempty"`
+
+	// RewrittenArgs are the arguments forwarded in place of Arguments,
+	// set on "redact" and "rewrite" tool calls.
+	RewrittenArgs any `json:"rewritten_args,omitempty"`
+
+	// ApprovalID and Approver are set on "ask" tool calls and on the
+	// "approval" entry that records the operator's decision.
+	ApprovalID string `json:"approval_id,omitempty"`
+	Approver   string `json:"approver,omitempty"`
+
+	// HashVersion is 2 on entries whose hash also covers the approval
+	// fields (see computeHash); 0 on entries hashed the original way.
+	HashVersion int `json:"hash_v,omitempty"`
+
+	PrevHash string `json:"prev_hash"`
+	Hash     string `json:"hash"`
 }
 
 // QueryParams defines filters for querying the audit log.
@@ -44,7 +95,8 @@ type Entry struct {
 type QueryParams struct {
 	Agent    string // Filter by agent ID (exact match).
 	Decision string // Filter by decision: "allow" or "block".
-	Since    string // ISO timestamp or duration string (e.g. "1h", "24h").
+	Type     string // Filter by entry type: "tool_call", "approval", ...
+	Since    string // ISO timestamp or duration string (e.g. "1h", "24h", "7d").
 	Limit    int    // Maximum entries to return.
 }
 
@@ -142,11 +194,10 @@ func (a *AuditLog) Close() error {
 //
 // Parameters match the entry format from design doc Section 8.2.
 func (a *AuditLog) LogToolCall(agent, provider, model, tool string, arguments any, decision, rule, message string, latencyUs int64) {
-	a.append(Entry{
+	a.LogToolCallEntry(Entry{
 		Agent:     agent,
 		Provider:  provider,
 		Model:     model,
-		Type:      "tool_call",
 		Tool:      tool,
 		Arguments: arguments,
 		Decision:  decision,
@@ -156,6 +207,37 @@ func (a *AuditLog) LogToolCall(agent, provider, model, tool string, arguments an
 	})
 }
 
+// LogToolCallEntry records a tool call evaluation from a prepared entry,
+// for callers that set fields beyond LogToolCall's parameters (e.g.
+// Default). Type is forced to "tool_call"; chain fields are filled in.
+// Returns the entry's sequence number, or 0 if it couldn't be written.
+func (a *AuditLog) LogToolCallEntry(e Entry) uint64 {
+	e.Type = "tool_call"
+	return a.append(e)
+}
+
+// LogApproval records the resolution of a pending approval.
+// Decision is "allow" if approved, "block" otherwise; reason is how it was
+// resolved ("approved", "denied", "timeout", "cancelled").
+func (a *AuditLog) LogApproval(agent, provider, model, tool, rule, approvalID string, approved bool, approver, reason string) {
+	decision := "block"
+	if approved {
+		decision = "allow"
+	}
+	a.append(Entry{
+		Agent:      agent,
+		Provider:   provider,
+		Model:      model,
+		Type:       "approval",
+		Tool:       tool,
+		Decision:   decision,
+		Rule:       rule,
+		Message:    reason,
+		ApprovalID: approvalID,
+		Approver:   approver,
+	})
+}
+
 // LogKill records a kill switch activation in the audit log.
 func (a *AuditLog) LogKill(agent, reason string) {
 	a.append(Entry{
@@ -221,10 +303,9 @@ func (a *AuditLog) Follow(ctx context.Context, callback func(Entry)) error {
 func (a *AuditLog) Query(params QueryParams) ([]Entry, error) {
 	// Convert "since" duration string (e.g. "1h", "24h") to ISO timestamp.
 	if params.Since != "" && !strings.Contains(params.Since, "T") {
-		// Parse as Go duration (e.g. "1h", "30m", "24h").
-		d, err := time.ParseDuration(params.Since)
+		d, err := parseSince(params.Since)
 		if err != nil {
-			return nil, fmt.Errorf("invalid since duration %q: %w", params.Since, err)
+			return nil, err
 		}
 		params.Since = time.Now().UTC().Add(-d).Format(time.RFC3339Nano)
 	}
@@ -237,6 +318,22 @@ func (a *AuditLog) Query(params QueryParams) ([]Entry, error) {
 	return a.readAllEntriesFiltered(params)
 }
 
+// parseSince parses a "since" duration: a Go duration (e.g. "1h", "30m",
+// "24h") or a number of days ("7d").
+func parseSince(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err == nil && n >= 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid since duration %q: %w", s, err)
+	}
+	return d, nil
+}
+
 // VerifyChain reads all audit entries and verifies the hash chain integrity.
 // Each entry's hash must match SHA-256(prev_hash | seq | ts | agent | tool | decision).
 // Returns the verification result, including where the chain broke (if at all).
@@ -334,8 +431,9 @@ func (a *AuditLog) Export(w io.Writer, format string) error {
 
 // append adds an entry to the audit log. Thread-safe.
 // Computes the hash chain, writes to the daily JSONL file, and updates
-// the SQLite index.
-func (a *AuditLog) append(e Entry) {
+// the SQLite index. Returns the entry's sequence number, or 0 if the
+// write failed.
+func (a *AuditLog) append(e Entry) uint64 {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
@@ -344,12 +442,15 @@ func (a *AuditLog) append(e Entry) {
 	e.Seq = a.seq
 	e.Timestamp = time.Now().UTC().Format(time.RFC3339Nano)
 	e.PrevHash = a.lastHash
+	if e.ApprovalID != "" || e.Approver != "" {
+		e.HashVersion = 2
+	}
 	e.Hash = computeHash(&e)
 
 	// Write to the daily JSONL file.
 	if err := a.writeToFile(&e); err != nil {
 		slog.Error("audit write failed", "seq", e.Seq, "error", err)
-		return
+		return 0
 	}
 
 	// Update the SQLite index (non-blocking, errors logged internally).
@@ -359,6 +460,7 @@ func (a *AuditLog) append(e Entry) {
 
 	// Update chain state.
 	a.lastHash = e.Hash
+	return e.Seq
 }
 
 // writeToFile appends the entry as a single JSON line to today's JSONL file.
@@ -599,6 +701,9 @@ func (a *AuditLog) readAllEntriesFiltered(params QueryParams) ([]Entry, error) {
 		if params.Decision != "" && e.Decision != params.Decision {
 			continue
 		}
+		if params.Type != "" && e.Type != params.Type {
+			continue
+		}
 		if params.Since != "" && e.Timestamp < params.Since {
 			continue
 		}
@@ -1,6 +1,10 @@
 package audit
 
 import (
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -71,6 +75,36 @@ func TestComputeHash_SensitiveToAllFields(t *testing.T) {
 	}
 }
 
+func TestComputeHash_ApprovalFields(t *testing.T) {
+	legacy := Entry{Seq: 1, Agent: "a", Tool: "exec", Decision: "allow", PrevHash: "sha256:00", ApprovalID: "ap_1", Approver: "alice"}
+	legacyHash := computeHash(&legacy)
+
+	// Entries hashed before approval fields were covered still verify.
+	edited := legacy
+	edited.Approver = "mallory"
+	if computeHash(&edited) != legacyHash {
+		t.Error("version 0 entries should keep the original formula")
+	}
+
+	v2 := legacy
+	v2.HashVersion = 2
+	v2.Hash = computeHash(&v2)
+	for name, modify := range map[string]func(e *Entry){
+		"approver":    func(e *Entry) { e.Approver = "mallory" },
+		"approval_id": func(e *Entry) { e.ApprovalID = "ap_2" },
+		"hash_v":      func(e *Entry) { e.HashVersion = 0 },
+	} {
+		modified := v2
+		modify(&modified)
+		if verifyEntry(&modified) {
+			t.Errorf("changing %s should break the entry's hash", name)
+		}
+	}
+	if !verifyEntry(&v2) {
+		t.Error("unmodified version 2 entry should verify")
+	}
+}
+
 func TestVerifyEntry_Valid(t *testing.T) {
 	e := &Entry{
 		Seq:       0,
@@ -154,3 +188,70 @@ func TestHashChain_Integrity(t *testing.T) {
 	// Individual verification of e3 still passes — you need chain verification
 	// to catch this (verify e2.Hash == computeHash(e2) for the chain to hold).
 }
+
+func TestIndex_RoundTripsEveryField(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.db")
+
+	// An index created before the entry column existed, with one old row.
+	old, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = old.Exec(`
+		CREATE TABLE entries (
+			seq INTEGER PRIMARY KEY, ts TEXT NOT NULL, agent TEXT NOT NULL DEFAULT '',
+			provider TEXT NOT NULL DEFAULT '', model TEXT NOT NULL DEFAULT '',
+			type TEXT NOT NULL DEFAULT '', tool TEXT NOT NULL DEFAULT '',
+			arguments TEXT NOT NULL DEFAULT '', decision TEXT NOT NULL DEFAULT '',
+			rule TEXT NOT NULL DEFAULT '', latency_us INTEGER NOT NULL DEFAULT 0,
+			hash TEXT NOT NULL DEFAULT '');
+		INSERT INTO entries (seq, ts, agent, tool, arguments, decision) VALUES (1, 't1', 'main', 'exec', '{"command":"ls"}', 'allow');`)
+	old.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := openIndex(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Entry{
+		Seq: 2, Timestamp: "t2", Agent: "main", Provider: "anthropic", Model: "m",
+		Type: "tool_call", Tool: "memo", Decision: "redact", Rule: "scrub",
+		Message: "scrubbed", LatencyUs: 12, Default: true, CanonicalPath: "/tmp/x",
+		Explain:        map[string]any{"decision": "redact"},
+		SequenceSeqs:   []uint64{1},
+		TaintSeq:       1,
+		Arguments:      map[string]any{"text": "key [REDACTED:github_token]"},
+		Secrets:        []any{map[string]any{"type": "github_token", "field": "text"}},
+		PII:            []any{map[string]any{"type": "email", "field": "text"}},
+		RuleBundle:     "bundle-1",
+		DecisionSource: "delegate",
+		RewrittenArgs:  map[string]any{"text": "key [REDACTED:github_token]"},
+		ApprovalID:     "apr_1", Approver: "alice", HashVersion: 2,
+		PrevHash: "p", Hash: "h",
+	}
+	idx.insert(&want)
+
+	got, err := idx.query(QueryParams{Agent: "main"})
+	if err != nil || len(got) != 2 {
+		t.Fatalf("query: %v %+v", err, got)
+	}
+	if !reflect.DeepEqual(got[0], want) {
+		g, _ := json.Marshal(got[0])
+		w, _ := json.Marshal(want)
+		t.Errorf("round trip lost fields:\n got %s\nwant %s", g, w)
+	}
+	if got[1].Seq != 1 || got[1].Tool != "exec" || got[1].Arguments.(map[string]any)["command"] != "ls" {
+		t.Errorf("old row should still read from the columns, got %+v", got[1])
+	}
+
+	// Reopening an index that already has the column is fine.
+	idx.close()
+	idx, err = openIndex(path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	idx.close()
+}
@@ -3,8 +3,9 @@
 // Every tool call evaluation, kill switch event, and proxy lifecycle event
 // is recorded as an Entry in an append-only JSONL file. Each entry's hash
 // is computed as SHA-256(prev_hash | seq | timestamp | agent | tool | decision),
-// forming a hash chain where tampering with any entry breaks the chain
-// from that point forward.
+// plus the approval ID and approver on entries that carry them, forming a
+// hash chain where tampering with any entry breaks the chain from that
+// point forward.
 //
 // See design doc Section 8 for the audit log design.
 package audit
@@ -23,12 +24,20 @@ import (
 //
 //	SHA-256(prev_hash | seq | timestamp | agent | tool | decision)
 //
+// Entries with HashVersion 2 (those with an approval ID or approver) also
+// cover "| approval_id | approver", so who approved a call can't be edited.
+// Entries without it keep the original formula, so logs written before
+// it verify unchanged; removing hash_v from an entry changes its hash.
+//
 // Returns a prefixed hash string: "sha256:<hex>".
 func computeHash(e *Entry) string {
 	h := sha256.New()
 	fmt.Fprintf(h, "%s|%d|%s|%s|%s|%s",
 		e.PrevHash, e.Seq, e.Timestamp,
 		e.Agent, e.Tool, e.Decision)
+	if e.HashVersion >= 2 {
+		fmt.Fprintf(h, "|%s|%s", e.ApprovalID, e.Approver)
+	}
 	return "sha256:" + hex.EncodeToString(h.Sum(nil))
 }
 
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"strings"
 
 	_ "github.com/glebarez/go-sqlite"
 )
@@ -41,7 +42,8 @@ func openIndex(path string) (*sqliteIndex, error) {
 			decision   TEXT NOT NULL DEFAULT '',
 			rule       TEXT NOT NULL DEFAULT '',
 			latency_us INTEGER NOT NULL DEFAULT 0,
-			hash       TEXT NOT NULL DEFAULT ''
+			hash       TEXT NOT NULL DEFAULT '',
+			entry      TEXT NOT NULL DEFAULT ''
 		);
 		CREATE INDEX IF NOT EXISTS idx_agent ON entries(agent);
 		CREATE INDEX IF NOT EXISTS idx_decision ON entries(decision);
@@ -53,19 +55,32 @@ func openIndex(path string) (*sqliteIndex, error) {
 		return nil, fmt.Errorf("creating sqlite schema: %w", err)
 	}
 
+	// Indexes created before the entry column existed get it added here.
+	// Their old rows keep an empty entry and are read from the columns.
+	_, err = db.Exec(`ALTER TABLE entries ADD COLUMN entry TEXT NOT NULL DEFAULT ''`)
+	if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+		db.Close()
+		return nil, fmt.Errorf("migrating sqlite schema: %w", err)
+	}
+
 	return &sqliteIndex{db: db}, nil
 }
 
 // insert adds an entry to the SQLite index. Non-blocking — errors are
 // logged but don't affect the primary JSONL audit log.
+//
+// The columns hold what queries filter on; the entry column holds the
+// whole entry as JSON so queries return every field the JSONL line has.
 func (idx *sqliteIndex) insert(e *Entry) {
 	argsJSON, _ := json.Marshal(e.Arguments)
+	entryJSON, _ := json.Marshal(e)
 
 	_, err := idx.db.Exec(
-		`INSERT OR REPLACE INTO entries (seq, ts, agent, provider, model, type, tool, arguments, decision, rule, latency_us, hash)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		`INSERT OR REPLACE INTO entries (seq, ts, agent, provider, model, type, tool, arguments, decision, rule, latency_us, hash, entry)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		e.Seq, e.Timestamp, e.Agent, e.Provider, e.Model, e.Type,
 		e.Tool, string(argsJSON), e.Decision, e.Rule, e.LatencyUs, e.Hash,
+		string(entryJSON),
 	)
 	if err != nil {
 		slog.Error("sqlite index insert failed", "seq", e.Seq, "error", err)
@@ -74,7 +89,7 @@ func (idx *sqliteIndex) insert(e *Entry) {
 
 // query retrieves entries from the SQLite index matching the given params.
 func (idx *sqliteIndex) query(params QueryParams) ([]Entry, error) {
-	query := "SELECT seq, ts, agent, provider, model, type, tool, arguments, decision, rule, latency_us, hash FROM entries WHERE 1=1"
+	query := "SELECT seq, ts, agent, provider, model, type, tool, arguments, decision, rule, latency_us, hash, entry FROM entries WHERE 1=1"
 	var args []any
 
 	if params.Agent != "" {
@@ -85,6 +100,10 @@ func (idx *sqliteIndex) query(params QueryParams) ([]Entry, error) {
 		query += " AND decision = ?"
 		args = append(args, params.Decision)
 	}
+	if params.Type != "" {
+		query += " AND type = ?"
+		args = append(args, params.Type)
+	}
 	if params.Since != "" {
 		// Since is an ISO timestamp string, computed by the caller.
 		query += " AND ts >= ?"
@@ -107,15 +126,22 @@ func (idx *sqliteIndex) query(params QueryParams) ([]Entry, error) {
 	var entries []Entry
 	for rows.Next() {
 		var e Entry
-		var argsJSON string
+		var argsJSON, entryJSON string
 		err := rows.Scan(
 			&e.Seq, &e.Timestamp, &e.Agent, &e.Provider, &e.Model,
 			&e.Type, &e.Tool, &argsJSON, &e.Decision, &e.Rule,
-			&e.LatencyUs, &e.Hash,
+			&e.LatencyUs, &e.Hash, &entryJSON,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("scanning sqlite row: %w", err)
 		}
+		if entryJSON != "" {
+			var full Entry
+			if jsonErr := json.Unmarshal([]byte(entryJSON), &full); jsonErr == nil {
+				entries = append(entries, full)
+				continue
+			}
+		}
 		// Parse arguments JSON back into any.
 		if argsJSON != "" && argsJSON != "null" {
 			var parsed any
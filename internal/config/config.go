@@ -6,12 +6,16 @@
 //   - Upstream LLM provider URLs (Anthropic, OpenAI, Moonshot, Qwen, MiniMax, Zhipu, custom)
 //   - Streaming behavior (buffer SSE for tool inspection)
 //   - Dashboard toggle
+//   - Approval queue timeout and default outcome for "ask" rules
+//   - Keys that may sign X-Ctrl-Rules runtime rule bundles
+//   - The policy service "delegate" rules hand their decisions to
 //
 // See design doc Section 3 for the full YAML schema.
 package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 
 	"gopkg.in/yaml.v3"
@@ -25,6 +29,11 @@ type Config struct {
 	Providers map[string]ProviderConfig `yaml:"providers"`
 	Streaming StreamingConfig           `yaml:"streaming"`
 	Dashboard DashboardConfig           `yaml:"dashboard"`
+	Approvals ApprovalsConfig           `yaml:"approvals"`
+	Audit     AuditConfig               `yaml:"audit"`
+	Delegate  DelegateConfig            `yaml:"delegate"`
+
+	RuntimeRules RuntimeRulesConfig `yaml:"runtimeRules"`
 }
 
 // ServerConfig defines where the proxy listens.
@@ -58,6 +67,62 @@ type DashboardConfig struct {
 	Enabled bool `yaml:"enabled"`
 }
 
+// ApprovalsConfig controls how long tool calls matched by an "ask" rule are
+// held while waiting for an operator decision, and what happens if nobody
+// answers in time.
+//
+// TimeoutMs: how long the proxy holds the response. Default: 120000ms.
+// Default: outcome applied on timeout — "block" (default) or "allow".
+type ApprovalsConfig struct {
+	TimeoutMs int    `yaml:"timeoutMs"`
+	Default   string `yaml:"default"`
+}
+
+// AuditConfig controls optional audit log content.
+//
+// ExplainBlocks: attach the full rule evaluation trace (the same one
+// `ctrlai rules explain` prints) to audit entries for blocked tool calls.
+// Off by default — traces list every rule and make entries much larger.
+type AuditConfig struct {
+	ExplainBlocks bool `yaml:"explainBlocks"`
+}
+
+// DelegateConfig points "delegate" rules at an external policy service.
+//
+// URL: where tool calls are POSTed as JSON. Empty: delegate rules block.
+// TimeoutMs: per-call limit on the service's answer. Default: 1000ms.
+// OnError: decision when the service times out or fails — "block"
+// (default, fail closed) or "allow" (fail open).
+// CacheTtlMs: how long an answer is reused for the same rule, agent, tool
+// and arguments. Default: 60000ms; 0 disables caching.
+type DelegateConfig struct {
+	URL        string `yaml:"url"`
+	TimeoutMs  int    `yaml:"timeoutMs"`
+	OnError    string `yaml:"onError"`
+	CacheTtlMs int    `yaml:"cacheTtlMs"`
+}
+
+// RuntimeRulesConfig lists the keys trusted to sign X-Ctrl-Rules bundles.
+// A bundle replaces rules.yaml for its request, so only bundles signed by
+// one of these keys, and not expired, are used. With no keys configured
+// the header is ignored.
+type RuntimeRulesConfig struct {
+	Keys []RuleKeyConfig `yaml:"keys,omitempty"`
+}
+
+// RuleKeyConfig is one bundle signing key.
+//
+// Algorithm: "ed25519" (PublicKey: PEM, or base64 of the raw 32 bytes) or
+// "hmac-sha256" (Secret: base64, at least 32 bytes).
+// Org: when set, the key only signs bundles for that org.
+type RuleKeyConfig struct {
+	ID        string `yaml:"id"`
+	Org       string `yaml:"org,omitempty"`
+	Algorithm string `yaml:"algorithm"`
+	PublicKey string `yaml:"publicKey,omitempty"`
+	Secret    string `yaml:"secret,omitempty"`
+}
+
 // Load reads and parses config.yaml from the given path.
 // If the file doesn't exist, returns defaults (not an error).
 // Invalid YAML or validation failures return an error.
@@ -112,6 +177,25 @@ func WriteDefault(path string) error {
 #
 # dashboard:
 #   enabled: Serve web UI at /dashboard on the same port
+#
+# approvals:
+#   timeoutMs: How long "ask" rules hold a response waiting for approval
+#   default: Outcome when nobody answers in time (block or allow)
+#
+# audit:
+#   explainBlocks: Attach the rule evaluation trace to blocked tool call entries
+#
+# delegate:
+#   url: Policy service "delegate" rules POST tool calls to (empty = they block)
+#   timeoutMs: How long to wait for the service's answer
+#   onError: Decision when the service fails or times out (block or allow)
+#   cacheTtlMs: Reuse an answer for identical calls this long (0 = no cache)
+#
+# runtimeRules:
+#   keys: Keys trusted to sign X-Ctrl-Rules bundles (none = header ignored)
+#     - id: Key ID the bundle names
+#       org: Only sign bundles for this org (optional)
+#       algorithm: ed25519 (publicKey) or hmac-sha256 (secret)
 
 `
 	return os.WriteFile(path, []byte(header+string(data)), 0o644)
@@ -140,6 +224,15 @@ func applyDefaults() *Config {
 		Dashboard: DashboardConfig{
 			Enabled: true,
 		},
+		Approvals: ApprovalsConfig{
+			TimeoutMs: 120000,
+			Default:   "block",
+		},
+		Delegate: DelegateConfig{
+			TimeoutMs:  1000,
+			OnError:    "block",
+			CacheTtlMs: 60000,
+		},
 	}
 }
 
@@ -162,5 +255,48 @@ func validate(cfg *Config) error {
 		return fmt.Errorf("streaming.bufferTimeoutMs must be non-negative")
 	}
 
+	if cfg.Approvals.TimeoutMs < 0 {
+		return fmt.Errorf("approvals.timeoutMs must be non-negative")
+	}
+	switch cfg.Approvals.Default {
+	case "", "allow", "block":
+	default:
+		return fmt.Errorf("approvals.default must be \"allow\" or \"block\", got %q", cfg.Approvals.Default)
+	}
+
+	if cfg.Delegate.URL != "" {
+		u, err := url.Parse(cfg.Delegate.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("delegate.url must be an http(s) URL, got %q", cfg.Delegate.URL)
+		}
+	}
+	if cfg.Delegate.TimeoutMs < 0 || cfg.Delegate.CacheTtlMs < 0 {
+		return fmt.Errorf("delegate.timeoutMs and delegate.cacheTtlMs must be non-negative")
+	}
+	switch cfg.Delegate.OnError {
+	case "", "allow", "block":
+	default:
+		return fmt.Errorf("delegate.onError must be \"allow\" or \"block\", got %q", cfg.Delegate.OnError)
+	}
+
+	seen := make(map[string]bool)
+	for i, k := range cfg.RuntimeRules.Keys {
+		if k.ID == "" {
+			return fmt.Errorf("runtimeRules.keys[%d]: id is required", i)
+		}
+		if seen[k.ID] {
+			return fmt.Errorf("runtimeRules.keys[%d]: duplicate id %q", i, k.ID)
+		}
+		seen[k.ID] = true
+		switch {
+		case k.Algorithm == "ed25519" && (k.PublicKey == "" || k.Secret != ""):
+			return fmt.Errorf("runtimeRules.keys[%d]: ed25519 keys need publicKey and no secret", i)
+		case k.Algorithm == "hmac-sha256" && (k.Secret == "" || k.PublicKey != ""):
+			return fmt.Errorf("runtimeRules.keys[%d]: hmac-sha256 keys need secret and no publicKey", i)
+		case k.Algorithm != "ed25519" && k.Algorithm != "hmac-sha256":
+			return fmt.Errorf("runtimeRules.keys[%d]: algorithm must be \"ed25519\" or \"hmac-sha256\", got %q", i, k.Algorithm)
+		}
+	}
+
 	return nil
 }
@@ -184,6 +184,72 @@ func TestValidate(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "negative approval timeout",
+			cfg: Config{
+				Server:    ServerConfig{Host: "127.0.0.1", Port: 3100},
+				Providers: map[string]ProviderConfig{"a": {Upstream: "http://x"}},
+				Approvals: ApprovalsConfig{TimeoutMs: -1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown approval default",
+			cfg: Config{
+				Server:    ServerConfig{Host: "127.0.0.1", Port: 3100},
+				Providers: map[string]ProviderConfig{"a": {Upstream: "http://x"}},
+				Approvals: ApprovalsConfig{Default: "maybe"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "delegate url without scheme",
+			cfg: Config{
+				Server:    ServerConfig{Host: "127.0.0.1", Port: 3100},
+				Providers: map[string]ProviderConfig{"a": {Upstream: "http://x"}},
+				Delegate:  DelegateConfig{URL: "127.0.0.1:9000/decide"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown delegate onError",
+			cfg: Config{
+				Server:    ServerConfig{Host: "127.0.0.1", Port: 3100},
+				Providers: map[string]ProviderConfig{"a": {Upstream: "http://x"}},
+				Delegate:  DelegateConfig{URL: "http://127.0.0.1:9000/decide", OnError: "retry"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "runtime rule key without material",
+			cfg: Config{
+				Server:       ServerConfig{Host: "127.0.0.1", Port: 3100},
+				Providers:    map[string]ProviderConfig{"a": {Upstream: "http://x"}},
+				RuntimeRules: RuntimeRulesConfig{Keys: []RuleKeyConfig{{ID: "k1", Algorithm: "ed25519"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate runtime rule key",
+			cfg: Config{
+				Server:    ServerConfig{Host: "127.0.0.1", Port: 3100},
+				Providers: map[string]ProviderConfig{"a": {Upstream: "http://x"}},
+				RuntimeRules: RuntimeRulesConfig{Keys: []RuleKeyConfig{
+					{ID: "k1", Algorithm: "hmac-sha256", Secret: "c2VjcmV0"},
+					{ID: "k1", Algorithm: "hmac-sha256", Secret: "c2VjcmV0"},
+				}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "unknown runtime rule algorithm",
+			cfg: Config{
+				Server:       ServerConfig{Host: "127.0.0.1", Port: 3100},
+				Providers:    map[string]ProviderConfig{"a": {Upstream: "http://x"}},
+				RuntimeRules: RuntimeRulesConfig{Keys: []RuleKeyConfig{{ID: "k1", Algorithm: "rsa", PublicKey: "x"}}},
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -22,6 +22,17 @@ type WatchTargets struct {
 	// instantly — the CLI writes killed.yaml, the watcher fires, and
 	// the proxy's kill switch state updates in memory.
 	OnKillSwitchChange func()
+
+	// OnListChange fires when one of the files ListFiles returns is
+	// written or created. Typically triggers engine.Reload(), which
+	// re-reads the lists that changed.
+	OnListChange func()
+
+	// ListFiles returns the extra files to watch: the host lists that
+	// rules.yaml references, which may live outside the config directory.
+	// Called at startup and again after every rules or list change, so a
+	// rules edit that adds or drops a list is followed. May be nil.
+	ListFiles func() []string
 }
 
 // Watcher monitors the CtrlAI config directory for file changes using
@@ -33,10 +44,16 @@ type WatchTargets struct {
 type Watcher struct {
 	fsWatcher *fsnotify.Watcher
 	done      chan struct{}
+
+	// Extra files from ListFiles, and the directories watched for them.
+	// Only touched by NewWatcher and then the event goroutine.
+	files map[string]bool
+	dirs  map[string]bool
 }
 
 // NewWatcher creates a file watcher on the given config directory.
-// It watches for changes to rules.yaml and killed.yaml.
+// It watches for changes to rules.yaml and killed.yaml, and to the files
+// targets.ListFiles returns.
 //
 // The watcher immediately starts processing events in a background
 // goroutine. Events are debounced naturally by fsnotify — rapid
@@ -57,7 +74,10 @@ func NewWatcher(dir string, targets WatchTargets) (*Watcher, error) {
 	w := &Watcher{
 		fsWatcher: fw,
 		done:      make(chan struct{}),
+		files:     make(map[string]bool),
+		dirs:      map[string]bool{absPath(dir): true},
 	}
+	w.watchFiles(targets)
 
 	// Start the event processing goroutine.
 	go w.processEvents(targets)
@@ -81,6 +101,15 @@ func (w *Watcher) processEvents(targets WatchTargets) {
 				continue
 			}
 
+			if w.files[absPath(event.Name)] {
+				slog.Info("host list changed, triggering reload", "path", event.Name)
+				if targets.OnListChange != nil {
+					targets.OnListChange()
+				}
+				w.watchFiles(targets)
+				continue
+			}
+
 			// Match on filename regardless of directory path.
 			name := filepath.Base(event.Name)
 			switch name {
@@ -89,6 +118,7 @@ func (w *Watcher) processEvents(targets WatchTargets) {
 				if targets.OnRulesChange != nil {
 					targets.OnRulesChange()
 				}
+				w.watchFiles(targets)
 			case "killed.yaml":
 				slog.Info("killed.yaml changed, triggering reload")
 				if targets.OnKillSwitchChange != nil {
@@ -108,6 +138,39 @@ func (w *Watcher) processEvents(targets WatchTargets) {
 	}
 }
 
+// watchFiles replaces the set of extra files with targets.ListFiles() and
+// watches their directories. fsnotify can't watch a file across the
+// rename-over that editors and feed updaters use, so the directory is
+// watched and events are filtered by path.
+func (w *Watcher) watchFiles(targets WatchTargets) {
+	if targets.ListFiles == nil {
+		return
+	}
+	files := make(map[string]bool)
+	for _, f := range targets.ListFiles() {
+		f = absPath(f)
+		files[f] = true
+		dir := filepath.Dir(f)
+		if w.dirs[dir] {
+			continue
+		}
+		if err := w.fsWatcher.Add(dir); err != nil {
+			slog.Error("watching list directory", "dir", dir, "error", err)
+			continue
+		}
+		w.dirs[dir] = true
+	}
+	w.files = files
+}
+
+// absPath returns p made absolute and cleaned, or p cleaned if that fails.
+func absPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return filepath.Clean(p)
+}
+
 // Close stops the file watcher goroutine and releases the underlying
 // fsnotify watcher. Safe to call multiple times.
 func (w *Watcher) Close() error {
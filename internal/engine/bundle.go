@@ -0,0 +1,228 @@
+package engine
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// X-Ctrl-Rules bundles. The header replaces the file rules for a request,
+// so anything that can set headers on the agent side could otherwise turn
+// every guardrail off. A bundle is only used when it is signed by a key
+// configured in config.yaml and hasn't expired.
+//
+// Header value: base64url(JSON RuleBundle) "." base64url(signature), both
+// unpadded. The signature covers the first part as sent. Which algorithm
+// verifies it is fixed by the key, never by the bundle.
+
+// Bundle signing algorithms.
+const (
+	BundleEd25519    = "ed25519"
+	BundleHMACSHA256 = "hmac-sha256"
+)
+
+// bundleClockSkew is how far in the future a bundle's issue time may be.
+const bundleClockSkew = 5 * time.Minute
+
+// RuleBundle is the signed content of an X-Ctrl-Rules header.
+type RuleBundle struct {
+	ID        string `json:"id"`  // Recorded in the audit log for every decision it governs.
+	Org       string `json:"org"` // Must equal the key's org, when the key has one.
+	KeyID     string `json:"kid"`
+	IssuedAt  int64  `json:"iat"` // Unix seconds.
+	ExpiresAt int64  `json:"exp"` // Unix seconds; required.
+	Rules     string `json:"rules"`
+}
+
+// BundleKey verifies bundles signed with one key.
+type BundleKey struct {
+	ID        string
+	Org       string // Empty: the key may sign for any org.
+	Algorithm string
+	public    ed25519.PublicKey
+	secret    []byte
+}
+
+// NewBundleKey builds a verification key from config.yaml. material is an
+// Ed25519 public key (PEM, or base64 of the 32 raw bytes) or a base64 HMAC
+// secret of at least 32 bytes.
+func NewBundleKey(id, org, algorithm, material string) (BundleKey, error) {
+	k := BundleKey{ID: id, Org: org, Algorithm: algorithm}
+	if id == "" {
+		return BundleKey{}, fmt.Errorf("key id is required")
+	}
+	switch algorithm {
+	case BundleEd25519:
+		pub, err := parseEd25519Public(material)
+		if err != nil {
+			return BundleKey{}, fmt.Errorf("key %q: %w", id, err)
+		}
+		k.public = pub
+	case BundleHMACSHA256:
+		secret, err := parseHMACSecret(material)
+		if err != nil {
+			return BundleKey{}, fmt.Errorf("key %q: %w", id, err)
+		}
+		k.secret = secret
+	default:
+		return BundleKey{}, fmt.Errorf("key %q: unknown algorithm %q (want %s or %s)", id, algorithm, BundleEd25519, BundleHMACSHA256)
+	}
+	return k, nil
+}
+
+// SignRuleBundle encodes and signs a bundle, returning the header value.
+// material is an Ed25519 private key (PKCS #8 PEM as written by
+// `openssl genpkey -algorithm ed25519`, or base64 of the 32-byte seed) or
+// the base64 HMAC secret.
+func SignRuleBundle(b RuleBundle, algorithm string, material []byte) (string, error) {
+	if b.ID == "" || b.KeyID == "" || b.ExpiresAt == 0 {
+		return "", fmt.Errorf("bundle needs an id, a key id and an expiry")
+	}
+	if _, _, err := ParseRulesFromYAML([]byte(b.Rules)); err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(b)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+
+	var sig []byte
+	switch algorithm {
+	case BundleEd25519:
+		priv, err := parseEd25519Private(string(material))
+		if err != nil {
+			return "", err
+		}
+		sig = ed25519.Sign(priv, []byte(payload))
+	case BundleHMACSHA256:
+		secret, err := parseHMACSecret(string(material))
+		if err != nil {
+			return "", err
+		}
+		sig = hmacSHA256(secret, payload)
+	default:
+		return "", fmt.Errorf("unknown algorithm %q (want %s or %s)", algorithm, BundleEd25519, BundleHMACSHA256)
+	}
+	return payload + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// VerifyRuleBundle checks a header value's signature against keys and its
+// validity period against now, and returns the bundle. Its rules are not
+// parsed yet.
+func VerifyRuleBundle(value string, keys []BundleKey, now time.Time) (*RuleBundle, error) {
+	payload, sigPart, ok := strings.Cut(strings.TrimSpace(value), ".")
+	if !ok {
+		return nil, errors.New("bundle is not signed")
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(sigPart)
+	if err != nil {
+		return nil, fmt.Errorf("decoding signature: %w", err)
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, fmt.Errorf("decoding bundle: %w", err)
+	}
+	var b RuleBundle
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("parsing bundle: %w", err)
+	}
+
+	var key *BundleKey
+	for i := range keys {
+		if keys[i].ID == b.KeyID {
+			key = &keys[i]
+			break
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("bundle %q: unknown key %q", b.ID, b.KeyID)
+	}
+	valid := false
+	switch key.Algorithm {
+	case BundleEd25519:
+		valid = ed25519.Verify(key.public, []byte(payload), sig)
+	case BundleHMACSHA256:
+		valid = hmac.Equal(sig, hmacSHA256(key.secret, payload))
+	}
+	if !valid {
+		return nil, fmt.Errorf("bundle %q: bad signature for key %q", b.ID, b.KeyID)
+	}
+
+	switch {
+	case b.ID == "":
+		return nil, errors.New("bundle has no id")
+	case key.Org != "" && b.Org != key.Org:
+		return nil, fmt.Errorf("bundle %q: org %q, but key %q signs for %q", b.ID, b.Org, key.ID, key.Org)
+	case b.ExpiresAt == 0:
+		return nil, fmt.Errorf("bundle %q: no expiry", b.ID)
+	case now.Unix() >= b.ExpiresAt:
+		return nil, fmt.Errorf("bundle %q: expired at %s", b.ID, time.Unix(b.ExpiresAt, 0).UTC().Format(time.RFC3339))
+	case b.IssuedAt != 0 && time.Unix(b.IssuedAt, 0).After(now.Add(bundleClockSkew)):
+		return nil, fmt.Errorf("bundle %q: issued in the future", b.ID)
+	}
+	return &b, nil
+}
+
+func hmacSHA256(secret []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
+
+// parseEd25519Public reads a PEM SubjectPublicKeyInfo or base64 raw key.
+func parseEd25519Public(s string) (ed25519.PublicKey, error) {
+	if block, _ := pem.Decode([]byte(s)); block != nil {
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing public key: %w", err)
+		}
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("public key is %T, not Ed25519", key)
+		}
+		return pub, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("public key must be PEM or base64 of %d bytes", ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(raw), nil
+}
+
+// parseEd25519Private reads a PKCS #8 PEM or base64 seed.
+func parseEd25519Private(s string) (ed25519.PrivateKey, error) {
+	if block, _ := pem.Decode([]byte(s)); block != nil {
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing private key: %w", err)
+		}
+		priv, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("private key is %T, not Ed25519", key)
+		}
+		return priv, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(raw) != ed25519.SeedSize {
+		return nil, fmt.Errorf("private key must be PKCS #8 PEM or base64 of a %d-byte seed", ed25519.SeedSize)
+	}
+	return ed25519.NewKeyFromSeed(raw), nil
+}
+
+// parseHMACSecret reads a base64 secret of at least 32 bytes.
+func parseHMACSecret(s string) ([]byte, error) {
+	secret, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(secret) < 32 {
+		return nil, errors.New("HMAC secret must be base64 of at least 32 bytes")
+	}
+	return secret, nil
+}
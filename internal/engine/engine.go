@@ -4,7 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/ctrlai/ctrlai/internal/extractor"
 	"gopkg.in/yaml.v3"
@@ -18,9 +23,24 @@ import (
 // Uses RWMutex so evaluations don't block each other.
 type Engine struct {
 	mu             sync.RWMutex
-	rules          []Rule            // Combined built-in + custom rules, in evaluation order.
-	customRules    []Rule            // Custom rules only (for serialization).
-	builtinToggles map[string]bool   // Toggle map for built-in rules.
+	rules          []Rule                 // Combined built-in + custom rules, in evaluation order.
+	customRules    []Rule                 // Custom rules only (for serialization).
+	builtinToggles map[string]bool        // Toggle map for built-in rules.
+	monitor        bool                   // Global dry-run switch from rules.yaml.
+	defaultAction  string                 // Global default_action ("" = allow).
+	agents         map[string]AgentPolicy // Per-agent overrides.
+	limiter        *rateLimiter           // rate_limit counters; kept across Reload.
+	pathSettings   PathSettings           // paths: section, as written.
+	paths          *pathResolver          // Canonicalizer built from pathSettings.
+	agentSource    func() []string        // Known agent IDs for Lint; nil skips the check.
+	stats          *ruleStats             // Per-rule hit counters; kept across Reload.
+	history        *callHistory           // Recent allowed calls per agent, for sequence rules.
+	taint          *taintTracker          // Sensitive result fingerprints per agent; kept across Reload.
+	taintSettings  TaintSettings          // taint: section, as written.
+	piiToggles     map[string]bool        // pii: section, as written.
+	piiEnabled     map[string]bool        // PII detectors left on by piiToggles.
+	listDir        string                 // Directory of rules.yaml; list names resolve against it.
+	lists          map[string]*hostList   // url_host_in_list files by name as written.
 	builtinCount   int
 	customCount    int
 }
@@ -31,7 +51,7 @@ type Engine struct {
 // Returns an error if the rules file is malformed or contains invalid
 // regex/glob patterns. Missing file is not an error (empty custom rules).
 func New(rulesPath string) (*Engine, error) {
-	e := &Engine{}
+	e := &Engine{limiter: newRateLimiter(), stats: newRuleStats(), history: newCallHistory(), taint: newTaintTracker()}
 	if err := e.load(rulesPath); err != nil {
 		return nil, err
 	}
@@ -39,7 +59,8 @@ func New(rulesPath string) (*Engine, error) {
 }
 
 // Evaluate checks a tool call against all rules in order.
-// First matching rule wins. If no rule matches, default is allow.
+// First matching rule wins. If no rule matches, the agent's effective
+// default_action decides (allow when none is configured).
 //
 // Design doc Section 6.3:
 //
@@ -53,30 +74,214 @@ func New(rulesPath string) (*Engine, error) {
 //	}
 //
 // Performance target: < 50us per tool call (design doc Section 17).
+//
+// Monitor-mode rules don't stop evaluation: their matches are collected in
+// WouldBlock and the enforced decision comes from the next enforcing rule.
+// With the global monitor switch on, the decision that would have been
+// enforced is reported instead, and the call is allowed.
 func (e *Engine) Evaluate(agentID string, tc extractor.ToolCall) Decision {
-	e.mu.RLock()
-	defer e.mu.RUnlock()
+	return e.EvaluateRequest(agentID, CallMeta{}, tc, nil)
+}
+
+// CallMeta describes the LLM request a tool call came back in. when:
+// expressions read it as provider and model.
+type CallMeta struct {
+	Provider string
+	Model    string
+
+	// Tools are the tools the request declared, with each one's argument
+	// JSON Schema (nil if it has none), for undeclared_tool and
+	// schema_invalid. nil when the request isn't known: neither matches.
+	Tools map[string]json.RawMessage
+
+	// Schemas caches the Tools schemas once compiled, so the calls of one
+	// response compile each schema once. nil compiles them per call.
+	Schemas *SchemaCache
+}
+
+// evaluateRules runs first-match-wins evaluation over rules, collecting
+// monitor-mode matches along the way. fallback is returned when no
+// enforcing rule matches. With monitorAll (the global monitor switch) the
+// decision is reached the same way but only reported (see reportOnly).
+//
+// A rate-limited rule that matches only fires once its budget is spent;
+// until then the call is counted and evaluation moves on.
+//
+// Every match that is acted on or reported counts as a hit in cc.stats.
+//
+// A matching sensitive rule sets Decision.Sensitive; if its action is
+// allow it only tags the call and evaluation moves on.
+//
+// A redact or rewrite rule's decision carries the new arguments in Args;
+// when the arguments didn't parse it blocks instead.
+func evaluateRules(rules []Rule, monitorAll bool, fallback Decision, cc *callContext) Decision {
+	var wouldBlock []Decision
+	sensitive := false
+
+	for i := range rules {
+		rule := &rules[i]
+		if !matchesRule(rule, cc) {
+			continue
+		}
+		if rule.Sensitive {
+			sensitive = true
+			if rule.Action == "allow" {
+				cc.hit(rule, false)
+				continue
+			}
+		}
+
+		d := Decision{
+			Action:  rule.Action,
+			Rule:    rule.Name,
+			Message: rule.Message,
+		}
+
+		if rule.rate != nil {
+			if cc.limiter == nil {
+				continue
+			}
+			ok, retryAfter := cc.limiter.allow(cc.rateID(rules, i), rule.rate, cc.agentID, cc.tc.Name, cc.now, !cc.dryRun)
+			if ok {
+				cc.hit(rule, false)
+				continue
+			}
+			d.Message = rateLimitMessage(rule, cc.agentID, cc.tc.Name, retryAfter)
+		}
+		if rule.jail != nil {
+			d.Message = rule.jail.message(cc.jailRaw, cc.jailPath)
+		}
+		if rule.seq != nil {
+			d.Sequence = cc.seqRefs
+		}
+		if rule.usesTaint() && cc.taintSrc != nil {
+			d.TaintSeq = cc.taintSrc.seq
+		}
+		d.Secrets = ruleSecrets(rule, cc)
+		d.PII = rulePII(rule, cc)
+
+		if rule.Mode == ModeMonitor {
+			cc.hit(rule, false)
+			// An allow rule in monitor mode would not have blocked anything,
+			// so there is nothing to report.
+			if d.Action != "allow" {
+				wouldBlock = append(wouldBlock, d)
+			}
+			continue
+		}
 
-	for _, rule := range e.rules {
-		if matchesRule(&rule, agentID, tc) {
-			return Decision{
-				Action:  rule.Action,
-				Rule:    rule.Name,
-				Message: rule.Message,
+		if d.Action == "redact" {
+			d.Args = redactArgs(cc.tc.Arguments, append(d.Secrets, d.PII...))
+			if d.Args == nil {
+				// Arguments that didn't parse can't be rewritten; fail closed.
+				d.Action = "block"
+				d.Message = strings.TrimSpace(d.Message + " (arguments could not be redacted)")
+			}
+		}
+		if d.Action == "rewrite" {
+			d.Args = rewriteArgs(cc.tc.Arguments, rule.patches)
+			if d.Args == nil {
+				d.Action = "block"
+				d.Message = strings.TrimSpace(d.Message + " (arguments could not be rewritten)")
 			}
 		}
+
+		d.Sensitive = sensitive
+		d.Path = cc.canonicalPath()
+		if rule.jail != nil {
+			d.Path = cc.jailPath
+		}
+		if monitorAll {
+			cc.hit(rule, false)
+			return reportOnly(d, wouldBlock)
+		}
+		cc.hit(rule, d.Action == "block")
+		d.WouldBlock = wouldBlock
+		return d
+	}
+
+	// No enforcing rule matched — the default decides.
+	fallback.Sensitive = sensitive
+	fallback.Path = cc.canonicalPath()
+	if monitorAll {
+		return reportOnly(fallback, wouldBlock)
+	}
+	fallback.WouldBlock = wouldBlock
+	return fallback
+}
+
+// reportOnly turns the decision enforcement would have made into the one
+// global monitor mode returns: the call is allowed, and if enforcement
+// would have blocked it, that block is reported in WouldBlock. Evaluation
+// stops at the same rule either way, so an allow rule ahead of a block
+// rule reports nothing.
+func reportOnly(d Decision, wouldBlock []Decision) Decision {
+	if d.Action == "block" {
+		wouldBlock = append(wouldBlock, d)
+	}
+	return Decision{Action: "allow", WouldBlock: wouldBlock, Path: d.Path, Sensitive: d.Sensitive}
+}
+
+// defaultDecision returns the decision for a tool call that matched no
+// rule: the agent's default_action override if set, else the global one.
+// Caller must hold the mutex.
+func (e *Engine) defaultDecision(agentID string) Decision {
+	action, agent := e.effectiveDefault(agentID)
+	if action == "" {
+		return Decision{Action: "allow"}
+	}
+
+	msg := fmt.Sprintf("No rule matched (default_action: %s)", action)
+	if agent != "" {
+		msg = fmt.Sprintf("No rule matched (default_action for agent %q: %s)", agent, action)
+	}
+	return Decision{Action: action, Rule: DefaultRuleName, Message: msg, Default: true}
+}
+
+// effectiveDefault returns the default_action that applies to agentID and,
+// if it comes from a per-agent override, the agent it was set for.
+// Caller must hold the mutex.
+func (e *Engine) effectiveDefault(agentID string) (action, agent string) {
+	if p, ok := e.agents[agentID]; ok && p.DefaultAction != "" {
+		return p.DefaultAction, agentID
 	}
+	return e.defaultAction, ""
+}
+
+// DefaultAction returns the effective default_action for an agent
+// ("allow" when none is configured).
+func (e *Engine) DefaultAction(agentID string) string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
 
-	// No rule matched — default allow.
-	return Decision{Action: "allow"}
+	if action, _ := e.effectiveDefault(agentID); action != "" {
+		return action
+	}
+	return "allow"
 }
 
 // EvaluateWithRuntimeRules checks a tool call against all rules (file-based + runtime).
 // Runtime rules are evaluated FIRST (higher priority than file-based rules).
 // This allows enterprise/org-specific rules to override defaults.
 //
-// Used when X-Ctrl-Rules header is present in the request.
+// Used when X-Ctrl-Rules header is present in the request. When it is absent
+// (runtimeRules is nil), this is equivalent to Evaluate.
 func (e *Engine) EvaluateWithRuntimeRules(agentID string, tc extractor.ToolCall, runtimeRules []Rule) Decision {
+	return e.EvaluateRequest(agentID, CallMeta{}, tc, runtimeRules)
+}
+
+// EvaluateRequest is EvaluateWithRuntimeRules for a call whose request is
+// known. The proxy uses it so rules can match on provider and model.
+func (e *Engine) EvaluateRequest(agentID string, meta CallMeta, tc extractor.ToolCall, runtimeRules []Rule) Decision {
+	if runtimeRules == nil {
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+
+		cc := e.newCallContext(agentID, tc)
+		cc.stats, cc.meta = e.stats, meta
+		return evaluateRules(e.rules, e.monitor, e.defaultDecision(agentID), cc)
+	}
+
 	slog.Info("🔍 EvaluateWithRuntimeRules called",
 		"agent_id", agentID,
 		"tool_name", tc.Name,
@@ -89,27 +294,37 @@ func (e *Engine) EvaluateWithRuntimeRules(agentID string, tc extractor.ToolCall,
 	// Runtime rules are the COMPLETE rule set from the enterprise header.
 	// They already include enabled built-ins + custom rules with toggles applied.
 	// Do NOT fall back to file-based rules — that would re-enable disabled built-ins.
-	for i, rule := range runtimeRules {
-		slog.Info("  Checking runtime rule", "index", i, "name", rule.Name)
-		if matchesRule(&rule, agentID, tc) {
-			slog.Info("🚫 BLOCKED by runtime rule", "rule", rule.Name, "message", rule.Message)
-			return Decision{
-				Action:  rule.Action,
-				Rule:    rule.Name,
-				Message: rule.Message,
-			}
-		}
+	// The global monitor switch in rules.yaml does not apply either — the
+	// header carries its own rule set, so only per-rule modes count.
+	// default_action from rules.yaml still applies: the header replaces
+	// the rules, not the posture for unmatched calls.
+	e.mu.RLock()
+	fallback := e.defaultDecision(agentID)
+	cc := e.newCallContext(agentID, tc)
+	cc.stats, cc.runtime, cc.meta = e.stats, true, meta
+	e.mu.RUnlock()
+
+	d := evaluateRules(runtimeRules, false, fallback, cc)
+	if d.Default {
+		slog.Info("No runtime rule matched, applying default_action", "action", d.Action, "tool", tc.Name, "agent", agentID)
+	} else if d.Rule != "" {
+		slog.Info("🚫 Matched runtime rule", "rule", d.Rule, "action", d.Action, "message", d.Message)
+	} else {
+		slog.Info("✅ ALLOWED - no runtime rules matched", "tool", tc.Name, "agent", agentID)
 	}
-
-	// No rule matched — default allow.
-	slog.Info("✅ ALLOWED - no runtime rules matched", "tool", tc.Name, "agent", agentID)
-	return Decision{Action: "allow"}
+	return d
 }
 
 // TestJSON evaluates a tool call provided as a JSON string.
 // Used by `ctrlai rules test` to verify rules without running a live agent.
 // The JSON should contain "name" and "arguments" fields.
 func (e *Engine) TestJSON(jsonStr string) (Decision, error) {
+	return e.TestJSONForAgent(jsonStr, "")
+}
+
+// TestJSONForAgent is TestJSON evaluated as the given agent, so
+// agent-specific rules and default_action overrides apply.
+func (e *Engine) TestJSONForAgent(jsonStr, agentID string) (Decision, error) {
 	var raw struct {
 		Name      string         `json:"name"`
 		Arguments map[string]any `json:"arguments"`
@@ -130,8 +345,8 @@ func (e *Engine) TestJSON(jsonStr string) (Decision, error) {
 		}
 	}
 
-	// Use empty agent ID for testing (matches all non-agent-specific rules).
-	return e.Evaluate("", tc), nil
+	// An empty agent ID matches all non-agent-specific rules.
+	return e.Evaluate(agentID, tc), nil
 }
 
 // TotalRules returns the total number of active rules (builtin + custom).
@@ -155,24 +370,93 @@ func (e *Engine) CustomCount() int {
 	return e.customCount
 }
 
-// ListRules returns summary info for all active rules.
-// Used by `ctrlai rules list`.
+// ListRules returns summary info for all active rules, followed by the
+// effective defaults: the global default_action, then per-agent overrides
+// sorted by agent ID. Runtime rules that have matched come last, sorted
+// by name. Used by `ctrlai rules list`.
 func (e *Engine) ListRules() []RuleInfo {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
 
 	infos := make([]RuleInfo, 0, len(e.rules))
 	for _, r := range e.rules {
-		infos = append(infos, RuleInfo{
+		mode := ModeEnforce
+		if e.monitor || r.Mode == ModeMonitor {
+			mode = ModeMonitor
+		}
+		info := RuleInfo{
 			Name:    r.Name,
 			Builtin: r.Builtin,
 			Action:  r.Action,
 			Message: r.Message,
+			Mode:    mode,
+			Stats:   e.stats.get(ruleScope(&r), r.Name),
+		}
+		if r.jail != nil {
+			info.Agent = r.jail.agent
+			info.Message = fmt.Sprintf("File tools confined to %s", strings.Join(r.jail.roots, ", "))
+		}
+		infos = append(infos, info)
+	}
+
+	mode := ModeEnforce
+	if e.monitor {
+		mode = ModeMonitor
+	}
+	global := e.defaultAction
+	if global == "" {
+		global = "allow"
+	}
+	infos = append(infos, RuleInfo{
+		Name:    DefaultRuleName,
+		Action:  global,
+		Message: "Applies when no rule matches",
+		Mode:    mode,
+		Default: true,
+	})
+
+	agents := make([]string, 0, len(e.agents))
+	for id, p := range e.agents {
+		if p.DefaultAction != "" {
+			agents = append(agents, id)
+		}
+	}
+	sort.Strings(agents)
+	for _, id := range agents {
+		infos = append(infos, RuleInfo{
+			Name:    DefaultRuleName,
+			Action:  e.agents[id].DefaultAction,
+			Message: fmt.Sprintf("Applies to agent %q when no rule matches", id),
+			Mode:    mode,
+			Default: true,
+			Agent:   id,
+		})
+	}
+
+	for _, name := range e.stats.names(ScopeRuntime) {
+		infos = append(infos, RuleInfo{
+			Name:    name,
+			Message: "Sent via X-Ctrl-Rules",
+			Runtime: true,
+			Stats:   e.stats.get(ScopeRuntime, name),
 		})
 	}
 	return infos
 }
 
+// RateLimitStatus returns current usage for every active rate limit
+// bucket. Used by /api/status and `ctrlai agents <id>`.
+func (e *Engine) RateLimitStatus() []RateLimitStatus {
+	return e.limiter.status(time.Now())
+}
+
+// Monitor reports whether the global monitor (dry-run) switch is on.
+func (e *Engine) Monitor() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.monitor
+}
+
 // AddRule parses a rule from a YAML string and adds it to the custom rules.
 // The new rule is compiled (regex/glob patterns validated) before adding.
 func (e *Engine) AddRule(yamlStr string) error {
@@ -195,7 +479,13 @@ func (e *Engine) AddRule(yamlStr string) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
-	e.customRules = append(e.customRules, rule)
+	rules := append(slices.Clip(e.customRules), rule)
+	lists, err := loadHostLists(e.listDir, rules, e.lists)
+	if err != nil {
+		return err
+	}
+	e.customRules = rules
+	e.lists = lists
 	e.rebuild()
 	return nil
 }
@@ -230,7 +520,16 @@ func (e *Engine) RemoveRule(name string) error {
 func (e *Engine) Save(path string) error {
 	e.mu.RLock()
 	defer e.mu.RUnlock()
-	return saveRulesToFile(path, e.customRules, e.builtinToggles)
+	return saveRulesToFile(path, rulesFile{
+		DefaultAction: e.defaultAction,
+		Agents:        e.agents,
+		Paths:         e.pathSettings,
+		Taint:         e.taintSettings,
+		PII:           e.piiToggles,
+		Monitor:       e.monitor,
+		Rules:         e.customRules,
+		Builtin:       e.builtinToggles,
+	})
 }
 
 // Reload reloads rules from the given YAML path.
@@ -243,7 +542,8 @@ func (e *Engine) Reload(path string) error {
 		return err
 	}
 
-	slog.Info("rules reloaded", "total", len(e.rules), "builtin", e.builtinCount, "custom", e.customCount)
+	slog.Info("rules reloaded", "total", len(e.rules), "builtin", e.builtinCount, "custom", e.customCount, "monitor", e.monitor, "default_action", e.defaultAction)
+	e.logLint()
 	return nil
 }
 
@@ -256,10 +556,11 @@ func (e *Engine) load(rulesPath string) error {
 
 // loadUnlocked does the actual loading. Caller must hold the mutex.
 func (e *Engine) loadUnlocked(rulesPath string) error {
-	customRules, builtinToggles, err := loadRulesFromFile(rulesPath)
+	file, err := loadRulesFromFile(rulesPath)
 	if err != nil {
 		return err
 	}
+	customRules, builtinToggles := file.Rules, file.Builtin
 
 	// Merge file toggles with defaults. If the file specifies a toggle, use it.
 	// Otherwise, fall back to the default (some builtins are off by default).
@@ -274,19 +575,65 @@ func (e *Engine) loadUnlocked(rulesPath string) error {
 		}
 	}
 
+	if err := validateDefaultAction("default_action", file.DefaultAction); err != nil {
+		return err
+	}
+	for id, p := range file.Agents {
+		if err := validateDefaultAction(fmt.Sprintf("agents.%s.default_action", id), p.DefaultAction); err != nil {
+			return err
+		}
+		if err := validateWorkspaceRoots(id, p.WorkspaceRoots, file.Paths); err != nil {
+			return err
+		}
+	}
+
+	taintTTL, err := compileTaintSettings(file.Taint)
+	if err != nil {
+		return err
+	}
+	if err := validatePIIToggles(file.PII); err != nil {
+		return err
+	}
+
 	// Compile matchers for custom rules.
 	for i := range customRules {
 		if err := compileMatcher(&customRules[i]); err != nil {
 			return err
 		}
 	}
+	listDir := filepath.Dir(rulesPath)
+	lists, err := loadHostLists(listDir, customRules, e.lists)
+	if err != nil {
+		return err
+	}
 
 	e.customRules = customRules
 	e.builtinToggles = builtinToggles
+	e.monitor = file.Monitor
+	e.defaultAction = file.DefaultAction
+	e.agents = file.Agents
+	e.pathSettings = file.Paths
+	e.paths = newPathResolver(file.Paths)
+	e.taintSettings = file.Taint
+	e.taint.setTTL(taintTTL)
+	e.piiToggles = file.PII
+	e.piiEnabled = piiEnabled(file.PII)
+	e.listDir = listDir
+	e.lists = lists
 	e.rebuild()
 	return nil
 }
 
+// validateDefaultAction checks a default_action value. where names the
+// setting for the error message.
+func validateDefaultAction(where, action string) error {
+	switch action {
+	case "", "allow", "block", "ask":
+		return nil
+	}
+	return fmt.Errorf("%s: unknown action %q (want allow, block, or ask)", where, action)
+}
+
 // rebuild merges built-in and custom rules into the combined evaluation list.
 // Built-in rules come first (higher priority), then custom rules.
 // Caller must hold the mutex.
@@ -313,6 +660,10 @@ func (e *Engine) rebuild() {
 		combined = append(combined, r)
 	}
 
+	// Workspace jails come right after the built-ins, so a custom allow
+	// rule can't let an agent out of its workspace.
+	combined = append(combined, workspaceRules(e.agents, e.pathSettings)...)
+
 	// Add custom rules after built-ins.
 	combined = append(combined, e.customRules...)
 
@@ -0,0 +1,384 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ctrlai/ctrlai/internal/extractor"
+)
+
+// Rule outcomes reported in RuleTrace.Outcome.
+const (
+	OutcomeNoMatch      = "no_match"      // At least one condition failed.
+	OutcomeDecided      = "decided"       // Matched and decided the call.
+	OutcomeWouldBlock   = "would_block"   // Matched in monitor mode; evaluation continued.
+	OutcomeMonitor      = "monitor"       // Monitor-mode allow rule matched; nothing to report.
+	OutcomeWithinBudget = "within_budget" // Rate-limited rule matched but the budget isn't spent.
+	OutcomeTagged       = "tagged"        // Sensitive allow rule matched; the call was tagged.
+	OutcomeNotReached   = "not_reached"   // An earlier rule already decided the call.
+)
+
+// Explanation is the full evaluation trace for one tool call: every rule
+// in evaluation order, with the outcome of each of its match fields.
+// Returned by Explain; serialized by /api/rules/explain and, for blocked
+// calls, into the audit entry.
+type Explanation struct {
+	Agent    string      `json:"agent"`
+	Tool     string      `json:"tool"`
+	Decision string      `json:"decision"`
+	Rule     string      `json:"rule,omitempty"`
+	Message  string      `json:"message,omitempty"`
+	Default  bool        `json:"default,omitempty"` // Decided by default_action.
+	Rules    []RuleTrace `json:"rules"`
+}
+
+// RuleTrace is one rule's part of an Explanation. Fields are traced even
+// for rules that weren't reached, to show what they would have done.
+type RuleTrace struct {
+	Rule    string       `json:"rule"`
+	Builtin bool         `json:"builtin,omitempty"`
+	Agent   string       `json:"agent,omitempty"` // Set on workspace jails.
+	Action  string       `json:"action"`
+	Matched bool         `json:"matched"`
+	Outcome string       `json:"outcome"`
+	Fields  []FieldTrace `json:"fields"`
+}
+
+// FieldTrace is the outcome of one match field. Pattern is what the rule
+// asks for and Value what the tool call had. Nested all/any/not blocks
+// carry a trace per child block.
+type FieldTrace struct {
+	Field    string         `json:"field"`
+	Pattern  string         `json:"pattern,omitempty"`
+	Value    string         `json:"value,omitempty"`
+	Matched  bool           `json:"matched"`
+	Children [][]FieldTrace `json:"children,omitempty"`
+}
+
+// maxTraceValue caps how much of an argument is copied into a trace.
+const maxTraceValue = 200
+
+// Explain evaluates a tool call like Evaluate and returns the decision
+// along with a trace of every rule. It has no side effects: rate limits
+// are checked but the call isn't counted.
+func (e *Engine) Explain(agentID string, tc extractor.ToolCall) Explanation {
+	return e.ExplainRequest(agentID, CallMeta{}, tc, nil)
+}
+
+// ExplainRequest is Explain for a call evaluated with EvaluateRequest: the
+// same request metadata and, when the request carried them, the same
+// runtime rules, so the trace matches the decision that was made.
+func (e *Engine) ExplainRequest(agentID string, meta CallMeta, tc extractor.ToolCall, runtimeRules []Rule) Explanation {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	rules, monitor := e.rules, e.monitor
+	cc := e.newCallContext(agentID, tc)
+	cc.dryRun, cc.meta = true, meta
+	if runtimeRules != nil {
+		// As in EvaluateRequest: the header's rules replace the file's,
+		// and the global monitor switch doesn't apply.
+		rules, monitor = runtimeRules, false
+		cc.runtime = true
+	}
+	d := evaluateRules(rules, monitor, e.defaultDecision(agentID), cc)
+
+	ex := Explanation{
+		Agent: agentID, Tool: tc.Name,
+		Decision: d.Action, Rule: d.Rule, Message: d.Message, Default: d.Default,
+		Rules: make([]RuleTrace, 0, len(rules)),
+	}
+
+	decided := false
+	for i := range rules {
+		r := &rules[i]
+		rt := RuleTrace{Rule: r.Name, Builtin: r.Builtin, Action: r.Action, Fields: explainMatch(&r.Match, r.compiled, cc)}
+		if r.jail != nil {
+			rt.Agent = r.jail.agent
+			rt.Fields = append(rt.Fields, explainJail(r.jail, cc))
+		}
+		if r.seq != nil {
+			rt.Fields = append(rt.Fields, explainSequence(r.seq, cc))
+		}
+		rt.Matched = matchesRule(r, cc)
+
+		switch {
+		case decided:
+			rt.Outcome = OutcomeNotReached
+		case !rt.Matched:
+			rt.Outcome = OutcomeNoMatch
+		case r.Sensitive && r.Action == "allow":
+			rt.Outcome = OutcomeTagged
+		case r.rate != nil && !rateExceeded(rules, i, cc):
+			rt.Outcome = OutcomeWithinBudget
+		case r.Mode == ModeMonitor:
+			rt.Outcome = OutcomeWouldBlock
+			if r.Action == "allow" {
+				rt.Outcome = OutcomeMonitor
+			}
+		case monitor:
+			// Global monitor stops here like enforcement would, and only
+			// a block is reported.
+			rt.Outcome = OutcomeMonitor
+			if r.Action == "block" {
+				rt.Outcome = OutcomeWouldBlock
+			}
+			decided = true
+		default:
+			rt.Outcome = OutcomeDecided
+			decided = true
+		}
+		ex.Rules = append(ex.Rules, rt)
+	}
+	return ex
+}
+
+// ExplainJSON is Explain for a tool call given as JSON, in the same
+// format as TestJSON.
+func (e *Engine) ExplainJSON(jsonStr, agentID string) (Explanation, error) {
+	var raw struct {
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	}
+	if err := json.Unmarshal([]byte(jsonStr), &raw); err != nil {
+		return Explanation{}, fmt.Errorf("parsing tool call JSON: %w", err)
+	}
+
+	tc := extractor.ToolCall{Name: raw.Name, Arguments: raw.Arguments}
+	if raw.Arguments != nil {
+		if data, err := json.Marshal(raw.Arguments); err == nil {
+			tc.RawJSON = data
+		}
+	}
+	return e.Explain(agentID, tc), nil
+}
+
+// rateExceeded reports whether the matching rate-limited rules[i] is
+// over budget, without counting the call.
+func rateExceeded(rules []Rule, i int, cc *callContext) bool {
+	if cc.limiter == nil {
+		return false
+	}
+	ok, _ := cc.limiter.allow(cc.rateID(rules, i), rules[i].rate, cc.agentID, cc.tc.Name, cc.now, false)
+	return !ok
+}
+
+// explainMatch traces every non-empty field of a match block. Each field
+// is checked on its own through matchesMatch, so the trace can't drift
+// from real evaluation.
+func explainMatch(m *RuleMatch, c *compiledMatcher, cc *callContext) []FieldTrace {
+	if c == nil {
+		c = &compiledMatcher{}
+	}
+	args := cc.tc.Arguments
+	var out []FieldTrace
+	field := func(name, pattern, value string, sub RuleMatch, subc compiledMatcher) {
+		out = append(out, FieldTrace{
+			Field: name, Pattern: pattern, Value: traceValue(value),
+			Matched: matchesMatch(&sub, &subc, cc),
+		})
+	}
+
+	if len(m.Tool) > 0 {
+		field("tool", strings.Join(m.Tool, ", "), cc.tc.Name, RuleMatch{Tool: m.Tool}, compiledMatcher{})
+	}
+	if m.Agent != "" {
+		field("agent", m.Agent, cc.agentID, RuleMatch{Agent: m.Agent}, compiledMatcher{})
+	}
+	if len(m.Action) > 0 {
+		field("action", strings.Join(m.Action, ", "), getStringArg(args, "action"), RuleMatch{Action: m.Action}, compiledMatcher{})
+	}
+	if len(m.Path) > 0 {
+		value := getStringArg(args, "path")
+		if canon := cc.canonicalPath(); canon != "" && canon != value {
+			value += " (canonical: " + canon + ")"
+		}
+		field("path", strings.Join(m.Path, ", "), value, RuleMatch{Path: m.Path}, compiledMatcher{pathGlobs: c.pathGlobs})
+	}
+	if len(m.ArgContains) > 0 {
+		field("arg_contains", strings.Join(m.ArgContains, ", "), string(cc.tc.RawJSON), RuleMatch{ArgContains: m.ArgContains}, compiledMatcher{})
+	}
+	if m.CommandRegex != "" {
+		field("command_regex", m.CommandRegex, getStringArg(args, "command"), RuleMatch{}, compiledMatcher{commandRegex: c.commandRegex})
+	}
+	if m.URLRegex != "" {
+		value := getStringArg(args, "url")
+		if value == "" {
+			value = getStringArg(args, "targetUrl")
+		}
+		field("url_regex", m.URLRegex, value, RuleMatch{}, compiledMatcher{urlRegex: c.urlRegex})
+	}
+	if len(m.Binary) > 0 || m.ArgvRegex != "" {
+		// Checked together: both must hold for the same parsed command.
+		var name, parts []string
+		if len(m.Binary) > 0 {
+			name = append(name, "binary")
+			parts = append(parts, strings.Join(m.Binary, ", "))
+		}
+		if m.ArgvRegex != "" {
+			name = append(name, "argv_regex")
+			parts = append(parts, m.ArgvRegex)
+		}
+		field(strings.Join(name, "+"), strings.Join(parts, " / "), getStringArg(args, "command"), RuleMatch{},
+			compiledMatcher{binaryGlobs: c.binaryGlobs, argvRegex: c.argvRegex})
+	}
+	if m.hasURLMatch() {
+		// Checked together: all must hold for the same URL.
+		var name, parts, urls []string
+		add := func(field string, set bool, pattern string) {
+			if set {
+				name = append(name, field)
+				parts = append(parts, pattern)
+			}
+		}
+		add("url_host", len(m.URLHost) > 0, strings.Join(m.URLHost, ", "))
+		add("url_host_in_list", len(m.URLHostInList) > 0, strings.Join(m.URLHostInList, ", "))
+		add("url_scheme", len(m.URLScheme) > 0, strings.Join(m.URLScheme, ", "))
+		add("url_port", len(m.URLPort) > 0, strings.Join(m.URLPort, ", "))
+		add("url_path", len(m.URLPath) > 0, strings.Join(m.URLPath, ", "))
+		if m.URLPrivate != nil {
+			add("url_private", true, fmt.Sprintf("%t", *m.URLPrivate))
+		}
+		for _, u := range cc.callURLs() {
+			urls = append(urls, u.raw)
+		}
+		sub := RuleMatch{URLHost: m.URLHost, URLHostInList: m.URLHostInList, URLScheme: m.URLScheme, URLPort: m.URLPort, URLPath: m.URLPath, URLPrivate: m.URLPrivate}
+		field(strings.Join(name, "+"), strings.Join(parts, " / "), strings.Join(urls, ", "), sub,
+			compiledMatcher{urlHosts: c.urlHosts, urlPorts: c.urlPorts, urlPathGlobs: c.urlPathGlobs})
+	}
+	for i, a := range m.Args {
+		var value string
+		if vals := selectArgPath(args, c.args[i].path); len(vals) > 0 {
+			data, _ := json.Marshal(vals[0])
+			value = string(data)
+		}
+		field("args", fmt.Sprintf("%s %s %v", a.Path, a.Op, a.Value), value, RuleMatch{}, compiledMatcher{args: c.args[i : i+1]})
+	}
+	if m.Tainted != nil {
+		value := "no sensitive content"
+		if src := cc.taintSource(); src != nil {
+			value = fmt.Sprintf("contains the result of %s call %s", src.tool, src.callID)
+			if src.seq != 0 {
+				value += " (audit " + formatSeqs([]uint64{src.seq}) + ")"
+			}
+		}
+		field("tainted", fmt.Sprintf("%t", *m.Tainted), value, RuleMatch{Tainted: m.Tainted}, compiledMatcher{})
+	}
+	if len(m.ContainsSecret) > 0 {
+		pattern := strings.Join(m.ContainsSecret, ", ")
+		if m.ContainsSecret.all() {
+			pattern = "any detector"
+		}
+		field("contains_secret", pattern, formatFindings(selectedFindings(m.ContainsSecret, cc.secretFindings())),
+			RuleMatch{ContainsSecret: m.ContainsSecret}, compiledMatcher{})
+	}
+	if len(m.ContainsPII) > 0 {
+		pattern := strings.Join(m.ContainsPII, ", ")
+		if m.ContainsPII.all() {
+			pattern = "any detector"
+		}
+		field("contains_pii", pattern, formatFindings(selectedFindings(m.ContainsPII, cc.piiFindings())),
+			RuleMatch{ContainsPII: m.ContainsPII}, compiledMatcher{})
+	}
+	if m.UndeclaredTool != nil {
+		value := "request not known"
+		if declared, known := cc.toolDeclared(); known && declared {
+			value = "declared"
+		} else if known {
+			value = "not declared"
+		}
+		field("undeclared_tool", fmt.Sprintf("%t", *m.UndeclaredTool), value, RuleMatch{UndeclaredTool: m.UndeclaredTool}, compiledMatcher{})
+	}
+	if m.SchemaInvalid != nil {
+		value := "no schema"
+		if violation, known := cc.argsSchemaViolation(); known {
+			value = violation
+			if value == "" {
+				value = "valid"
+			}
+		}
+		field("schema_invalid", fmt.Sprintf("%t", *m.SchemaInvalid), value, RuleMatch{SchemaInvalid: m.SchemaInvalid}, compiledMatcher{})
+	}
+	if m.When.Source != "" && c.when != nil {
+		// The value shows why an expression failed at run time.
+		value := ""
+		if _, err := c.when.evalBool(cc.exprEnv()); err != nil {
+			value = "error: " + err.Error()
+		}
+		field("when", m.When.Source, value, RuleMatch{When: m.When}, compiledMatcher{when: c.when})
+	}
+
+	if len(m.All) > 0 {
+		ft := FieldTrace{Field: "all", Matched: true}
+		for i := range m.All {
+			ft.Children = append(ft.Children, explainMatch(&m.All[i], c.all[i], cc))
+			if !matchesMatch(&m.All[i], c.all[i], cc) {
+				ft.Matched = false
+			}
+		}
+		out = append(out, ft)
+	}
+	if len(m.Any) > 0 {
+		ft := FieldTrace{Field: "any"}
+		for i := range m.Any {
+			ft.Children = append(ft.Children, explainMatch(&m.Any[i], c.any[i], cc))
+			if matchesMatch(&m.Any[i], c.any[i], cc) {
+				ft.Matched = true
+			}
+		}
+		out = append(out, ft)
+	}
+	if m.Not != nil {
+		out = append(out, FieldTrace{
+			Field:    "not",
+			Matched:  !matchesMatch(m.Not, c.not, cc),
+			Children: [][]FieldTrace{explainMatch(m.Not, c.not, cc)},
+		})
+	}
+	return out
+}
+
+// explainJail traces a workspace jail's path check.
+func explainJail(j *workspaceJail, cc *callContext) FieldTrace {
+	ft := FieldTrace{Field: "workspace_roots", Pattern: strings.Join(j.roots, ", "), Matched: true}
+	raw, resolved, escaped := j.escape(cc.tc)
+	if !escaped {
+		// No path escapes; the jail doesn't fire.
+		ft.Matched = false
+		ft.Value = strings.Join(toolCallPaths(cc.tc), ", ")
+		return ft
+	}
+	ft.Value = raw + " (resolves to " + resolved + ")"
+	return ft
+}
+
+// explainSequence traces a sequence rule: the last step against the call,
+// and whether the earlier steps were found in the agent's history.
+func explainSequence(s *sequenceSpec, cc *callContext) FieldTrace {
+	last := s.steps[len(s.steps)-1]
+	ft := FieldTrace{
+		Field:    "sequence",
+		Pattern:  fmt.Sprintf("%d steps", len(s.steps)),
+		Children: [][]FieldTrace{explainMatch(last.match, last.c, cc)},
+	}
+	seqs, ok := matchSequence(s, cc)
+	switch {
+	case ok:
+		ft.Matched = true
+		ft.Value = "earlier steps at audit " + formatSeqs(seqs)
+	case matchesMatch(last.match, last.c, cc):
+		ft.Value = "earlier steps not found in recent history"
+	default:
+		ft.Value = "call doesn't match the last step"
+	}
+	return ft
+}
+
+// traceValue truncates long argument values for the trace.
+func traceValue(s string) string {
+	if len(s) <= maxTraceValue {
+		return s
+	}
+	return strings.ToValidUTF8(s[:maxTraceValue], "") + "..."
+}
@@ -0,0 +1,1034 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gobwas/glob"
+	"gopkg.in/yaml.v3"
+)
+
+// Expr is a `when:` condition: a small expression over the tool call that
+// must evaluate to true for the block to match.
+//
+//	when: 'tool == "exec" && args.command.contains("curl") && !args.command.contains("api.internal")'
+//
+// Variables:
+//
+//	tool, agent, provider, model   strings ("" when unknown)
+//	args                           the parsed arguments (null if they didn't parse)
+//
+// Operators: || && ! == != < <= > >= in, literals ('s', "s", 1.5, true,
+// false, null, [a, b]), field access args.a.b and indexing args.list[0] or
+// args["key"]. Functions and methods:
+//
+//	len(x)                      length of a string, list or object
+//	glob(s, "pattern")          glob match, like the path field
+//	regex(s, "pattern")         RE2 search, like command_regex
+//	url.host(s)                 lowercased host of a URL, "" if none
+//	s.contains(t)  s.startsWith(t)  s.endsWith(t)  s.lower()
+//
+// Expressions are parsed and type-checked when rules load; errors point at
+// the line and column in rules.yaml. glob and regex patterns must be string
+// literals so they compile once. Evaluation has no loops or user
+// functions, and expressions are capped in length and size, so each one
+// costs at most a fixed number of steps.
+//
+// A missing field or list element is null, and so is any field of null,
+// so args.a.b is safe when a is absent. Other run-time type errors (e.g.
+// calling contains on null) make the condition false.
+type Expr struct {
+	Source string
+
+	line, col int  // Where Source starts in the YAML file; 0 if unknown.
+	block     bool // A block scalar: Source starts on the line after line.
+}
+
+// UnmarshalYAML keeps the scalar's position for error messages.
+func (x *Expr) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.ScalarNode {
+		return fmt.Errorf("line %d: when must be a string", node.Line)
+	}
+	x.Source = node.Value
+	x.line, x.col = node.Line, node.Column
+	switch node.Style {
+	case yaml.LiteralStyle, yaml.FoldedStyle:
+		x.block = true
+	case yaml.SingleQuotedStyle, yaml.DoubleQuotedStyle:
+		x.col++
+	}
+	return nil
+}
+
+// MarshalYAML writes the expression back as a plain string.
+func (x Expr) MarshalYAML() (any, error) { return x.Source, nil }
+
+// MarshalJSON writes the expression as a string, for the rules API.
+func (x Expr) MarshalJSON() ([]byte, error) { return json.Marshal(x.Source) }
+
+// IsZero lets omitempty drop an unset when.
+func (x Expr) IsZero() bool { return x.Source == "" }
+
+// position describes byte offset off in Source, as a location in the
+// rules file when known.
+func (x Expr) position(off int) string {
+	line := strings.Count(x.Source[:off], "\n")
+	col := off - strings.LastIndexByte(x.Source[:off], '\n')
+	switch {
+	case x.line == 0:
+		if line == 0 {
+			return fmt.Sprintf("column %d", col)
+		}
+		return fmt.Sprintf("line %d, column %d", line+1, col)
+	case x.block:
+		return fmt.Sprintf("line %d, column %d of the expression", x.line+1+line, col)
+	case line == 0:
+		return fmt.Sprintf("line %d, column %d", x.line, x.col+col-1)
+	default:
+		return fmt.Sprintf("line %d", x.line+line)
+	}
+}
+
+// Expression limits. Evaluation visits each node at most once, so these
+// bound its cost along with the size of the arguments.
+const (
+	maxExprLength = 4096
+	maxExprNodes  = 256
+	maxExprDepth  = 32
+)
+
+// exprType is the static type of an expression node.
+type exprType int
+
+const (
+	tDyn exprType = iota // Decided at run time (args and what's derived from it).
+	tString
+	tNumber
+	tBool
+	tNull
+	tList
+	tMap
+)
+
+func (t exprType) String() string {
+	return [...]string{"dyn", "string", "number", "bool", "null", "list", "object"}[t]
+}
+
+type exprKind int
+
+const (
+	eLiteral exprKind = iota
+	eIdent
+	eMember // kids[0].name
+	eIndex  // kids[0][kids[1]]
+	eCall   // name(kids...)
+	eMethod // kids[0].name(kids[1:]...)
+	eUnary  // name kids[0]
+	eBinary // kids[0] name kids[1]
+	eList   // [kids...]
+)
+
+// exprNode is a node of a parsed expression.
+type exprNode struct {
+	kind exprKind
+	pos  int
+	name string // Identifier, member, function or operator.
+	val  any    // Literal value.
+	kids []*exprNode
+	typ  exprType
+
+	re   *regexp.Regexp // regex()
+	glob glob.Glob      // glob()
+}
+
+// exprError is a parse or type error at a byte offset of the source.
+type exprError struct {
+	pos int
+	msg string
+}
+
+func (e *exprError) Error() string { return e.msg }
+
+func exprErrorf(pos int, format string, args ...any) *exprError {
+	return &exprError{pos: pos, msg: fmt.Sprintf(format, args...)}
+}
+
+// compileExpr parses and type-checks a when: expression.
+func compileExpr(x Expr) (*exprNode, error) {
+	if len(x.Source) > maxExprLength {
+		return nil, fmt.Errorf("expression longer than %d bytes", maxExprLength)
+	}
+	root, err := parseExpr(x.Source)
+	if err == nil {
+		err = checkExpr(root)
+	}
+	if err == nil && root.typ != tBool && root.typ != tDyn {
+		err = exprErrorf(root.pos, "expression is %s, not bool", root.typ)
+	}
+	if err != nil {
+		if ee, ok := err.(*exprError); ok {
+			return nil, fmt.Errorf("%s: %s", x.position(ee.pos), ee.msg)
+		}
+		return nil, err
+	}
+	return root, nil
+}
+
+// ---- Lexer ----
+
+type exprTokKind int
+
+const (
+	exprTokEOF exprTokKind = iota
+	exprTokIdent
+	exprTokString
+	exprTokNumber
+	exprTokOp
+)
+
+type exprToken struct {
+	kind exprTokKind
+	pos  int
+	text string // Identifier, operator, or decoded string.
+	num  float64
+}
+
+func lexExpr(src string) ([]exprToken, error) {
+	var toks []exprToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || src[j] >= 'a' && src[j] <= 'z' || src[j] >= 'A' && src[j] <= 'Z' || src[j] >= '0' && src[j] <= '9') {
+				j++
+			}
+			toks = append(toks, exprToken{kind: exprTokIdent, pos: i, text: src[i:j]})
+			i = j
+		case c >= '0' && c <= '9':
+			j := i + 1
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.') {
+				j++
+			}
+			n, err := strconv.ParseFloat(src[i:j], 64)
+			if err != nil {
+				return nil, exprErrorf(i, "invalid number %q", src[i:j])
+			}
+			toks = append(toks, exprToken{kind: exprTokNumber, pos: i, num: n})
+			i = j
+		case c == '"' || c == '\'':
+			s, n, err := lexString(src[i:])
+			if err != nil {
+				return nil, exprErrorf(i, "%s", err)
+			}
+			toks = append(toks, exprToken{kind: exprTokString, pos: i, text: s})
+			i += n
+		default:
+			op := ""
+			for _, o := range []string{"&&", "||", "==", "!=", "<=", ">=", "(", ")", "[", "]", ".", ",", "!", "<", ">", "-"} {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				r, _ := utf8.DecodeRuneInString(src[i:])
+				if c == '=' || c == '&' || c == '|' {
+					return nil, exprErrorf(i, "unexpected %q (did you mean %q?)", string(c), strings.Repeat(string(c), 2))
+				}
+				return nil, exprErrorf(i, "unexpected character %q", r)
+			}
+			toks = append(toks, exprToken{kind: exprTokOp, pos: i, text: op})
+			i += len(op)
+		}
+	}
+	// Errors at the end point at the last character, not trailing newlines.
+	return append(toks, exprToken{kind: exprTokEOF, pos: len(strings.TrimRight(src, " \t\r\n"))}), nil
+}
+
+// lexString decodes a quoted string at the start of s and returns it and
+// the number of bytes consumed.
+func lexString(s string) (string, int, error) {
+	quote := s[0]
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == quote:
+			return b.String(), i + 1, nil
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case '\\', '"', '\'':
+				b.WriteByte(s[i])
+			default:
+				return "", 0, fmt.Errorf("unknown escape \\%c", s[i])
+			}
+		case c == '\n':
+			return "", 0, fmt.Errorf("unterminated string")
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+// ---- Parser ----
+//
+//	or      = and { "||" and }
+//	and     = cmp { "&&" cmp }
+//	cmp     = unary [ ("=="|"!="|"<"|"<="|">"|">="|"in") unary ]
+//	unary   = ("!"|"-") unary | postfix
+//	postfix = primary { "." ident [ "(" args ")" ] | "[" or "]" }
+//	primary = literal | ident [ "(" args ")" ] | "(" or ")" | "[" [ args ] "]"
+
+type exprParser struct {
+	toks  []exprToken
+	i     int
+	nodes int
+	depth int
+}
+
+func parseExpr(src string) (*exprNode, error) {
+	toks, err := lexExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{toks: toks}
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != exprTokEOF {
+		return nil, exprErrorf(t.pos, "unexpected %s", describeExprToken(t))
+	}
+	return n, nil
+}
+
+func describeExprToken(t exprToken) string {
+	switch t.kind {
+	case exprTokEOF:
+		return "end of expression"
+	case exprTokString:
+		return strconv.Quote(t.text)
+	case exprTokNumber:
+		return strconv.FormatFloat(t.num, 'g', -1, 64)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+func (p *exprParser) peek() exprToken { return p.toks[p.i] }
+
+func (p *exprParser) next() exprToken {
+	t := p.toks[p.i]
+	if t.kind != exprTokEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *exprParser) isOp(op string) bool {
+	t := p.peek()
+	return t.kind == exprTokOp && t.text == op
+}
+
+func (p *exprParser) expect(op string) error {
+	if !p.isOp(op) {
+		t := p.peek()
+		return exprErrorf(t.pos, "expected %q, found %s", op, describeExprToken(t))
+	}
+	p.next()
+	return nil
+}
+
+func (p *exprParser) node(n *exprNode) (*exprNode, error) {
+	p.nodes++
+	if p.nodes > maxExprNodes {
+		return nil, exprErrorf(n.pos, "expression has more than %d terms", maxExprNodes)
+	}
+	return n, nil
+}
+
+func (p *exprParser) or() (*exprNode, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > maxExprDepth {
+		return nil, exprErrorf(p.peek().pos, "expression nested deeper than %d levels", maxExprDepth)
+	}
+
+	left, err := p.and()
+	for err == nil && p.isOp("||") {
+		t := p.next()
+		var right *exprNode
+		if right, err = p.and(); err == nil {
+			left, err = p.node(&exprNode{kind: eBinary, pos: t.pos, name: "||", kids: []*exprNode{left, right}})
+		}
+	}
+	return left, err
+}
+
+func (p *exprParser) and() (*exprNode, error) {
+	left, err := p.cmp()
+	for err == nil && p.isOp("&&") {
+		t := p.next()
+		var right *exprNode
+		if right, err = p.cmp(); err == nil {
+			left, err = p.node(&exprNode{kind: eBinary, pos: t.pos, name: "&&", kids: []*exprNode{left, right}})
+		}
+	}
+	return left, err
+}
+
+func (p *exprParser) cmp() (*exprNode, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	op := ""
+	switch {
+	case t.kind == exprTokOp && (t.text == "==" || t.text == "!=" || t.text == "<" || t.text == "<=" || t.text == ">" || t.text == ">="):
+		op = t.text
+	case t.kind == exprTokIdent && t.text == "in":
+		op = "in"
+	default:
+		return left, nil
+	}
+	p.next()
+	right, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return p.node(&exprNode{kind: eBinary, pos: t.pos, name: op, kids: []*exprNode{left, right}})
+}
+
+func (p *exprParser) unary() (*exprNode, error) {
+	if p.isOp("!") || p.isOp("-") {
+		p.depth++
+		defer func() { p.depth-- }()
+		if p.depth > maxExprDepth {
+			return nil, exprErrorf(p.peek().pos, "expression nested deeper than %d levels", maxExprDepth)
+		}
+		t := p.next()
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return p.node(&exprNode{kind: eUnary, pos: t.pos, name: t.text, kids: []*exprNode{operand}})
+	}
+	return p.postfix()
+}
+
+func (p *exprParser) postfix() (*exprNode, error) {
+	n, err := p.primary()
+	for err == nil {
+		switch {
+		case p.isOp("."):
+			p.next()
+			t := p.next()
+			if t.kind != exprTokIdent {
+				return nil, exprErrorf(t.pos, "expected a field or method name after \".\", found %s", describeExprToken(t))
+			}
+			if p.isOp("(") {
+				var args []*exprNode
+				if args, err = p.args(")"); err == nil {
+					n, err = p.node(&exprNode{kind: eMethod, pos: t.pos, name: t.text, kids: append([]*exprNode{n}, args...)})
+				}
+				continue
+			}
+			n, err = p.node(&exprNode{kind: eMember, pos: t.pos, name: t.text, kids: []*exprNode{n}})
+		case p.isOp("["):
+			t := p.next()
+			var idx *exprNode
+			if idx, err = p.or(); err == nil {
+				if err = p.expect("]"); err == nil {
+					n, err = p.node(&exprNode{kind: eIndex, pos: t.pos, kids: []*exprNode{n, idx}})
+				}
+			}
+		default:
+			return n, nil
+		}
+	}
+	return nil, err
+}
+
+// args parses a comma-separated list after the opening "(" or "[".
+func (p *exprParser) args(end string) ([]*exprNode, error) {
+	p.next()
+	var out []*exprNode
+	if p.isOp(end) {
+		p.next()
+		return out, nil
+	}
+	for {
+		a, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+		if p.isOp(",") {
+			p.next()
+			continue
+		}
+		return out, p.expect(end)
+	}
+}
+
+func (p *exprParser) primary() (*exprNode, error) {
+	t := p.peek()
+	switch t.kind {
+	case exprTokString:
+		p.next()
+		return p.node(&exprNode{kind: eLiteral, pos: t.pos, val: t.text})
+	case exprTokNumber:
+		p.next()
+		return p.node(&exprNode{kind: eLiteral, pos: t.pos, val: t.num})
+	case exprTokIdent:
+		p.next()
+		switch t.text {
+		case "true", "false":
+			return p.node(&exprNode{kind: eLiteral, pos: t.pos, val: t.text == "true"})
+		case "null":
+			return p.node(&exprNode{kind: eLiteral, pos: t.pos, val: nil})
+		case "in":
+			return nil, exprErrorf(t.pos, "unexpected \"in\"")
+		}
+		if p.isOp("(") {
+			args, err := p.args(")")
+			if err != nil {
+				return nil, err
+			}
+			return p.node(&exprNode{kind: eCall, pos: t.pos, name: t.text, kids: args})
+		}
+		return p.node(&exprNode{kind: eIdent, pos: t.pos, name: t.text})
+	case exprTokOp:
+		switch t.text {
+		case "(":
+			p.next()
+			n, err := p.or()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		case "[":
+			elems, err := p.args("]")
+			if err != nil {
+				return nil, err
+			}
+			return p.node(&exprNode{kind: eList, pos: t.pos, kids: elems})
+		}
+	}
+	return nil, exprErrorf(t.pos, "unexpected %s", describeExprToken(t))
+}
+
+// ---- Type checker ----
+
+// exprVars are the variables an expression can read.
+var exprVars = map[string]exprType{
+	"tool": tString, "agent": tString, "provider": tString, "model": tString, "args": tDyn,
+}
+
+// accepts reports whether a value of type got can be used where want is
+// expected. dyn is checked at run time.
+func accepts(want, got exprType) bool {
+	return got == want || got == tDyn || want == tDyn
+}
+
+func checkExpr(n *exprNode) error {
+	// url.host(s) parses as a method on the identifier url.
+	if n.kind == eMethod && n.kids[0].kind == eIdent && n.kids[0].name == "url" {
+		if n.name != "host" {
+			return exprErrorf(n.pos, "unknown function url.%s (want url.host)", n.name)
+		}
+		n.kind, n.name, n.kids = eCall, "url.host", n.kids[1:]
+	}
+
+	for _, k := range n.kids {
+		if err := checkExpr(k); err != nil {
+			return err
+		}
+	}
+
+	switch n.kind {
+	case eLiteral:
+		switch n.val.(type) {
+		case string:
+			n.typ = tString
+		case float64:
+			n.typ = tNumber
+		case bool:
+			n.typ = tBool
+		default:
+			n.typ = tNull
+		}
+
+	case eIdent:
+		t, ok := exprVars[n.name]
+		if !ok {
+			if n.name == "url" {
+				return exprErrorf(n.pos, "url is only used as url.host(...)")
+			}
+			return exprErrorf(n.pos, "undefined variable %q (want tool, agent, provider, model, or args)", n.name)
+		}
+		n.typ = t
+
+	case eMember:
+		if t := n.kids[0].typ; t != tDyn && t != tMap {
+			return exprErrorf(n.pos, "%s has no field %q", t, n.name)
+		}
+		n.typ = tDyn
+
+	case eIndex:
+		recv, idx := n.kids[0].typ, n.kids[1].typ
+		if recv != tDyn && recv != tMap && recv != tList {
+			return exprErrorf(n.pos, "cannot index %s", recv)
+		}
+		if idx != tDyn && idx != tString && idx != tNumber {
+			return exprErrorf(n.kids[1].pos, "index must be a string or number, not %s", idx)
+		}
+		n.typ = tDyn
+
+	case eList:
+		n.typ = tList
+
+	case eUnary:
+		want := tBool
+		if n.name == "-" {
+			want = tNumber
+		}
+		if !accepts(want, n.kids[0].typ) {
+			return exprErrorf(n.pos, "%s needs a %s, not %s", n.name, want, n.kids[0].typ)
+		}
+		n.typ = want
+
+	case eBinary:
+		return checkBinary(n)
+
+	case eMethod:
+		return checkMethod(n)
+
+	case eCall:
+		return checkCall(n)
+	}
+	return nil
+}
+
+func checkBinary(n *exprNode) error {
+	l, r := n.kids[0].typ, n.kids[1].typ
+	n.typ = tBool
+	switch n.name {
+	case "&&", "||":
+		for _, k := range n.kids {
+			if !accepts(tBool, k.typ) {
+				return exprErrorf(k.pos, "%s needs bool operands, not %s", n.name, k.typ)
+			}
+		}
+	case "==", "!=":
+		if l != r && l != tDyn && r != tDyn && l != tNull && r != tNull {
+			return exprErrorf(n.pos, "cannot compare %s %s %s", l, n.name, r)
+		}
+	case "<", "<=", ">", ">=":
+		ok := (accepts(tNumber, l) && accepts(tNumber, r)) || (accepts(tString, l) && accepts(tString, r))
+		if !ok || l == tBool || r == tBool || l == tNull || r == tNull {
+			return exprErrorf(n.pos, "cannot order %s %s %s", l, n.name, r)
+		}
+	case "in":
+		if r != tList && r != tMap && r != tDyn {
+			return exprErrorf(n.kids[1].pos, "in needs a list or object on the right, not %s", r)
+		}
+	}
+	return nil
+}
+
+// exprMethods are the string methods: argument types and result type.
+var exprMethods = map[string]struct {
+	args   []exprType
+	result exprType
+}{
+	"contains":   {[]exprType{tString}, tBool},
+	"startsWith": {[]exprType{tString}, tBool},
+	"endsWith":   {[]exprType{tString}, tBool},
+	"lower":      {nil, tString},
+}
+
+func checkMethod(n *exprNode) error {
+	m, ok := exprMethods[n.name]
+	if !ok {
+		return exprErrorf(n.pos, "unknown method %q (want contains, startsWith, endsWith, or lower)", n.name)
+	}
+	if recv := n.kids[0].typ; !accepts(tString, recv) {
+		return exprErrorf(n.pos, "%s is a string method, called on %s", n.name, recv)
+	}
+	if err := checkArgs(n, n.kids[1:], m.args); err != nil {
+		return err
+	}
+	n.typ = m.result
+	return nil
+}
+
+func checkCall(n *exprNode) error {
+	switch n.name {
+	case "len":
+		if len(n.kids) != 1 {
+			return exprErrorf(n.pos, "len takes 1 argument, got %d", len(n.kids))
+		}
+		if t := n.kids[0].typ; t != tDyn && t != tString && t != tList && t != tMap {
+			return exprErrorf(n.kids[0].pos, "len needs a string, list or object, not %s", t)
+		}
+		n.typ = tNumber
+	case "url.host":
+		if err := checkArgs(n, n.kids, []exprType{tString}); err != nil {
+			return err
+		}
+		n.typ = tString
+	case "glob", "regex":
+		if err := checkArgs(n, n.kids, []exprType{tString, tString}); err != nil {
+			return err
+		}
+		pat := n.kids[1]
+		s, ok := pat.val.(string)
+		if pat.kind != eLiteral || !ok {
+			return exprErrorf(pat.pos, "%s pattern must be a string literal", n.name)
+		}
+		var err error
+		if n.name == "glob" {
+			n.glob, err = glob.Compile(s)
+		} else {
+			n.re, err = regexp.Compile(s)
+		}
+		if err != nil {
+			return exprErrorf(pat.pos, "invalid %s pattern: %v", n.name, err)
+		}
+		n.typ = tBool
+	default:
+		return exprErrorf(n.pos, "unknown function %q (want len, glob, regex, or url.host)", n.name)
+	}
+	return nil
+}
+
+func checkArgs(n *exprNode, args []*exprNode, want []exprType) error {
+	if len(args) != len(want) {
+		return exprErrorf(n.pos, "%s takes %d argument(s), got %d", n.name, len(want), len(args))
+	}
+	for i, a := range args {
+		if !accepts(want[i], a.typ) {
+			return exprErrorf(a.pos, "%s argument %d must be a %s, not %s", n.name, i+1, want[i], a.typ)
+		}
+	}
+	return nil
+}
+
+// ---- Evaluation ----
+
+// exprEnv is what an expression reads.
+type exprEnv struct {
+	tool, agent, provider, model string
+	args                         map[string]any
+}
+
+// errExprType is a run-time type mismatch on a dyn value.
+type errExprType struct{ msg string }
+
+func (e errExprType) Error() string { return e.msg }
+
+func typeErr(format string, args ...any) error { return errExprType{fmt.Sprintf(format, args...)} }
+
+// evalBool evaluates a compiled expression as a condition. Errors, and
+// non-bool results, are false.
+func (n *exprNode) evalBool(env *exprEnv) (bool, error) {
+	v, err := n.eval(env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, typeErr("expression is %s, not bool", valueType(v))
+	}
+	return b, nil
+}
+
+func valueType(v any) exprType {
+	switch v.(type) {
+	case string:
+		return tString
+	case float64, int:
+		return tNumber
+	case bool:
+		return tBool
+	case []any:
+		return tList
+	case map[string]any:
+		return tMap
+	case nil:
+		return tNull
+	}
+	return tDyn
+}
+
+func (n *exprNode) eval(env *exprEnv) (any, error) {
+	switch n.kind {
+	case eLiteral:
+		return n.val, nil
+
+	case eIdent:
+		switch n.name {
+		case "tool":
+			return env.tool, nil
+		case "agent":
+			return env.agent, nil
+		case "provider":
+			return env.provider, nil
+		case "model":
+			return env.model, nil
+		}
+		if env.args == nil {
+			return nil, nil
+		}
+		return env.args, nil
+
+	case eList:
+		out := make([]any, len(n.kids))
+		for i, k := range n.kids {
+			v, err := k.eval(env)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+
+	case eMember:
+		recv, err := n.kids[0].eval(env)
+		if err != nil {
+			return nil, err
+		}
+		if recv == nil {
+			return nil, nil
+		}
+		m, ok := recv.(map[string]any)
+		if !ok {
+			return nil, typeErr("%s has no field %q", valueType(recv), n.name)
+		}
+		return m[n.name], nil
+
+	case eIndex:
+		recv, err := n.kids[0].eval(env)
+		if err != nil {
+			return nil, err
+		}
+		idx, err := n.kids[1].eval(env)
+		if err != nil {
+			return nil, err
+		}
+		switch r := recv.(type) {
+		case nil:
+			return nil, nil
+		case map[string]any:
+			if k, ok := idx.(string); ok {
+				return r[k], nil
+			}
+		case []any:
+			if f, ok := idx.(float64); ok {
+				i := int(f)
+				if float64(i) != f || i < 0 || i >= len(r) {
+					return nil, nil
+				}
+				return r[i], nil
+			}
+		}
+		return nil, typeErr("cannot index %s with %s", valueType(recv), valueType(idx))
+
+	case eUnary:
+		v, err := n.kids[0].eval(env)
+		if err != nil {
+			return nil, err
+		}
+		if n.name == "!" {
+			b, ok := v.(bool)
+			if !ok {
+				return nil, typeErr("! needs a bool, not %s", valueType(v))
+			}
+			return !b, nil
+		}
+		f, ok := v.(float64)
+		if !ok {
+			return nil, typeErr("- needs a number, not %s", valueType(v))
+		}
+		return -f, nil
+
+	case eBinary:
+		return n.evalBinary(env)
+
+	case eMethod:
+		return n.evalMethod(env)
+
+	case eCall:
+		return n.evalCall(env)
+	}
+	return nil, fmt.Errorf("unknown expression node")
+}
+
+func (n *exprNode) evalBinary(env *exprEnv) (any, error) {
+	l, err := n.kids[0].eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	// && and || short-circuit.
+	if n.name == "&&" || n.name == "||" {
+		lb, ok := l.(bool)
+		if !ok {
+			return nil, typeErr("%s needs bool operands, not %s", n.name, valueType(l))
+		}
+		if lb == (n.name == "||") {
+			return lb, nil
+		}
+		return n.kids[1].evalBool(env)
+	}
+
+	r, err := n.kids[1].eval(env)
+	if err != nil {
+		return nil, err
+	}
+	switch n.name {
+	case "==":
+		return exprEqual(l, r), nil
+	case "!=":
+		return !exprEqual(l, r), nil
+	case "in":
+		switch c := r.(type) {
+		case []any:
+			for _, e := range c {
+				if exprEqual(l, e) {
+					return true, nil
+				}
+			}
+			return false, nil
+		case map[string]any:
+			k, ok := l.(string)
+			if !ok {
+				return false, nil
+			}
+			_, found := c[k]
+			return found, nil
+		}
+		return nil, typeErr("in needs a list or object, not %s", valueType(r))
+	}
+
+	// Ordering.
+	var cmp int
+	switch lv := l.(type) {
+	case float64:
+		rv, ok := r.(float64)
+		if !ok {
+			return nil, typeErr("cannot order number %s %s", n.name, valueType(r))
+		}
+		switch {
+		case lv < rv:
+			cmp = -1
+		case lv > rv:
+			cmp = 1
+		}
+	case string:
+		rv, ok := r.(string)
+		if !ok {
+			return nil, typeErr("cannot order string %s %s", n.name, valueType(r))
+		}
+		cmp = strings.Compare(lv, rv)
+	default:
+		return nil, typeErr("cannot order %s", valueType(l))
+	}
+	switch n.name {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	}
+	return cmp >= 0, nil
+}
+
+// exprEqual compares two values. Numbers compare by value; lists and
+// objects deeply.
+func exprEqual(a, b any) bool {
+	switch av := a.(type) {
+	case string, float64, bool, nil:
+		return a == b
+	default:
+		return reflect.DeepEqual(av, b)
+	}
+}
+
+func (n *exprNode) evalMethod(env *exprEnv) (any, error) {
+	recv, err := n.kids[0].eval(env)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := recv.(string)
+	if !ok {
+		return nil, typeErr("%s called on %s", n.name, valueType(recv))
+	}
+	if n.name == "lower" {
+		return strings.ToLower(s), nil
+	}
+	av, err := n.kids[1].eval(env)
+	if err != nil {
+		return nil, err
+	}
+	arg, ok := av.(string)
+	if !ok {
+		return nil, typeErr("%s needs a string argument, not %s", n.name, valueType(av))
+	}
+	switch n.name {
+	case "contains":
+		return strings.Contains(s, arg), nil
+	case "startsWith":
+		return strings.HasPrefix(s, arg), nil
+	}
+	return strings.HasSuffix(s, arg), nil
+}
+
+func (n *exprNode) evalCall(env *exprEnv) (any, error) {
+	v, err := n.kids[0].eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if n.name == "len" {
+		switch x := v.(type) {
+		case string:
+			return float64(utf8.RuneCountInString(x)), nil
+		case []any:
+			return float64(len(x)), nil
+		case map[string]any:
+			return float64(len(x)), nil
+		}
+		return nil, typeErr("len of %s", valueType(v))
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return nil, typeErr("%s needs a string, not %s", n.name, valueType(v))
+	}
+	switch n.name {
+	case "glob":
+		return n.glob.Match(s), nil
+	case "regex":
+		return n.re.MatchString(s), nil
+	}
+	u, ok := parseCallURL(s)
+	if !ok {
+		return "", nil
+	}
+	return u.host, nil
+}
@@ -0,0 +1,229 @@
+package engine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// hostList is a url_host_in_list file, loaded into a hostSet. The file
+// is re-read only when its size or modification time changes, so reloading
+// rules.yaml doesn't re-parse large lists that didn't change.
+type hostList struct {
+	path    string // Resolved path.
+	size    int64
+	modTime time.Time
+	hosts   *hostSet
+	entries int // Hosts and CIDRs loaded.
+	skipped int // Lines that were neither.
+}
+
+// hostsFileNames are the names hosts files map for the local machine.
+// Blocklists in hosts-file format start with them; they aren't entries.
+var hostsFileNames = map[string]bool{
+	"localhost": true, "localhost.localdomain": true, "local": true,
+	"broadcasthost": true, "ip6-localhost": true, "ip6-loopback": true,
+	"ip6-localnet": true, "ip6-mcastprefix": true, "ip6-allnodes": true,
+	"ip6-allrouters": true, "ip6-allhosts": true, "0.0.0.0": true,
+}
+
+// parseHostList reads a host list. Each line is one of:
+//
+//	example.com              a domain and its subdomains (as in url_host)
+//	*.example.com            subdomains only
+//	0.0.0.0 a.com b.com      hosts-file format: the names after the address
+//	203.0.113.0/24           a CIDR
+//	203.0.113.7              an IP
+//
+// Text after # is a comment. Lines that are none of these are counted in
+// skipped rather than failing the load: one bad line in a 100k-entry feed
+// shouldn't take the whole list down.
+func parseHostList(r io.Reader) (hs *hostSet, entries, skipped int, err error) {
+	hs = &hostSet{domains: make(map[string]bool)}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 0:
+			continue
+		case len(fields) == 1:
+			if !validListEntry(fields[0]) || hs.add(fields[0]) != nil {
+				skipped++
+				continue
+			}
+			entries++
+		default:
+			if _, err := netip.ParseAddr(fields[0]); err != nil {
+				skipped++
+				continue
+			}
+			for _, name := range fields[1:] {
+				name = strings.ToLower(name)
+				if hostsFileNames[name] {
+					continue
+				}
+				if !validListEntry(name) || hs.add(name) != nil {
+					skipped++
+					continue
+				}
+				entries++
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, 0, 0, err
+	}
+	hs.finish()
+	return hs, entries, skipped, nil
+}
+
+// validListEntry reports whether a list word only has the characters of a
+// host, wildcard, IP or CIDR.
+func validListEntry(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '.', c == '-', c == '_', c == '*', c == ':', c == '/', c == '[', c == ']':
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
+// loadHostLists loads every url_host_in_list file the rules name. Relative
+// names resolve against dir, the directory of rules.yaml. Lists in prev
+// whose file hasn't changed are reused as is.
+func loadHostLists(dir string, rules []Rule, prev map[string]*hostList) (map[string]*hostList, error) {
+	lists := make(map[string]*hostList)
+	for i := range rules {
+		for _, name := range rules[i].hostListNames() {
+			if _, ok := lists[name]; ok {
+				continue
+			}
+			l, err := loadHostList(resolveListPath(dir, name), prev[name])
+			if err != nil {
+				return nil, fmt.Errorf("rule %q: url_host_in_list: %w", rules[i].Name, err)
+			}
+			lists[name] = l
+		}
+	}
+	return lists, nil
+}
+
+// resolveListPath returns the file a url_host_in_list name refers to.
+func resolveListPath(dir, name string) string {
+	if filepath.IsAbs(name) {
+		return filepath.Clean(name)
+	}
+	return filepath.Join(dir, name)
+}
+
+// loadHostList reads one list file, or returns prev if it is the same file
+// and hasn't changed since.
+func loadHostList(path string, prev *hostList) (*hostList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if prev != nil && prev.path == path && prev.size == info.Size() && prev.modTime.Equal(info.ModTime()) {
+		return prev, nil
+	}
+
+	start := time.Now()
+	hs, entries, skipped, err := parseHostList(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	slog.Info("host list loaded", "path", path, "entries", entries, "skipped", skipped, "took", time.Since(start))
+	return &hostList{path: path, size: info.Size(), modTime: info.ModTime(), hosts: hs, entries: entries, skipped: skipped}, nil
+}
+
+// hostListNames returns the url_host_in_list names the rule uses, in its
+// match block and sequence steps.
+func (r *Rule) hostListNames() []string {
+	names := r.Match.hostListNames(nil)
+	for i := range r.Sequence {
+		names = r.Sequence[i].RuleMatch.hostListNames(names)
+	}
+	return names
+}
+
+func (m *RuleMatch) hostListNames(out []string) []string {
+	out = append(out, m.URLHostInList...)
+	for i := range m.All {
+		out = m.All[i].hostListNames(out)
+	}
+	for i := range m.Any {
+		out = m.Any[i].hostListNames(out)
+	}
+	if m.Not != nil {
+		out = m.Not.hostListNames(out)
+	}
+	return out
+}
+
+// ListFiles returns the host list files the current rules use, for the
+// config watcher.
+func (e *Engine) ListFiles() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	files := make([]string, 0, len(e.lists))
+	for _, l := range e.lists {
+		files = append(files, l.path)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// addrRange is an inclusive range of addresses of one family.
+type addrRange struct{ lo, hi netip.Addr }
+
+// prefixRange returns the addresses a masked prefix covers.
+func prefixRange(p netip.Prefix) addrRange {
+	b := p.Addr().AsSlice()
+	for i := range b {
+		if n := p.Bits() - 8*i; n < 8 {
+			b[i] |= 0xff >> max(n, 0)
+		}
+	}
+	hi, _ := netip.AddrFromSlice(b)
+	return addrRange{p.Addr(), hi}
+}
+
+// finish sorts and merges the set's IP ranges so match can binary-search
+// them. Must be called after the last add.
+func (hs *hostSet) finish() {
+	sort.Slice(hs.ranges, func(i, j int) bool { return hs.ranges[i].lo.Less(hs.ranges[j].lo) })
+	merged := hs.ranges[:0]
+	for _, r := range hs.ranges {
+		if n := len(merged); n > 0 && r.lo.Compare(merged[n-1].hi) <= 0 {
+			if merged[n-1].hi.Less(r.hi) {
+				merged[n-1].hi = r.hi
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	hs.ranges = merged
+}
+
+// matchIP reports whether ip is in one of the set's ranges.
+func (hs *hostSet) matchIP(ip netip.Addr) bool {
+	i := sort.Search(len(hs.ranges), func(i int) bool { return hs.ranges[i].lo.Compare(ip) > 0 })
+	return i > 0 && hs.ranges[i-1].hi.Compare(ip) >= 0
+}
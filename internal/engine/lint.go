@@ -0,0 +1,318 @@
+package engine
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+// Lint check names, reported in LintIssue.Check.
+const (
+	LintShadowed      = "shadowed"       // An earlier rule matches every call this one does.
+	LintDuplicateName = "duplicate_name" // Another rule has the same name.
+	LintEmptyMatch    = "empty_match"    // No conditions: matches every tool call.
+	LintUnknownAgent  = "unknown_agent"  // Agent ID not in the registry.
+	LintEmptyRegex    = "empty_regex"    // Regex matches the empty string.
+)
+
+// LintIssue is one problem found by Lint. Serialized by /api/rules/lint.
+type LintIssue struct {
+	Rule    string `json:"rule"`
+	Builtin bool   `json:"builtin,omitempty"`
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+// SetAgentSource sets the function Lint uses to learn which agent IDs
+// exist (normally the agent registry). Without one, agent IDs are not
+// checked.
+func (e *Engine) SetAgentSource(fn func() []string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.agentSource = fn
+}
+
+// Lint checks the active rule set for rules that can never fire or match
+// far more than intended. It never changes what the engine does.
+func (e *Engine) Lint() []LintIssue {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.lintUnlocked()
+}
+
+// logLint logs every lint issue as a warning. Caller must hold the mutex.
+func (e *Engine) logLint() {
+	for _, issue := range e.lintUnlocked() {
+		slog.Warn("rules lint", "rule", issue.Rule, "check", issue.Check, "message", issue.Message)
+	}
+}
+
+// lintUnlocked runs every check. Caller must hold the mutex.
+func (e *Engine) lintUnlocked() []LintIssue {
+	var issues []LintIssue
+	add := func(r *Rule, check, format string, args ...any) {
+		issues = append(issues, LintIssue{
+			Rule: r.Name, Builtin: r.Builtin, Check: check, Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	var known map[string]bool
+	if e.agentSource != nil {
+		known = make(map[string]bool)
+		for _, id := range e.agentSource() {
+			known[id] = true
+		}
+		if len(known) == 0 {
+			known = nil // No agent has connected yet; nothing to compare against.
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i := range e.rules {
+		r := &e.rules[i]
+		if r.jail != nil {
+			continue // Synthesized per agent; checked via agents: below.
+		}
+
+		if seen[r.Name] {
+			add(r, LintDuplicateName, "another rule is also named %q; remove by name affects the first", r.Name)
+		}
+		seen[r.Name] = true
+
+		if r.Match.isEmpty() && r.seq == nil {
+			add(r, LintEmptyMatch, "match has no conditions, so the rule applies to every tool call")
+		}
+
+		blocks := []*RuleMatch{&r.Match}
+		for i := range r.Sequence {
+			blocks = append(blocks, &r.Sequence[i].RuleMatch)
+		}
+		for _, block := range blocks {
+			walkMatch(block, func(m *RuleMatch) {
+				for _, f := range matchRegexes(m) {
+					if re, err := regexp.Compile(f.pattern); err == nil && re.MatchString("") {
+						add(r, LintEmptyRegex, "%s %q matches the empty string, so it matches any value", f.field, f.pattern)
+					}
+				}
+				if known != nil && m.Agent != "" && !known[m.Agent] {
+					add(r, LintUnknownAgent, "agent %q is not in the agent registry", m.Agent)
+				}
+			})
+		}
+
+		if e.monitor {
+			continue // Nothing is enforced, so nothing is shadowed.
+		}
+		for j := 0; j < i; j++ {
+			earlier := &e.rules[j]
+			if !shadowsRule(earlier) || !matchCovers(&earlier.Match, &r.Match) {
+				continue
+			}
+			kind := "custom"
+			if earlier.Builtin {
+				kind = "built-in"
+			}
+			add(r, LintShadowed, "never fires: %s rule %q (%s) matches every call it does and is evaluated first",
+				kind, earlier.Name, earlier.Action)
+			break
+		}
+	}
+
+	if known != nil {
+		ids := make([]string, 0, len(e.agents))
+		for id := range e.agents {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			if !known[id] {
+				issues = append(issues, LintIssue{
+					Rule: "agents." + id, Check: LintUnknownAgent,
+					Message: fmt.Sprintf("agent %q is not in the agent registry", id),
+				})
+			}
+		}
+	}
+	return issues
+}
+
+// shadowsRule reports whether a rule always ends evaluation when it
+// matches. Monitor rules, rate limits, jails and sensitive allow rules
+// let calls fall through, and a sequence rule's match block says nothing
+// about what it matches.
+func shadowsRule(r *Rule) bool {
+	return r.Mode != ModeMonitor && r.rate == nil && r.jail == nil && r.seq == nil &&
+		!(r.Sensitive && r.Action == "allow")
+}
+
+// walkMatch calls fn for a match block and every nested block.
+func walkMatch(m *RuleMatch, fn func(*RuleMatch)) {
+	fn(m)
+	for i := range m.All {
+		walkMatch(&m.All[i], fn)
+	}
+	for i := range m.Any {
+		walkMatch(&m.Any[i], fn)
+	}
+	if m.Not != nil {
+		walkMatch(m.Not, fn)
+	}
+}
+
+// regexField is a regex pattern and the field it is set on.
+type regexField struct {
+	field, pattern string
+}
+
+// matchRegexes returns the regex patterns set on one match block (not
+// nested blocks), in field order.
+func matchRegexes(m *RuleMatch) []regexField {
+	var out []regexField
+	if m.CommandRegex != "" {
+		out = append(out, regexField{"command_regex", m.CommandRegex})
+	}
+	if m.URLRegex != "" {
+		out = append(out, regexField{"url_regex", m.URLRegex})
+	}
+	if m.ArgvRegex != "" {
+		out = append(out, regexField{"argv_regex", m.ArgvRegex})
+	}
+	for _, a := range m.Args {
+		if s, ok := a.Value.(string); ok && a.Op == "regex" {
+			out = append(out, regexField{"args " + a.Path, s})
+		}
+	}
+	return out
+}
+
+// matchCovers reports whether a matches every tool call b matches. It is
+// conservative: false means "not provably", so only certain shadowing is
+// reported. Each condition in a must be implied by one in b.
+func matchCovers(a, b *RuleMatch) bool {
+	if !listCovers(a.Tool, b.Tool, strings.EqualFold) ||
+		!listCovers(a.Action, b.Action, strings.EqualFold) ||
+		!listCovers(a.Binary, b.Binary, func(x, y string) bool { return x == y }) {
+		return false
+	}
+	if a.Agent != "" && a.Agent != b.Agent {
+		return false
+	}
+	if !listCovers(a.Path, b.Path, globCovers) {
+		return false
+	}
+	// b matches when one of its substrings is present; a's must then be
+	// present too, so each of b's must contain one of a's.
+	if !listCovers(a.ArgContains, b.ArgContains, func(x, y string) bool {
+		return strings.Contains(strings.ToLower(y), strings.ToLower(x))
+	}) {
+		return false
+	}
+	if (a.CommandRegex != "" && a.CommandRegex != b.CommandRegex) ||
+		(a.URLRegex != "" && a.URLRegex != b.URLRegex) ||
+		(a.ArgvRegex != "" && a.ArgvRegex != b.ArgvRegex) {
+		return false
+	}
+	if !listCovers(a.URLHost, b.URLHost, hostCovers) ||
+		!listCovers(a.URLHostInList, b.URLHostInList, func(x, y string) bool { return x == y }) ||
+		!listCovers(a.URLScheme, b.URLScheme, strings.EqualFold) ||
+		!listCovers(a.URLPort, b.URLPort, func(x, y string) bool { return x == y }) ||
+		!listCovers(a.URLPath, b.URLPath, globCovers) {
+		return false
+	}
+	if a.URLPrivate != nil && (b.URLPrivate == nil || *a.URLPrivate != *b.URLPrivate) {
+		return false
+	}
+	if a.Tainted != nil && (b.Tainted == nil || *a.Tainted != *b.Tainted) {
+		return false
+	}
+	if a.UndeclaredTool != nil && (b.UndeclaredTool == nil || *a.UndeclaredTool != *b.UndeclaredTool) {
+		return false
+	}
+	if a.SchemaInvalid != nil && (b.SchemaInvalid == nil || *a.SchemaInvalid != *b.SchemaInvalid) {
+		return false
+	}
+	if len(a.ContainsSecret) > 0 && (len(b.ContainsSecret) == 0 || !a.ContainsSecret.covers(b.ContainsSecret)) {
+		return false
+	}
+	if len(a.ContainsPII) > 0 && (len(b.ContainsPII) == 0 || !a.ContainsPII.covers(b.ContainsPII)) {
+		return false
+	}
+	if a.When.Source != "" && a.When.Source != b.When.Source {
+		return false
+	}
+	for _, arg := range a.Args {
+		found := false
+		for _, other := range b.Args {
+			if reflect.DeepEqual(arg, other) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if (a.All != nil && !reflect.DeepEqual(a.All, b.All)) ||
+		(a.Any != nil && !reflect.DeepEqual(a.Any, b.Any)) ||
+		(a.Not != nil && !reflect.DeepEqual(a.Not, b.Not)) {
+		return false
+	}
+	return true
+}
+
+// listCovers reports whether an OR-list condition a is implied by b:
+// a is unset, or b is set and every value in b is covered by some value
+// in a.
+func listCovers(a, b []string, covers func(aVal, bVal string) bool) bool {
+	if len(a) == 0 {
+		return true
+	}
+	if len(b) == 0 {
+		return false
+	}
+	for _, bv := range b {
+		found := false
+		for _, av := range a {
+			if covers(av, bv) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// hostCovers reports whether url_host entry a matches every host entry b
+// does: the same entry, or a parent domain of it. CIDRs only cover
+// themselves.
+func hostCovers(a, b string) bool {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	if a == b || b == "*."+a {
+		return true
+	}
+	if strings.Contains(a, "/") || strings.Contains(b, "/") {
+		return false
+	}
+	return strings.HasSuffix(strings.TrimPrefix(b, "*."), "."+strings.TrimPrefix(a, "*."))
+}
+
+// globCovers reports whether glob pattern a matches everything pattern b
+// does: they are identical, or b is a literal path that a matches.
+func globCovers(a, b string) bool {
+	if a == b {
+		return true
+	}
+	if strings.ContainsAny(b, "*?[]{}") {
+		return false
+	}
+	g, err := glob.Compile(a)
+	return err == nil && g.Match(b)
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/ctrlai/ctrlai/internal/extractor"
 	"github.com/gobwas/glob"
@@ -13,58 +14,397 @@ import (
 // compiledMatcher holds pre-compiled patterns for a rule.
 // Compiling regex and glob patterns once at load time keeps per-evaluation
 // cost under the 50us target (design doc Section 17).
+//
+// Nested all/any/not blocks get their own compiledMatcher; all and any are
+// index-aligned with RuleMatch.All and RuleMatch.Any.
 type compiledMatcher struct {
 	commandRegex *regexp.Regexp
 	urlRegex     *regexp.Regexp
 	pathGlobs    []glob.Glob
+	binaryGlobs  []glob.Glob
+	argvRegex    *regexp.Regexp
+	args         []compiledArg
+	tainted      bool               // This block or a nested one has a tainted: condition.
+	secrets      []detectorSelector // contains_secret fields here and in nested all/any blocks.
+	pii          []detectorSelector // contains_pii fields, likewise.
+	urlHosts     *hostSet
+	urlPorts     []portRange
+	urlPathGlobs []glob.Glob
+	when         *exprNode
+
+	all []*compiledMatcher
+	any []*compiledMatcher
+	not *compiledMatcher
 }
 
+// maxMatchDepth bounds all/any/not nesting so a pathological rules file
+// can't blow the stack or make evaluation arbitrarily slow.
+const maxMatchDepth = 16
+
 // compileMatcher pre-compiles all pattern matchers for a rule.
-// Returns an error if any regex or glob pattern is invalid.
+// Returns an error if any regex or glob pattern is invalid, if a nested
+// all/any/not block is malformed, or if the rule's action is not one the
+// proxy knows how to enforce.
 func compileMatcher(r *Rule) error {
 	r.compiled = &compiledMatcher{}
 
-	if r.Match.CommandRegex != "" {
-		re, err := regexp.Compile(r.Match.CommandRegex)
+	switch r.Action {
+	case "", "allow", "block", "ask", "redact", "rewrite", "delegate":
+	default:
+		return fmt.Errorf("rule %q: unknown action %q (want allow, block, ask, redact, rewrite, or delegate)", r.Name, r.Action)
+	}
+
+	switch r.Mode {
+	case "", ModeEnforce, ModeMonitor:
+	default:
+		return fmt.Errorf("rule %q: unknown mode %q (want enforce or monitor)", r.Name, r.Mode)
+	}
+
+	r.rate = nil
+	if r.RateLimit != nil {
+		spec, err := compileRateLimit(r.Name, r.RateLimit)
 		if err != nil {
-			return fmt.Errorf("rule %q: invalid command_regex: %w", r.Name, err)
+			return err
 		}
-		r.compiled.commandRegex = re
+		r.rate = spec
+	}
+
+	c, err := compileMatch(r.Name, "", &r.Match, 0)
+	if err != nil {
+		return err
 	}
+	r.compiled = c
 
-	if r.Match.URLRegex != "" {
-		re, err := regexp.Compile(r.Match.URLRegex)
+	// redact rewrites what the detectors found, so it needs one.
+	if r.Action == "redact" && len(c.secrets) == 0 && len(c.pii) == 0 {
+		return fmt.Errorf("rule %q: action redact needs contains_pii or contains_secret in match", r.Name)
+	}
+
+	r.patches = nil
+	switch {
+	case r.Action == "rewrite" && len(r.Rewrite) == 0:
+		return fmt.Errorf("rule %q: action rewrite needs a rewrite list", r.Name)
+	case r.Action != "rewrite" && len(r.Rewrite) > 0:
+		return fmt.Errorf("rule %q: rewrite is only used with action rewrite", r.Name)
+	case len(r.Rewrite) > 0:
+		patches, err := compilePatches(r.Rewrite)
 		if err != nil {
-			return fmt.Errorf("rule %q: invalid url_regex: %w", r.Name, err)
+			return fmt.Errorf("rule %q: %w", r.Name, err)
 		}
-		r.compiled.urlRegex = re
+		r.patches = patches
 	}
 
-	for _, p := range r.Match.Path {
+	r.seq = nil
+	if r.Sequence != nil {
+		spec, err := compileSequence(r)
+		if err != nil {
+			return err
+		}
+		r.seq = spec
+	}
+	return nil
+}
+
+// compileMatch compiles one match block and, recursively, its nested
+// all/any/not blocks. where is the block's location inside the rule
+// (e.g. "match.any[1].not") and is empty for the top-level block.
+func compileMatch(ruleName, where string, m *RuleMatch, depth int) (*compiledMatcher, error) {
+	prefix := fmt.Sprintf("rule %q: ", ruleName)
+	if where != "" {
+		prefix = fmt.Sprintf("rule %q: %s: ", ruleName, where)
+	}
+	if depth > maxMatchDepth {
+		return nil, fmt.Errorf("%smatch nesting deeper than %d levels", prefix, maxMatchDepth)
+	}
+	if depth > 0 && m.isEmpty() {
+		return nil, fmt.Errorf("%sempty match block", prefix)
+	}
+
+	c := &compiledMatcher{}
+
+	if m.CommandRegex != "" {
+		re, err := regexp.Compile(m.CommandRegex)
+		if err != nil {
+			return nil, fmt.Errorf("%sinvalid command_regex: %w", prefix, err)
+		}
+		c.commandRegex = re
+	}
+
+	if m.URLRegex != "" {
+		re, err := regexp.Compile(m.URLRegex)
+		if err != nil {
+			return nil, fmt.Errorf("%sinvalid url_regex: %w", prefix, err)
+		}
+		c.urlRegex = re
+	}
+
+	for _, p := range m.Path {
 		g, err := glob.Compile(p)
 		if err != nil {
-			return fmt.Errorf("rule %q: invalid path glob %q: %w", r.Name, p, err)
+			return nil, fmt.Errorf("%sinvalid path glob %q: %w", prefix, p, err)
 		}
-		r.compiled.pathGlobs = append(r.compiled.pathGlobs, g)
+		c.pathGlobs = append(c.pathGlobs, g)
 	}
 
-	return nil
+	for _, b := range m.Binary {
+		g, err := glob.Compile(b)
+		if err != nil {
+			return nil, fmt.Errorf("%sinvalid binary pattern %q: %w", prefix, b, err)
+		}
+		c.binaryGlobs = append(c.binaryGlobs, g)
+	}
+
+	if m.ArgvRegex != "" {
+		re, err := regexp.Compile(m.ArgvRegex)
+		if err != nil {
+			return nil, fmt.Errorf("%sinvalid argv_regex: %w", prefix, err)
+		}
+		c.argvRegex = re
+	}
+
+	for i, a := range m.Args {
+		ca, err := compileArgMatcher(a)
+		if err != nil {
+			return nil, fmt.Errorf("%sargs[%d]: %w", prefix, i, err)
+		}
+		c.args = append(c.args, ca)
+	}
+
+	if err := compileURLMatch(m, c); err != nil {
+		return nil, fmt.Errorf("%s%w", prefix, err)
+	}
+
+	c.tainted = m.Tainted != nil
+
+	if len(m.ContainsSecret) > 0 {
+		if err := m.ContainsSecret.validate("secret", SecretDetectorNames()); err != nil {
+			return nil, fmt.Errorf("%scontains_secret: %w", prefix, err)
+		}
+		c.secrets = append(c.secrets, m.ContainsSecret)
+	}
+	if len(m.ContainsPII) > 0 {
+		if err := m.ContainsPII.validate("PII", PIIDetectorNames()); err != nil {
+			return nil, fmt.Errorf("%scontains_pii: %w", prefix, err)
+		}
+		c.pii = append(c.pii, m.ContainsPII)
+	}
+
+	if m.When.Source != "" {
+		x, err := compileExpr(m.When)
+		if err != nil {
+			return nil, fmt.Errorf("%swhen: %w", prefix, err)
+		}
+		c.when = x
+	}
+
+	base := where
+	if base == "" {
+		base = "match"
+	}
+
+	if m.All != nil && len(m.All) == 0 {
+		return nil, fmt.Errorf("%sall: needs at least one entry", prefix)
+	}
+	for i := range m.All {
+		child, err := compileMatch(ruleName, fmt.Sprintf("%s.all[%d]", base, i), &m.All[i], depth+1)
+		if err != nil {
+			return nil, err
+		}
+		c.all = append(c.all, child)
+		c.tainted = c.tainted || child.tainted
+		c.secrets = append(c.secrets, child.secrets...)
+		c.pii = append(c.pii, child.pii...)
+	}
+
+	if m.Any != nil && len(m.Any) == 0 {
+		return nil, fmt.Errorf("%sany: needs at least one entry", prefix)
+	}
+	for i := range m.Any {
+		child, err := compileMatch(ruleName, fmt.Sprintf("%s.any[%d]", base, i), &m.Any[i], depth+1)
+		if err != nil {
+			return nil, err
+		}
+		c.any = append(c.any, child)
+		c.tainted = c.tainted || child.tainted
+		c.secrets = append(c.secrets, child.secrets...)
+		c.pii = append(c.pii, child.pii...)
+	}
+
+	if m.Not != nil {
+		child, err := compileMatch(ruleName, base+".not", m.Not, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		c.not = child
+		c.tainted = c.tainted || child.tainted
+	}
+
+	return c, nil
+}
+
+// callContext is a tool call being evaluated, plus views of it derived
+// lazily and shared across every rule checked during one evaluation.
+type callContext struct {
+	agentID string
+	tc      extractor.ToolCall
+	meta    CallMeta
+	now     time.Time
+	limiter *rateLimiter  // nil disables rate_limit rules.
+	paths   *pathResolver // nil matches raw paths only.
+	dryRun  bool          // Check rate limits without counting the call.
+	stats   *ruleStats    // nil: rule hits aren't counted.
+	runtime bool          // Rules came from X-Ctrl-Rules; hits go to ScopeRuntime.
+	history *callHistory  // nil: sequence rules never match.
+	taint   *taintTracker // nil: no call is tainted.
+
+	lists map[string]*hostList // url_host_in_list files by name; nil: lists never match.
+
+	shell       *shellScript
+	shellParsed bool
+
+	canonPath   string
+	canonParsed bool
+
+	rawLower string // Lowercased raw arguments JSON, for arg_contains.
+	rawDone  bool
+
+	taintSrc  *taintSource // Newest source found in the arguments, if any.
+	taintDone bool
+
+	secrets     []Finding // Every detector's findings, for contains_secret.
+	secretsDone bool
+
+	piiEnabled map[string]bool // Detectors left on by the pii: toggles.
+	pii        []Finding
+	piiDone    bool
+
+	urls     []callURL // Parsed URL arguments and URLs in the command.
+	urlsDone bool
+
+	schemaViolation string // First way the arguments violate the tool's schema.
+	schemaKnown     bool   // The tool was declared with a schema.
+	schemaDone      bool
+
+	// Set by matchesRule when a workspace jail matched.
+	jailRaw, jailPath string
+
+	// Set by matchesRule when a sequence matched.
+	seqRefs []uint64
+}
+
+// newCallContext builds the context for evaluating one tool call against
+// this engine's counters and path settings. Caller must hold the mutex.
+func (e *Engine) newCallContext(agentID string, tc extractor.ToolCall) *callContext {
+	return &callContext{agentID: agentID, tc: tc, now: time.Now(), limiter: e.limiter, paths: e.paths, history: e.history, taint: e.taint, piiEnabled: e.piiEnabled, lists: e.lists}
+}
+
+// exprEnv returns what when: expressions read.
+func (cc *callContext) exprEnv() *exprEnv {
+	return &exprEnv{tool: cc.tc.Name, agent: cc.agentID, provider: cc.meta.Provider, model: cc.meta.Model, args: cc.tc.Arguments}
+}
+
+// canonicalPath returns the canonical form of the "path" argument, or ""
+// if there is none.
+func (cc *callContext) canonicalPath() string {
+	if !cc.canonParsed {
+		cc.canonParsed = true
+		raw := getStringArg(cc.tc.Arguments, "path")
+		if raw != "" && cc.paths != nil {
+			cc.canonPath = cc.paths.canonical(raw)
+		}
+	}
+	return cc.canonPath
+}
+
+// matchPathGlob matches a path glob against p. A relative p (no workspace
+// to anchor it) is also tried rooted, so "**/" matches zero directories
+// and a bare ".env" still matches "**/.env".
+func matchPathGlob(g glob.Glob, p string) bool {
+	return g.Match(p) || (!isAbsPath(p) && g.Match("/"+p))
+}
+
+// rawArgsLower returns the lowercased raw arguments JSON.
+func (cc *callContext) rawArgsLower() string {
+	if !cc.rawDone {
+		cc.rawDone = true
+		rawStr := string(cc.tc.RawJSON)
+		if rawStr == "" {
+			// Fallback: marshal Arguments to JSON for searching.
+			if data, err := json.Marshal(cc.tc.Arguments); err == nil {
+				rawStr = string(data)
+			}
+		}
+		cc.rawLower = strings.ToLower(rawStr)
+	}
+	return cc.rawLower
+}
+
+// shellScript parses the "command" argument on first use. Returns nil if
+// the call has no command.
+func (cc *callContext) shellScript() *shellScript {
+	if !cc.shellParsed {
+		cc.shellParsed = true
+		if cmd := getStringArg(cc.tc.Arguments, "command"); cmd != "" {
+			cc.shell = parseShell(cmd)
+		}
+	}
+	return cc.shell
 }
 
 // matchesRule checks whether a tool call matches a rule's conditions.
-// All non-empty match fields must be satisfied (AND logic).
 // Returns true if the rule fires for this tool call.
+func matchesRule(r *Rule, cc *callContext) bool {
+	if !matchesMatch(&r.Match, r.compiled, cc) {
+		return false
+	}
+	if r.jail != nil {
+		raw, resolved, escaped := r.jail.escape(cc.tc)
+		if !escaped {
+			return false
+		}
+		cc.jailRaw, cc.jailPath = raw, resolved
+	}
+	if r.seq != nil {
+		refs, ok := matchSequence(r.seq, cc)
+		if !ok {
+			return false
+		}
+		cc.seqRefs = refs
+	}
+	return true
+}
+
+// matchesMatch checks whether a tool call satisfies a single match block.
+// All non-empty match fields must be satisfied (AND logic), including the
+// nested blocks: every all: entry, at least one any: entry, and not the
+// not: block. c may be nil for a rule that was never compiled, in which
+// case pattern fields and nested blocks are skipped.
 //
 // Match logic from design doc Section 6.3:
 //   - tool:          case-insensitive match (handles OAuth PascalCase)
 //   - action:        case-insensitive match on "action" argument field
 //   - agent:         exact match on agent ID from URL path
-//   - path:          glob match on "path" argument (OR across list)
-//   - arg_contains:  case-insensitive substring in raw arguments JSON (OR across list)
+//   - path:          glob match on raw or canonical "path" argument (OR across list)
+//   - arg_contains:  case-insensitive substring in raw arguments JSON or the
+//     canonical path (OR across list)
 //   - command_regex: regex match on "command" argument field
 //   - url_regex:     regex match on "url" or "targetUrl" argument field
-func matchesRule(r *Rule, agentID string, tc extractor.ToolCall) bool {
-	m := r.Match
+//   - binary:        glob on argv[0] basename of any parsed command (OR across list)
+//   - argv_regex:    regex on a parsed command's space-joined argv
+//   - args:          JSON-path selectors with typed operators (AND across list)
+//   - tainted:       whether the arguments carry sensitive result content
+//   - contains_secret: a selected secret detector fires on the arguments
+//   - contains_pii:  a selected PII detector fires on the arguments
+//   - url_host, url_host_in_list, url_scheme, url_port, url_path,
+//     url_private: conditions on the parsed "url"/"targetUrl" argument or a URL in the command
+//   - undeclared_tool: the request didn't declare the called tool
+//   - schema_invalid: the arguments violate the declared tool's JSON Schema
+//   - when:          an expression over tool, agent, provider, model and args
+//
+// binary and argv_regex in the same block must be satisfied by the same
+// parsed command, and the url_* fields by the same URL.
+func matchesMatch(m *RuleMatch, c *compiledMatcher, cc *callContext) bool {
+	agentID, tc := cc.agentID, cc.tc
 
 	// Tool name match (case-insensitive, OR across list).
 	if len(m.Tool) > 0 {
@@ -105,15 +445,17 @@ func matchesRule(r *Rule, agentID string, tc extractor.ToolCall) bool {
 	}
 
 	// Path glob match (OR across list).
-	// Checks the "path" field in arguments (for read/write/edit tools).
-	if len(m.Path) > 0 && r.compiled != nil && len(r.compiled.pathGlobs) > 0 {
+	// Checks the "path" field in arguments (for read/write/edit tools),
+	// both as written and in canonical form (see paths.go).
+	if len(m.Path) > 0 && c != nil && len(c.pathGlobs) > 0 {
 		pathVal := getStringArg(tc.Arguments, "path")
 		if pathVal == "" {
 			return false
 		}
+		canon := cc.canonicalPath()
 		matched := false
-		for _, g := range r.compiled.pathGlobs {
-			if g.Match(pathVal) {
+		for _, g := range c.pathGlobs {
+			if matchPathGlob(g, pathVal) || (canon != "" && canon != pathVal && matchPathGlob(g, canon)) {
 				matched = true
 				break
 			}
@@ -124,19 +466,15 @@ func matchesRule(r *Rule, agentID string, tc extractor.ToolCall) bool {
 	}
 
 	// Argument substring match (case-insensitive, OR across list).
-	// Searches the raw JSON representation of arguments.
+	// Searches the raw JSON representation of arguments, then the
+	// canonical "path" argument.
 	if len(m.ArgContains) > 0 {
-		rawStr := string(tc.RawJSON)
-		if rawStr == "" {
-			// Fallback: marshal Arguments to JSON for searching.
-			if data, err := json.Marshal(tc.Arguments); err == nil {
-				rawStr = string(data)
-			}
-		}
-		rawLower := strings.ToLower(rawStr)
+		rawLower := cc.rawArgsLower()
+		canonLower := strings.ToLower(cc.canonicalPath())
 		matched := false
 		for _, s := range m.ArgContains {
-			if strings.Contains(rawLower, strings.ToLower(s)) {
+			sub := strings.ToLower(s)
+			if strings.Contains(rawLower, sub) || (canonLower != "" && strings.Contains(canonLower, sub)) {
 				matched = true
 				break
 			}
@@ -148,21 +486,120 @@ func matchesRule(r *Rule, agentID string, tc extractor.ToolCall) bool {
 
 	// Command regex match.
 	// Checks the "command" field in arguments (for exec tool).
-	if r.compiled != nil && r.compiled.commandRegex != nil {
+	if c != nil && c.commandRegex != nil {
 		cmdVal := getStringArg(tc.Arguments, "command")
-		if cmdVal == "" || !r.compiled.commandRegex.MatchString(cmdVal) {
+		if cmdVal == "" || !c.commandRegex.MatchString(cmdVal) {
 			return false
 		}
 	}
 
 	// URL regex match.
 	// Checks "url" or "targetUrl" fields (for web_fetch, browser tools).
-	if r.compiled != nil && r.compiled.urlRegex != nil {
+	if c != nil && c.urlRegex != nil {
 		urlVal := getStringArg(tc.Arguments, "url")
 		if urlVal == "" {
 			urlVal = getStringArg(tc.Arguments, "targetUrl")
 		}
-		if urlVal == "" || !r.compiled.urlRegex.MatchString(urlVal) {
+		if urlVal == "" || !c.urlRegex.MatchString(urlVal) {
+			return false
+		}
+	}
+
+	// Shell-aware command match. The "command" argument is parsed once per
+	// evaluation; any simple command in it (pipeline stage, subshell,
+	// substitution, unwrapped sudo/bash -c, decoded payload) may satisfy
+	// binary and argv_regex together.
+	if c != nil && (len(c.binaryGlobs) > 0 || c.argvRegex != nil) {
+		script := cc.shellScript()
+		if script == nil || !matchesShell(c, script) {
+			return false
+		}
+	}
+
+	// Structured URL match (see urls.go): some URL in the call satisfies
+	// every url_* field.
+	if c != nil && m.hasURLMatch() {
+		matched := false
+		for _, u := range cc.callURLs() {
+			if matchesURL(m, c, u, cc.lists) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	// Generic JSON-path argument matchers (AND across the list).
+	if c != nil {
+		for i := range c.args {
+			if !c.args[i].matches(tc.Arguments) {
+				return false
+			}
+		}
+	}
+
+	// Taint check (see taint.go). tainted: false matches untainted calls.
+	if m.Tainted != nil && (cc.taintSource() != nil) != *m.Tainted {
+		return false
+	}
+
+	// Secret scan over every argument string (see secrets.go).
+	if len(m.ContainsSecret) > 0 && len(selectedFindings(m.ContainsSecret, cc.secretFindings())) == 0 {
+		return false
+	}
+
+	// PII scan, with the detectors the pii: toggles leave on (see pii.go).
+	if len(m.ContainsPII) > 0 && len(selectedFindings(m.ContainsPII, cc.piiFindings())) == 0 {
+		return false
+	}
+
+	// Declared tools and their schemas (see schema.go). Neither field
+	// matches when the request isn't known.
+	if m.UndeclaredTool != nil {
+		declared, known := cc.toolDeclared()
+		if !known || declared == *m.UndeclaredTool {
+			return false
+		}
+	}
+	if m.SchemaInvalid != nil {
+		violation, known := cc.argsSchemaViolation()
+		if !known || (violation != "") != *m.SchemaInvalid {
+			return false
+		}
+	}
+
+	// when: expression (see expr.go). Run-time errors don't match.
+	if c != nil && c.when != nil {
+		if ok, _ := c.when.evalBool(cc.exprEnv()); !ok {
+			return false
+		}
+	}
+
+	// Nested boolean blocks. Skipped for uncompiled rules — the children's
+	// compiled matchers are needed to evaluate them.
+	if c != nil {
+		for i := range m.All {
+			if !matchesMatch(&m.All[i], c.all[i], cc) {
+				return false
+			}
+		}
+
+		if len(m.Any) > 0 {
+			matched := false
+			for i := range m.Any {
+				if matchesMatch(&m.Any[i], c.any[i], cc) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return false
+			}
+		}
+
+		if m.Not != nil && matchesMatch(m.Not, c.not, cc) {
 			return false
 		}
 	}
@@ -171,6 +608,35 @@ func matchesRule(r *Rule, agentID string, tc extractor.ToolCall) bool {
 	return true
 }
 
+// matchesShell reports whether any parsed command satisfies the block's
+// binary globs and argv regex.
+func matchesShell(c *compiledMatcher, script *shellScript) bool {
+	for i := range script.Commands {
+		cmd := &script.Commands[i]
+		if len(cmd.Argv) == 0 {
+			continue
+		}
+		if len(c.binaryGlobs) > 0 {
+			bin := cmd.binary()
+			matched := false
+			for _, g := range c.binaryGlobs {
+				if g.Match(bin) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				continue
+			}
+		}
+		if c.argvRegex != nil && !c.argvRegex.MatchString(cmd.argvString()) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
 // getStringArg safely extracts a string value from a tool call's arguments map.
 // Returns "" if the key doesn't exist or the value isn't a string.
 func getStringArg(args map[string]any, key string) string {
@@ -0,0 +1,199 @@
+package engine
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// PathSettings configures how the "path" argument is canonicalized
+// before matching. Set in the `paths:` section of rules.yaml.
+//
+//	paths:
+//	  home: /home/agent          # what ~ and $HOME expand to
+//	  workspace: /srv/agent/work # base for relative paths and $PWD
+//	  resolve_symlinks: true     # follow symlinks on this machine
+//
+// Agents usually run on the same machine as the proxy, so Home defaults
+// to the proxy user's home directory. Relative paths stay relative (but
+// cleaned) when no workspace is configured; path globs also try them
+// rooted, so "**/.env" matches a bare ".env".
+type PathSettings struct {
+	Home            string `yaml:"home,omitempty"`
+	Workspace       string `yaml:"workspace,omitempty"`
+	ResolveSymlinks bool   `yaml:"resolve_symlinks,omitempty"`
+}
+
+// pathResolver canonicalizes paths for one PathSettings.
+type pathResolver struct {
+	home            string
+	workspace       string
+	resolveSymlinks bool
+}
+
+// newPathResolver builds a resolver, filling in the default home.
+func newPathResolver(s PathSettings) *pathResolver {
+	home := s.Home
+	if home == "" {
+		home, _ = os.UserHomeDir()
+	}
+	return &pathResolver{
+		home:            toSlash(home),
+		workspace:       toSlash(s.Workspace),
+		resolveSymlinks: s.ResolveSymlinks,
+	}
+}
+
+// canonical returns the canonical form of p:
+//   - backslashes become forward slashes
+//   - ~, ~/..., $HOME, ${HOME}, and %USERPROFILE% expand to the home dir
+//   - $PWD and ${PWD} expand to the workspace (when configured)
+//   - relative paths are joined to the workspace (when configured)
+//   - . and .. segments, duplicate and trailing slashes are removed
+//   - symlinks are resolved, if enabled and the path exists locally
+//
+// Returns "" for an empty path.
+func (r *pathResolver) canonical(p string) string {
+	canon, _ := r.resolve(p)
+	return canon
+}
+
+// resolve is canonical for callers that can't trust a path whose symlinks
+// weren't resolved: ok is false when symlink resolution is enabled and p
+// isn't absolute on this machine or a link in it can't be followed.
+func (r *pathResolver) resolve(p string) (canon string, ok bool) {
+	p = strings.TrimSpace(p)
+	if p == "" {
+		return "", true
+	}
+	p = toSlash(p)
+
+	if r.home != "" {
+		switch {
+		case p == "~":
+			p = r.home
+		case strings.HasPrefix(p, "~/"):
+			p = r.home + p[1:]
+		}
+		p = expandVar(p, "HOME", r.home)
+		p = expandVar(p, "USERPROFILE", r.home)
+	}
+	if r.workspace != "" {
+		p = expandVar(p, "PWD", r.workspace)
+		if !isAbsPath(p) {
+			p = r.workspace + "/" + p
+		}
+	}
+
+	p = cleanPath(p)
+
+	if r.resolveSymlinks {
+		resolved, ok := evalSymlinks(p)
+		if !ok {
+			return p, false
+		}
+		p = resolved
+	}
+	return p, true
+}
+
+// expandVar replaces $NAME, ${NAME}, and %NAME% with value.
+func expandVar(p, name, value string) string {
+	if !strings.ContainsAny(p, "$%") {
+		return p
+	}
+	p = strings.ReplaceAll(p, "${"+name+"}", value)
+	p = strings.ReplaceAll(p, "%"+name+"%", value)
+
+	// $NAME only when not followed by another identifier character, so
+	// $HOMEDIR is left alone.
+	token := "$" + name
+	var b strings.Builder
+	for {
+		i := strings.Index(p, token)
+		if i < 0 {
+			b.WriteString(p)
+			break
+		}
+		end := i + len(token)
+		if end < len(p) && isIdentChar(p[end]) {
+			b.WriteString(p[:end])
+		} else {
+			b.WriteString(p[:i])
+			b.WriteString(value)
+		}
+		p = p[end:]
+	}
+	return b.String()
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9'
+}
+
+// cleanPath is path.Clean that keeps a Windows drive prefix ("C:") intact
+// and leaves a relative path's leading ".." segments alone.
+func cleanPath(p string) string {
+	drive := ""
+	if len(p) >= 2 && p[1] == ':' && isDriveLetter(p[0]) {
+		drive, p = p[:2], p[2:]
+	}
+	if p == "" {
+		return drive
+	}
+	return drive + path.Clean(p)
+}
+
+// isAbsPath reports whether p (already slash-normalized) is absolute on
+// either Unix or Windows.
+func isAbsPath(p string) bool {
+	if strings.HasPrefix(p, "/") {
+		return true
+	}
+	return len(p) >= 3 && isDriveLetter(p[0]) && p[1] == ':' && p[2] == '/'
+}
+
+func isDriveLetter(c byte) bool {
+	return c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z'
+}
+
+func toSlash(p string) string {
+	return strings.ReplaceAll(p, `\`, "/")
+}
+
+// evalSymlinks resolves symlinks in p. If p itself doesn't exist (a file
+// about to be written), its nearest existing ancestor is resolved and the
+// rest appended, so a symlinked directory is still seen through. It fails
+// for a path that isn't absolute on this machine, and for one that exists
+// but can't be resolved (a dangling or looping link).
+func evalSymlinks(p string) (string, bool) {
+	native := filepath.FromSlash(p)
+	if !filepath.IsAbs(native) {
+		return "", false
+	}
+	rest := ""
+	for {
+		if resolved, err := filepath.EvalSymlinks(native); err == nil {
+			out := toSlash(resolved)
+			if rest != "" {
+				out = cleanPath(out + "/" + rest)
+			}
+			return out, true
+		}
+		if _, err := os.Lstat(native); err == nil {
+			return "", false
+		}
+		parent := filepath.Dir(native)
+		if parent == native {
+			return "", false
+		}
+		base := filepath.Base(native)
+		if rest == "" {
+			rest = base
+		} else {
+			rest = base + "/" + rest
+		}
+		native = parent
+	}
+}
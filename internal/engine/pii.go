@@ -0,0 +1,196 @@
+package engine
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// PII detector names accepted by contains_pii and the pii: toggles.
+const (
+	PIIEmail      = "email"
+	PIIPhone      = "phone"
+	PIICreditCard = "credit_card"
+	PIINationalID = "national_id"
+	PIIIBAN       = "iban"
+)
+
+// piiDetector recognizes one kind of personal data. valid filters regex
+// matches with a checksum or range check; nil accepts every match.
+type piiDetector struct {
+	name  string
+	re    *regexp.Regexp
+	valid func(match string) bool
+}
+
+// piiDetectors run in this order; a span claimed by one isn't reported by
+// a later one (a card number is not also a phone number).
+var piiDetectors = []piiDetector{
+	{name: PIICreditCard, re: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), valid: validCardNumber},
+	{name: PIIIBAN, re: regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]){11,30}\b`), valid: validIBAN},
+	// US Social Security numbers.
+	{name: PIINationalID, re: regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`), valid: validSSN},
+	// UK National Insurance numbers.
+	{name: PIINationalID, re: regexp.MustCompile(`\b[A-CEGHJ-PR-TW-Z][A-CEGHJ-NPR-TW-Z] ?\d{2} ?\d{2} ?\d{2} ?[A-D]\b`)},
+	{name: PIIEmail, re: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)},
+	// International numbers with a leading +, and North American
+	// (555) 123-4567 / 555-123-4567 forms.
+	{name: PIIPhone, re: regexp.MustCompile(`\+\d{1,3}[ .-]?\(?\d{1,4}\)?(?:[ .-]?\d{2,4}){2,4}\b|(?:\(\d{3}\) ?|\b\d{3}[ .-])\d{3}[ .-]\d{4}\b`), valid: validPhone},
+}
+
+// PIIDetectorNames returns every PII detector name, sorted.
+func PIIDetectorNames() []string {
+	return []string{PIICreditCard, PIIEmail, PIIIBAN, PIINationalID, PIIPhone}
+}
+
+// validatePIIToggles checks the names in the pii: section of rules.yaml.
+func validatePIIToggles(toggles map[string]bool) error {
+	known := PIIDetectorNames()
+	for name := range toggles {
+		if i := sort.SearchStrings(known, name); i == len(known) || known[i] != name {
+			return fmt.Errorf("pii: unknown detector %q (want one of %s)", name, strings.Join(known, ", "))
+		}
+	}
+	return nil
+}
+
+// piiEnabled returns the set of detectors left on by the pii: toggles.
+// Every detector is on unless switched off.
+func piiEnabled(toggles map[string]bool) map[string]bool {
+	enabled := make(map[string]bool)
+	for _, name := range PIIDetectorNames() {
+		if on, ok := toggles[name]; !ok || on {
+			enabled[name] = true
+		}
+	}
+	return enabled
+}
+
+// scanPII runs the enabled PII detectors over the arguments.
+func scanPII(args map[string]any, raw []byte, enabled map[string]bool) []Finding {
+	return scanArgs(args, raw, func(arg, s string) []Finding {
+		return scanPIIText(arg, s, enabled)
+	})
+}
+
+// scanPIIText finds personal data in one string.
+func scanPIIText(arg, s string, enabled map[string]bool) []Finding {
+	var out []Finding
+	var claimed [][]int
+	for _, d := range piiDetectors {
+		if !enabled[d.name] {
+			continue
+		}
+		for _, loc := range d.re.FindAllStringIndex(s, -1) {
+			if overlaps(loc, claimed) || (d.valid != nil && !d.valid(s[loc[0]:loc[1]])) {
+				continue
+			}
+			out = append(out, Finding{Type: d.name, Arg: arg, Offset: loc[0], Length: loc[1] - loc[0]})
+			claimed = append(claimed, loc)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Offset < out[j].Offset })
+	return out
+}
+
+// digitsOf returns the ASCII digits in s.
+func digitsOf(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] >= '0' && s[i] <= '9' {
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// validCardNumber accepts 13-19 digit numbers with a known issuer prefix
+// (Visa, Mastercard, Amex, Discover, JCB, Diners) and a valid Luhn check
+// digit.
+func validCardNumber(s string) bool {
+	d := digitsOf(s)
+	if len(d) < 13 || len(d) > 19 {
+		return false
+	}
+	prefix := false
+	for _, p := range []string{"4", "51", "52", "53", "54", "55", "22", "23", "24", "25", "26", "27", "34", "37", "6011", "65", "35", "30", "36", "38"} {
+		if strings.HasPrefix(d, p) {
+			prefix = true
+			break
+		}
+	}
+	return prefix && luhn(d)
+}
+
+// luhn reports whether a digit string passes the Luhn checksum.
+func luhn(d string) bool {
+	sum := 0
+	double := false
+	for i := len(d) - 1; i >= 0; i-- {
+		n := int(d[i] - '0')
+		if double {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// validIBAN accepts 15-34 character IBANs whose mod-97 check is 1.
+func validIBAN(s string) bool {
+	s = strings.ReplaceAll(s, " ", "")
+	if len(s) < 15 || len(s) > 34 {
+		return false
+	}
+	// Move the country code and check digits to the end, then read
+	// letters as 10..35.
+	rearranged := s[4:] + s[:4]
+	rem := 0
+	for i := 0; i < len(rearranged); i++ {
+		c := rearranged[i]
+		switch {
+		case c >= '0' && c <= '9':
+			rem = (rem*10 + int(c-'0')) % 97
+		case c >= 'A' && c <= 'Z':
+			rem = (rem*100 + int(c-'A') + 10) % 97
+		default:
+			return false
+		}
+	}
+	return rem == 1
+}
+
+// validSSN rejects numbers the SSA never issues: area 000, 666 or 900+,
+// group 00, serial 0000.
+func validSSN(s string) bool {
+	area, group, serial := s[0:3], s[4:6], s[7:11]
+	return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+}
+
+// validPhone accepts 10-15 digits (E.164 allows at most 15).
+func validPhone(s string) bool {
+	n := len(digitsOf(s))
+	return n >= 10 && n <= 15
+}
+
+// piiFindings returns the call's PII findings, scanned on first use.
+func (cc *callContext) piiFindings() []Finding {
+	if !cc.piiDone {
+		cc.piiDone = true
+		cc.pii = scanPII(cc.tc.Arguments, cc.tc.RawJSON, cc.piiEnabled)
+	}
+	return cc.pii
+}
+
+// rulePII returns the findings that made a contains_pii rule match.
+func rulePII(r *Rule, cc *callContext) []Finding {
+	if r.compiled == nil || len(r.compiled.pii) == 0 {
+		return nil
+	}
+	return selectedFindings(mergeSelectors(r.compiled.pii), cc.piiFindings())
+}
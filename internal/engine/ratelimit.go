@@ -0,0 +1,255 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit turns a rule into a budget: the rule's match selects which
+// tool calls count, and the rule only fires once a key has used up its
+// budget within the sliding window.
+//
+//	rules:
+//	  - name: limit-fetches
+//	    match:
+//	      tool: [web_fetch, message]
+//	    rate_limit:
+//	      max: 20
+//	      per: 1m
+//	      key: [agent, tool]
+//	    action: block
+//
+// Key picks what is counted separately: "agent", "tool", or both.
+// The default is per agent. Calls over the budget are not counted, so a
+// looping agent gets its budget back one call at a time as the window
+// slides rather than staying locked out.
+type RateLimit struct {
+	Max int          `yaml:"max"`
+	Per string       `yaml:"per"`           // Go duration: "30s", "1m", "1h".
+	Key stringOrList `yaml:"key,omitempty"` // "agent", "tool" (default: agent).
+}
+
+// rateSpec is a validated RateLimit.
+type rateSpec struct {
+	max      int
+	window   time.Duration
+	perAgent bool
+	perTool  bool
+}
+
+// compileRateLimit validates a rule's rate_limit block.
+func compileRateLimit(ruleName string, rl *RateLimit) (*rateSpec, error) {
+	if rl.Max <= 0 {
+		return nil, fmt.Errorf("rule %q: rate_limit.max must be positive", ruleName)
+	}
+	window, err := time.ParseDuration(rl.Per)
+	if err != nil || window <= 0 {
+		return nil, fmt.Errorf("rule %q: rate_limit.per must be a positive duration like 30s or 1m, got %q", ruleName, rl.Per)
+	}
+
+	spec := &rateSpec{max: rl.Max, window: window}
+	keys := rl.Key
+	if len(keys) == 0 {
+		keys = stringOrList{"agent"}
+	}
+	for _, k := range keys {
+		switch k {
+		case "agent":
+			spec.perAgent = true
+		case "tool":
+			spec.perTool = true
+		default:
+			return nil, fmt.Errorf("rule %q: unknown rate_limit key %q (want agent or tool)", ruleName, k)
+		}
+	}
+	return spec, nil
+}
+
+// RateLimitStatus is the current usage of one rate limit bucket.
+// Serialized by /api/status.
+type RateLimitStatus struct {
+	Rule    string `json:"rule"`
+	Scope   string `json:"scope"`           // builtin, custom or runtime, as in rule stats.
+	Agent   string `json:"agent,omitempty"` // Empty when not keyed by agent.
+	Tool    string `json:"tool,omitempty"`  // Empty when not keyed by tool.
+	Count   int    `json:"count"`           // Calls counted in the current window.
+	Max     int    `json:"max"`
+	Per     string `json:"per"`
+	Limited bool   `json:"limited"` // Budget exhausted — the next call fires the rule.
+}
+
+// rateBucket is the sliding-window log for one rule/key combination.
+// hits holds the accepted call times, oldest first, never more than max.
+type rateBucket struct {
+	rule        rateRuleID
+	agent, tool string
+	max         int
+	window      time.Duration
+	hits        []time.Time
+}
+
+// prune drops hits that have slid out of the window.
+func (b *rateBucket) prune(now time.Time) {
+	cutoff := now.Add(-b.window)
+	i := 0
+	for i < len(b.hits) && !b.hits[i].After(cutoff) {
+		i++
+	}
+	if i > 0 {
+		b.hits = append(b.hits[:0], b.hits[i:]...)
+	}
+}
+
+// rateRuleID identifies a rate-limited rule's budget: its stats scope,
+// its name, and which rule of that name in the scope it is (0 for the
+// first). A header rule and a rules.yaml rule of the same name, or two
+// rules sharing a name, never share a budget.
+type rateRuleID struct {
+	scope, name string
+	nth         int
+}
+
+// rateID returns the budget identity of rules[i] in this call.
+func (cc *callContext) rateID(rules []Rule, i int) rateRuleID {
+	id := rateRuleID{scope: cc.scope(&rules[i]), name: rules[i].Name}
+	for j := 0; j < i; j++ {
+		if rules[j].Name == id.name && cc.scope(&rules[j]) == id.scope {
+			id.nth++
+		}
+	}
+	return id
+}
+
+// rateLimiter holds the counters for every rate-limited rule. It lives on
+// the Engine rather than on the rules, so counters survive Reload; buckets
+// are keyed by rule identity (see rateRuleID) and pick up changed limits
+// on their next check.
+//
+// Has its own mutex because Evaluate runs concurrently under the engine's
+// read lock.
+type rateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*rateBucket
+	checks  int
+}
+
+// sweepEvery controls how often idle buckets (rules removed, agents gone
+// quiet) are garbage-collected.
+const sweepEvery = 1024
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{buckets: make(map[string]*rateBucket)}
+}
+
+// allow records a call against the rule's budget. Returns false, plus how
+// long until the oldest counted call expires, when the budget is used up.
+// With record false the budget is only checked (explain mode).
+func (l *rateLimiter) allow(rule rateRuleID, spec *rateSpec, agentID, tool string, now time.Time, record bool) (bool, time.Duration) {
+	agent, toolKey := "", ""
+	if spec.perAgent {
+		agent = agentID
+	}
+	if spec.perTool {
+		toolKey = strings.ToLower(tool)
+	}
+	key := rule.scope + "\x00" + rule.name + "\x00" + strconv.Itoa(rule.nth) + "\x00" + agent + "\x00" + toolKey
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.checks++
+	if l.checks%sweepEvery == 0 {
+		l.sweepLocked(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &rateBucket{rule: rule, agent: agent, tool: toolKey}
+		l.buckets[key] = b
+	}
+	b.max, b.window = spec.max, spec.window
+	b.prune(now)
+
+	if len(b.hits) >= b.max {
+		// A lowered max can leave more hits than allowed; the oldest one
+		// that must expire is the one that brings the count under max.
+		return false, b.hits[len(b.hits)-b.max].Add(b.window).Sub(now)
+	}
+	if record {
+		b.hits = append(b.hits, now)
+	}
+	return true, 0
+}
+
+// sweepLocked removes buckets with no hits left in their window.
+// Caller must hold l.mu.
+func (l *rateLimiter) sweepLocked(now time.Time) {
+	for key, b := range l.buckets {
+		b.prune(now)
+		if len(b.hits) == 0 {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// status returns every non-empty bucket, sorted by rule, scope, agent, tool.
+func (l *rateLimiter) status(now time.Time) []RateLimitStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweepLocked(now)
+	out := make([]RateLimitStatus, 0, len(l.buckets))
+	for _, b := range l.buckets {
+		out = append(out, RateLimitStatus{
+			Rule:    b.rule.name,
+			Scope:   b.rule.scope,
+			Agent:   b.agent,
+			Tool:    b.tool,
+			Count:   len(b.hits),
+			Max:     b.max,
+			Per:     b.window.String(),
+			Limited: len(b.hits) >= b.max,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Rule != out[j].Rule {
+			return out[i].Rule < out[j].Rule
+		}
+		if out[i].Scope != out[j].Scope {
+			return out[i].Scope < out[j].Scope
+		}
+		if out[i].Agent != out[j].Agent {
+			return out[i].Agent < out[j].Agent
+		}
+		return out[i].Tool < out[j].Tool
+	})
+	return out
+}
+
+// rateLimitMessage builds the block message for a call over budget,
+// keeping the rule's own message in front when it has one.
+func rateLimitMessage(r *Rule, agentID, tool string, retryAfter time.Duration) string {
+	var scope []string
+	if r.rate.perAgent {
+		scope = append(scope, "agent="+agentID)
+	}
+	if r.rate.perTool {
+		scope = append(scope, "tool="+tool)
+	}
+	retry := int(math.Ceil(retryAfter.Seconds()))
+	if retry < 1 {
+		retry = 1
+	}
+
+	detail := fmt.Sprintf("rate limit of %d calls per %s exceeded for %s; retry in %ds",
+		r.rate.max, r.rate.window, strings.Join(scope, ", "), retry)
+	if r.Message != "" {
+		return r.Message + " (" + detail + ")"
+	}
+	return strings.ToUpper(detail[:1]) + detail[1:]
+}
@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"sort"
+	"strconv"
+)
+
+// RedactArgs is redactArgs for callers outside the engine: the proxy uses
+// it to keep findings out of the arguments it writes to the audit log.
+func RedactArgs(args map[string]any, findings []Finding) map[string]any {
+	return redactArgs(args, findings)
+}
+
+// redactArgs returns a copy of args with every finding's span replaced by
+// "[REDACTED:<type>]". Values without findings are shared with args, not
+// copied. Returns nil when args is nil: the arguments didn't parse, so
+// there is nothing to rewrite.
+func redactArgs(args map[string]any, findings []Finding) map[string]any {
+	if args == nil {
+		return nil
+	}
+	byArg := make(map[string][]Finding)
+	for _, f := range findings {
+		byArg[f.Arg] = append(byArg[f.Arg], f)
+	}
+
+	var walk func(path string, v any) any
+	walk = func(path string, v any) any {
+		switch v := v.(type) {
+		case string:
+			if fs := byArg[path]; len(fs) > 0 {
+				return redactString(v, fs)
+			}
+			return v
+		case map[string]any:
+			out := make(map[string]any, len(v))
+			for k, child := range v {
+				out[k] = walk(joinArgPath(path, k), child)
+			}
+			return out
+		case []any:
+			out := make([]any, len(v))
+			for i, child := range v {
+				out[i] = walk(path+"["+strconv.Itoa(i)+"]", child)
+			}
+			return out
+		default:
+			return v
+		}
+	}
+	return walk("", args).(map[string]any)
+}
+
+// redactString replaces the findings' spans in s. Overlapping and
+// adjacent spans are merged into one, labelled with the type of the
+// finding that starts first (the longest, on a tie), so no part of any
+// finding survives between two replacements.
+func redactString(s string, findings []Finding) string {
+	type span struct {
+		lo, hi int
+		typ    string
+	}
+	fs := append([]Finding(nil), findings...)
+	sort.Slice(fs, func(i, j int) bool {
+		if fs[i].Offset != fs[j].Offset {
+			return fs[i].Offset < fs[j].Offset
+		}
+		return fs[i].Length > fs[j].Length
+	})
+
+	var spans []span
+	for _, f := range fs {
+		lo, hi := f.Offset, f.Offset+f.Length
+		if lo < 0 || hi > len(s) || hi <= lo {
+			continue
+		}
+		if n := len(spans); n > 0 && lo <= spans[n-1].hi {
+			spans[n-1].hi = max(spans[n-1].hi, hi)
+			continue
+		}
+		spans = append(spans, span{lo, hi, f.Type})
+	}
+
+	// Last first, so earlier offsets stay valid.
+	for i := len(spans) - 1; i >= 0; i-- {
+		sp := spans[i]
+		s = s[:sp.lo] + "[REDACTED:" + sp.typ + "]" + s[sp.hi:]
+	}
+	return s
+}
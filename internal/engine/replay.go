@@ -0,0 +1,146 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ctrlai/ctrlai/internal/extractor"
+)
+
+// ReplayCall is a historical tool call and the decision it got, as read
+// from the audit log (`ctrlai rules replay`).
+type ReplayCall struct {
+	Seq       uint64
+	Timestamp string // RFC 3339, as stored in the audit log.
+	Agent     string
+	Tool      string
+	Arguments any
+	Decision  string // "allow", "block", or "ask".
+	Rule      string
+}
+
+// ReplayOutcome is a decision and the rule that made it.
+type ReplayOutcome struct {
+	Decision string `json:"decision"`
+	Rule     string `json:"rule,omitempty"`
+}
+
+// ReplayChange is one call whose decision or deciding rule changed.
+type ReplayChange struct {
+	Seq       uint64        `json:"seq"`
+	Timestamp string        `json:"ts"`
+	Agent     string        `json:"agent"`
+	Tool      string        `json:"tool"`
+	Before    ReplayOutcome `json:"before"`
+	After     ReplayOutcome `json:"after"`
+}
+
+// ReplayGroup summarizes the changes attributed to one rule for one agent.
+// The rule is the one that decides the call now, or — if no rule decides
+// it any more — the one that used to.
+type ReplayGroup struct {
+	Rule        string         `json:"rule"`
+	Agent       string         `json:"agent"`
+	Changes     int            `json:"changes"`
+	Transitions map[string]int `json:"transitions"` // e.g. "allow -> block": 3
+}
+
+// ReplayReport is the result of replaying history against a rule set.
+type ReplayReport struct {
+	Total   int            `json:"total"`   // Calls replayed.
+	Changed int            `json:"changed"` // Calls whose outcome changed.
+	Skipped int            `json:"skipped"` // Calls without stored arguments.
+	Groups  []ReplayGroup  `json:"groups"`
+	Changes []ReplayChange `json:"changes"`
+}
+
+// Replay re-evaluates historical tool calls against this engine's rules
+// and reports every call whose decision or deciding rule differs from
+// what was recorded. Calls should be in the order they were made.
+//
+// Each call is evaluated at its recorded time against a private set of
+// rate_limit counters and call history, so budgets and sequences play out
+// as they would have and the engine's live state is untouched. Rules sent via X-Ctrl-Rules at
+// the time are not known, so calls they decided show up as changes. Tool
+// results aren't audited, so no call is tainted.
+//
+// A delegate rule's answer comes from the policy service and can't be
+// reproduced. When the same rule delegated the call at the time, the
+// recorded answer stands; otherwise only the rule is compared and the
+// change is reported with the decision "delegate". Calls become history
+// the way the proxy records them: allowed, redacted and rewritten calls,
+// and delegated calls the service allowed.
+func (e *Engine) Replay(calls []ReplayCall) ReplayReport {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	limiter, history := newRateLimiter(), newCallHistory()
+	report := ReplayReport{Groups: []ReplayGroup{}, Changes: []ReplayChange{}}
+	groups := make(map[[2]string]*ReplayGroup)
+
+	for _, call := range calls {
+		args, ok := call.Arguments.(map[string]any)
+		if !ok {
+			report.Skipped++
+			continue
+		}
+		report.Total++
+
+		raw, _ := json.Marshal(args)
+		cc := e.newCallContext(call.Agent, extractor.ToolCall{Name: call.Tool, Arguments: args, RawJSON: raw})
+		cc.limiter, cc.history, cc.taint = limiter, history, nil
+		if ts, err := time.Parse(time.RFC3339Nano, call.Timestamp); err == nil {
+			cc.now = ts
+		}
+		d := evaluateRules(e.rules, e.monitor, e.defaultDecision(call.Agent), cc)
+
+		before := ReplayOutcome{Decision: call.Decision, Rule: call.Rule}
+		after := ReplayOutcome{Decision: d.Action, Rule: d.Rule}
+		if d.Action == "delegate" && d.Rule == call.Rule {
+			after.Decision = call.Decision
+			d.Action = call.Decision // Only allow is recorded below; there are no new arguments.
+		}
+		if tc, ran := ranCall(cc.tc, d); ran {
+			history.record(call.Agent, historyEntry{tc: tc, at: cc.now, seq: call.Seq})
+		}
+		if before == after {
+			continue
+		}
+
+		report.Changed++
+		report.Changes = append(report.Changes, ReplayChange{
+			Seq: call.Seq, Timestamp: call.Timestamp, Agent: call.Agent, Tool: call.Tool,
+			Before: before, After: after,
+		})
+
+		rule := after.Rule
+		if rule == "" {
+			rule = before.Rule
+		}
+		key := [2]string{rule, call.Agent}
+		g, ok := groups[key]
+		if !ok {
+			g = &ReplayGroup{Rule: rule, Agent: call.Agent, Transitions: make(map[string]int)}
+			groups[key] = g
+		}
+		g.Changes++
+		g.Transitions[fmt.Sprintf("%s -> %s", before.Decision, after.Decision)]++
+	}
+
+	for _, g := range groups {
+		report.Groups = append(report.Groups, *g)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i], report.Groups[j]
+		if a.Changes != b.Changes {
+			return a.Changes > b.Changes
+		}
+		if a.Rule != b.Rule {
+			return a.Rule < b.Rule
+		}
+		return a.Agent < b.Agent
+	})
+	return report
+}
@@ -0,0 +1,195 @@
+package engine
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// ArgPatch is a single entry in a rewrite rule's `rewrite:` list. It
+// changes the tool call's arguments before the agent runs the call.
+//
+//	action: rewrite
+//	rewrite:
+//	  - path: command
+//	    op: replace
+//	    pattern: '\s--force\b'
+//	    value: ""
+//	  - path: timeout
+//	    op: set
+//	    value: 600
+//
+// Paths use the `args:` syntax (see ArgMatcher). Patches apply in order,
+// each to the result of the previous one.
+//
+// Operators:
+//   - set:     set the value, adding the key if the object lacks it
+//     (intermediate objects are not created)
+//   - delete:  remove the key; the path must end in an object key or `*`
+//   - replace: replace every match of the regex pattern in a string value
+//     with value, which may use $1-style group references
+type ArgPatch struct {
+	Path    string `yaml:"path"`
+	Op      string `yaml:"op"`
+	Value   any    `yaml:"value,omitempty"`
+	Pattern string `yaml:"pattern,omitempty"`
+}
+
+// compiledPatch is a pre-compiled ArgPatch.
+type compiledPatch struct {
+	path  []pathSegment
+	op    string
+	value any            // For "set", normalized to JSON-decoded types.
+	re    *regexp.Regexp // For "replace".
+	repl  string         // For "replace".
+}
+
+// compilePatches validates a rule's rewrite list.
+func compilePatches(patches []ArgPatch) ([]compiledPatch, error) {
+	out := make([]compiledPatch, 0, len(patches))
+	for i, p := range patches {
+		c, err := compilePatch(p)
+		if err != nil {
+			return nil, fmt.Errorf("rewrite[%d]: %w", i, err)
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+func compilePatch(p ArgPatch) (compiledPatch, error) {
+	path, err := parseArgPath(p.Path)
+	if err != nil {
+		return compiledPatch{}, fmt.Errorf("path %q: %w", p.Path, err)
+	}
+	c := compiledPatch{path: path, op: p.Op}
+
+	switch p.Op {
+	case "set":
+		if p.Value == nil {
+			return compiledPatch{}, fmt.Errorf("path %q: set needs a value", p.Path)
+		}
+		c.value = normalizeJSONValue(p.Value)
+
+	case "delete":
+		if last := path[len(path)-1]; last.index >= 0 {
+			return compiledPatch{}, fmt.Errorf("path %q: delete needs an object key, not an array index", p.Path)
+		}
+
+	case "replace":
+		if p.Pattern == "" {
+			return compiledPatch{}, fmt.Errorf("path %q: replace needs a pattern", p.Path)
+		}
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			return compiledPatch{}, fmt.Errorf("path %q: invalid pattern: %w", p.Path, err)
+		}
+		c.re = re
+		if p.Value != nil {
+			s, ok := p.Value.(string)
+			if !ok {
+				return compiledPatch{}, fmt.Errorf("path %q: replace value must be a string", p.Path)
+			}
+			c.repl = s
+		}
+
+	case "":
+		return compiledPatch{}, fmt.Errorf("path %q: op is required", p.Path)
+	default:
+		return compiledPatch{}, fmt.Errorf("path %q: unknown op %q (want set, delete, or replace)", p.Path, p.Op)
+	}
+	return c, nil
+}
+
+// rewriteArgs returns a copy of args with the patches applied. Returns nil
+// when args is nil: the arguments didn't parse, so there is nothing to
+// rewrite.
+func rewriteArgs(args map[string]any, patches []compiledPatch) map[string]any {
+	if args == nil {
+		return nil
+	}
+	out := copyJSONValue(args).(map[string]any)
+	for i := range patches {
+		patches[i].apply(out)
+	}
+	return out
+}
+
+// apply patches args in place.
+func (p *compiledPatch) apply(args map[string]any) {
+	parents := []any{args}
+	if len(p.path) > 1 {
+		parents = selectArgPath(args, p.path[:len(p.path)-1])
+	}
+	last := p.path[len(p.path)-1]
+
+	for _, parent := range parents {
+		switch node := parent.(type) {
+		case map[string]any:
+			switch {
+			case last.index >= 0:
+				// An array index on an object selects nothing.
+			case last.wildcard && p.op == "delete":
+				clear(node)
+			case last.wildcard:
+				for k, v := range node {
+					if nv, ok := p.patch(v, true); ok {
+						node[k] = nv
+					}
+				}
+			case p.op == "delete":
+				delete(node, last.key)
+			default:
+				v, exists := node[last.key]
+				if nv, ok := p.patch(v, exists); ok {
+					node[last.key] = nv
+				}
+			}
+		case []any:
+			if p.op == "delete" || (!last.wildcard && last.index < 0) {
+				continue
+			}
+			for i, v := range node {
+				if last.wildcard || i == last.index {
+					if nv, ok := p.patch(v, true); ok {
+						node[i] = nv
+					}
+				}
+			}
+		}
+	}
+}
+
+// patch returns the new value for one selected slot, or false to leave it.
+func (p *compiledPatch) patch(v any, exists bool) (any, bool) {
+	switch p.op {
+	case "set":
+		return copyJSONValue(p.value), true
+	case "replace":
+		s, ok := v.(string)
+		if !exists || !ok {
+			return nil, false
+		}
+		return p.re.ReplaceAllString(s, p.repl), true
+	}
+	return nil, false
+}
+
+// copyJSONValue deep-copies a JSON-decoded value.
+func copyJSONValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, child := range v {
+			out[k] = copyJSONValue(child)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, child := range v {
+			out[i] = copyJSONValue(child)
+		}
+		return out
+	default:
+		return v
+	}
+}
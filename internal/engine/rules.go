@@ -12,6 +12,13 @@
 //   - Argument substrings (string or list, case-insensitive, OR logic)
 //   - Command regex (for exec tool's "command" field)
 //   - URL regex (for web_fetch/browser "url"/"targetUrl" fields)
+//   - Shell-aware binary and argv matching on parsed exec commands
+//   - Generic JSON-path argument matchers (args: with typed operators)
+//   - Nested all/any/not blocks combining any of the above
+//   - Ordered sequences of calls by the same agent (sequence:)
+//   - Arguments carrying content from sensitive tool results (tainted:)
+//   - Credentials anywhere in the arguments (contains_secret:)
+//   - Personal data anywhere in the arguments (contains_pii:)
 //
 // See design doc Section 6 for the full rule schema and evaluation logic.
 package engine
@@ -37,13 +44,33 @@ import (
 type Rule struct {
 	Name    string    `yaml:"name"`
 	Match   RuleMatch `yaml:"match"`
-	Action  string    `yaml:"action"`  // "block" or "allow"
-	Message string    `yaml:"message"` // Human-readable explanation.
-	Builtin bool      `yaml:"-"`       // True for built-in rules (not serialized).
+	Action  string    `yaml:"action"`         // "block", "allow", "ask", "redact", "rewrite", or "delegate"
+	Message string    `yaml:"message"`        // Human-readable explanation.
+	Mode    string    `yaml:"mode,omitempty"` // "enforce" (default) or "monitor"
+	Builtin bool      `yaml:"-"`              // True for built-in rules (not serialized).
+
+	// RateLimit, if set, makes the rule fire only for calls over budget.
+	RateLimit *RateLimit `yaml:"rate_limit,omitempty"`
+
+	// Sequence, if set, replaces Match: the rule fires on a call matching
+	// the last step when the agent's earlier calls matched the others.
+	Sequence []SequenceStep `yaml:"sequence,omitempty"`
+
+	// Sensitive marks matched calls so their results taint the agent (see
+	// taint.go). A sensitive allow rule only tags; evaluation continues.
+	Sensitive bool `yaml:"sensitive,omitempty"`
+
+	// Rewrite lists the argument patches an action: rewrite rule applies
+	// (see rewrite.go).
+	Rewrite []ArgPatch `yaml:"rewrite,omitempty"`
 
 	// compiled holds pre-compiled matchers (regex, glob).
 	// Set by compileMatcher() after loading.
 	compiled *compiledMatcher
+	rate     *rateSpec       // Validated RateLimit, set by compileMatcher().
+	jail     *workspaceJail  // Set on synthesized workspace_jail rules.
+	seq      *sequenceSpec   // Compiled Sequence, set by compileMatcher().
+	patches  []compiledPatch // Compiled Rewrite, set by compileMatcher().
 }
 
 // RuleMatch defines the conditions under which a rule fires.
@@ -60,15 +87,69 @@ type Rule struct {
 //	    ArgContains  []string // substrings in raw JSON (OR, case-insensitive)
 //	    CommandRegex string   // regex for `command` field
 //	    URLRegex     string   // regex for `url`/`targetUrl` field
+//	    Binary       []string // argv[0] basename globs, any parsed command (OR)
+//	    ArgvRegex    string   // regex for a parsed command's argv
 //	}
+//
+// All, Any, and Not nest arbitrarily and are AND'd with the flat fields:
+//
+//	match:
+//	  tool: exec
+//	  any:
+//	    - command_regex: '\bcurl\b'
+//	    - command_regex: '\bwget\b'
+//	  not:
+//	    arg_contains: api.internal
 type RuleMatch struct {
-	Tool         stringOrList `yaml:"tool"`
-	Action       stringOrList `yaml:"action"`
-	Agent        string       `yaml:"agent"`
-	Path         stringOrList `yaml:"path"`
-	ArgContains  stringOrList `yaml:"arg_contains"`
-	CommandRegex string       `yaml:"command_regex"`
-	URLRegex     string       `yaml:"url_regex"`
+	Tool         stringOrList `yaml:"tool,omitempty"`
+	Action       stringOrList `yaml:"action,omitempty"`
+	Agent        string       `yaml:"agent,omitempty"`
+	Path         stringOrList `yaml:"path,omitempty"`
+	ArgContains  stringOrList `yaml:"arg_contains,omitempty"`
+	CommandRegex string       `yaml:"command_regex,omitempty"`
+	URLRegex     string       `yaml:"url_regex,omitempty"`
+	Binary       stringOrList `yaml:"binary,omitempty"`
+	ArgvRegex    string       `yaml:"argv_regex,omitempty"`
+	Args         []ArgMatcher `yaml:"args,omitempty"`
+	Tainted      *bool        `yaml:"tainted,omitempty"` // Arguments carry a sensitive result.
+
+	// URL fields match the parsed URLs in the call (see urls.go). All of
+	// them in one block must hold for the same URL.
+	URLHost       stringOrList `yaml:"url_host,omitempty"`         // Domain suffixes, IPs, or CIDRs.
+	URLHostInList stringOrList `yaml:"url_host_in_list,omitempty"` // List files, relative to rules.yaml.
+	URLScheme     stringOrList `yaml:"url_scheme,omitempty"`       // Case-insensitive.
+	URLPort       stringOrList `yaml:"url_port,omitempty"`         // Ports or ranges like 8000-8999.
+	URLPath       stringOrList `yaml:"url_path,omitempty"`         // Globs on the cleaned path.
+	URLPrivate    *bool        `yaml:"url_private,omitempty"`      // Host is local, private, or metadata.
+
+	// ContainsSecret is true or a list of detector names (see secrets.go).
+	ContainsSecret detectorSelector `yaml:"contains_secret,omitempty"`
+
+	// ContainsPII is true or a list of PII detector names (see pii.go).
+	ContainsPII detectorSelector `yaml:"contains_pii,omitempty"`
+
+	// Checks against the tools the request declared (see schema.go).
+	UndeclaredTool *bool `yaml:"undeclared_tool,omitempty"` // The request didn't offer the tool.
+	SchemaInvalid  *bool `yaml:"schema_invalid,omitempty"`  // Arguments violate the tool's schema.
+
+	// When is an expression for what the fields can't say (see expr.go).
+	When Expr `yaml:"when,omitempty"`
+
+	All []RuleMatch `yaml:"all,omitempty"` // Every entry must match.
+	Any []RuleMatch `yaml:"any,omitempty"` // At least one entry must match.
+	Not *RuleMatch  `yaml:"not,omitempty"` // Must NOT match.
+}
+
+// isEmpty reports whether the match block has no conditions at all.
+func (m *RuleMatch) isEmpty() bool {
+	return len(m.Tool) == 0 && len(m.Action) == 0 && m.Agent == "" &&
+		len(m.Path) == 0 && len(m.ArgContains) == 0 &&
+		m.CommandRegex == "" && m.URLRegex == "" &&
+		len(m.Binary) == 0 && m.ArgvRegex == "" && len(m.Args) == 0 &&
+		m.Tainted == nil && len(m.ContainsSecret) == 0 && len(m.ContainsPII) == 0 &&
+		m.UndeclaredTool == nil && m.SchemaInvalid == nil &&
+		!m.hasURLMatch() && m.When.Source == "" &&
+		m.All == nil && m.Any == nil && m.Not == nil
 }
 
 // stringOrList handles YAML fields that can be either a single string
@@ -96,58 +177,136 @@ func (s *stringOrList) UnmarshalYAML(value *yaml.Node) error {
 	}
 }
 
+// Rule modes. Monitor-mode rules never affect the response — a match is
+// reported in Decision.WouldBlock and audited as "would_block" instead.
+const (
+	ModeEnforce = "enforce"
+	ModeMonitor = "monitor"
+)
+
+// DefaultRuleName is reported as Decision.Rule when no rule matched and a
+// configured default_action (global or per-agent) decided the tool call.
+const DefaultRuleName = "default_action"
+
 // Decision is the outcome of evaluating a tool call against the rule set.
+//
+// Action "ask" means the tool call must be held for operator approval;
+// the proxy resolves it to "allow" or "block" via the approval queue.
+// Actions "redact" and "rewrite" let the call through with Args in place
+// of its arguments. Action "delegate" hands the decision to the external
+// policy service configured in config.yaml; the proxy replaces it with
+// the service's "allow", "block" or "rewrite".
+//
+// WouldBlock lists monitor-mode rules that matched before the enforced
+// decision was reached. Each entry carries the rule's own action ("block"
+// or "ask"); the proxy audits them as "would_block" and leaves the
+// response untouched.
+//
+// Default is true when no rule matched and the decision came from a
+// configured default_action; Rule is then DefaultRuleName. With no
+// default_action configured, a non-match is a plain allow with no rule.
 type Decision struct {
-	Action  string // "allow" or "block"
-	Rule    string // Name of the rule that matched (empty if default allow).
-	Message string // Human-readable reason (from the rule).
+	Action     string         // "allow", "block", "ask", "redact", "rewrite", or "delegate"
+	Rule       string         // Name of the rule that matched (empty if default allow).
+	Message    string         // Human-readable reason (from the rule).
+	Default    bool           // Decided by default_action, not a named rule.
+	Path       string         // Canonical form of the "path" argument, if any.
+	Sequence   []uint64       // Audit seqs of a sequence rule's earlier steps.
+	Sensitive  bool           // A sensitive rule matched; the proxy marks the call.
+	TaintSeq   uint64         // Audit seq of the sensitive call a tainted: rule traced.
+	Secrets    []Finding      // What a contains_secret rule found (never the secret).
+	PII        []Finding      // What a contains_pii rule found.
+	Args       map[string]any // New arguments, for Action "redact" or "rewrite".
+	WouldBlock []Decision     // Monitor-mode matches (not enforced).
+}
+
+// Rewrites reports whether the call goes through with Args in place of
+// its arguments.
+func (d Decision) Rewrites() bool {
+	return d.Action == "redact" || d.Action == "rewrite"
 }
 
 // RuleInfo is a summary of a rule for display (used by `ctrlai rules list`).
+//
+// ListRules also reports the effective defaults as trailing entries with
+// Default set: one for the global default_action and one per agent
+// override (Agent set), then one entry per X-Ctrl-Rules rule that has
+// matched, with Runtime set.
 type RuleInfo struct {
 	Name    string
 	Builtin bool
 	Action  string
 	Message string
+	Mode    string // Effective mode: "enforce" or "monitor".
+	Default bool   // Pseudo-entry for a default_action.
+	Agent   string // Agent the default applies to (empty = all agents).
+	Runtime bool   // Rule from an X-Ctrl-Rules header; only Name and Stats are set.
+	Stats   RuleStats
+}
+
+// AgentPolicy holds per-agent settings from the `agents:` section of
+// rules.yaml.
+//
+//	agents:
+//	  prod-bot:
+//	    default_action: block
+//	    workspace_roots: [/srv/prod-bot]
+type AgentPolicy struct {
+	DefaultAction string `yaml:"default_action,omitempty"` // Overrides the global default_action.
+
+	// WorkspaceRoots confines the agent's file tools (read, write, edit,
+	// apply_patch, image) to these directories. Relative paths resolve
+	// against the first root.
+	WorkspaceRoots []string `yaml:"workspace_roots,omitempty"`
 }
 
 // rulesFile is the YAML envelope for rules.yaml.
+//
+// Monitor is the global dry-run switch: when true, every rule (built-in
+// and custom) behaves as if it had mode: monitor.
+//
+// DefaultAction decides tool calls that match no rule ("allow" when
+// empty); Agents overrides it per agent ID. Paths configures path
+// canonicalization, Taint taint tracking, and PII turns individual PII
+// detectors off.
 type rulesFile struct {
-	Rules   []Rule                `yaml:"rules"`
-	Builtin map[string]bool       `yaml:"builtin"`
+	DefaultAction string                 `yaml:"default_action,omitempty"`
+	Agents        map[string]AgentPolicy `yaml:"agents,omitempty"`
+	Paths         PathSettings           `yaml:"paths,omitempty"`
+	Taint         TaintSettings          `yaml:"taint,omitempty"`
+	PII           map[string]bool        `yaml:"pii,omitempty"`
+	Monitor       bool                   `yaml:"monitor,omitempty"`
+	Rules         []Rule                 `yaml:"rules"`
+	Builtin       map[string]bool        `yaml:"builtin"`
 }
 
-// loadRulesFromFile reads and parses custom rules from the given YAML path.
-// Returns an empty slice if the file doesn't exist (not an error).
-func loadRulesFromFile(path string) ([]Rule, map[string]bool, error) {
+// loadRulesFromFile reads and parses rules.yaml from the given path.
+// Returns an empty file if it doesn't exist (not an error).
+func loadRulesFromFile(path string) (rulesFile, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, nil, nil
+			return rulesFile{}, nil
 		}
-		return nil, nil, fmt.Errorf("reading rules %s: %w", path, err)
+		return rulesFile{}, fmt.Errorf("reading rules %s: %w", path, err)
 	}
 
 	if len(data) == 0 {
-		return nil, nil, nil
+		return rulesFile{}, nil
 	}
 
 	var file rulesFile
 	if err := yaml.Unmarshal(data, &file); err != nil {
-		return nil, nil, fmt.Errorf("parsing rules %s: %w", path, err)
+		return rulesFile{}, fmt.Errorf("parsing rules %s: %w", path, err)
 	}
 
-	return file.Rules, file.Builtin, nil
+	return file, nil
 }
 
-// saveRulesToFile writes custom rules to the given YAML path.
-// Only saves custom rules (not built-in) and the builtin toggle map.
-func saveRulesToFile(path string, customRules []Rule, builtinToggles map[string]bool) error {
-	file := rulesFile{
-		Rules:   customRules,
-		Builtin: builtinToggles,
-	}
-
+// saveRulesToFile writes rules.yaml to the given path.
+// Only saves custom rules (not built-in), the builtin toggle map, and
+// file-level settings.
+func saveRulesToFile(path string, file rulesFile) error {
 	data, err := yaml.Marshal(&file)
 	if err != nil {
 		return fmt.Errorf("marshaling rules: %w", err)
@@ -175,6 +334,11 @@ func ParseRulesFromYAML(yamlData []byte) ([]Rule, map[string]bool, error) {
 		if err := compileMatcher(&file.Rules[i]); err != nil {
 			return nil, nil, fmt.Errorf("compiling rule %s: %w", file.Rules[i].Name, err)
 		}
+		// List files are read from the proxy's disk; a header must not
+		// name arbitrary local files.
+		if len(file.Rules[i].hostListNames()) > 0 {
+			return nil, nil, fmt.Errorf("compiling rule %s: url_host_in_list is not available in runtime rules", file.Rules[i].Name)
+		}
 	}
 
 	return file.Rules, file.Builtin, nil
@@ -183,6 +347,5 @@ func ParseRulesFromYAML(yamlData []byte) ([]Rule, map[string]bool, error) {
 // WriteDefaultRules writes a default rules.yaml with all built-in rules enabled.
 // Used by the first-run setup.
 func WriteDefaultRules(path string) error {
-	builtinToggles := defaultBuiltinToggles()
-	return saveRulesToFile(path, nil, builtinToggles)
+	return saveRulesToFile(path, rulesFile{Builtin: DefaultBuiltinToggles()})
 }
@@ -0,0 +1,533 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/ctrlai/ctrlai/internal/extractor"
+)
+
+// Tool schemas. Requests declare each tool with a JSON Schema for its
+// arguments (input_schema for Anthropic, function.parameters or parameters
+// for OpenAI). The undeclared_tool and schema_invalid match fields check a
+// call against the request it came back in: a tool the request never
+// offered was hallucinated or injected, and arguments that don't fit the
+// schema are what the agent's own validation may or may not catch.
+//
+// The validator covers what tool schemas use in practice: type, properties,
+// required, additionalProperties, items, enum, const, string and number
+// bounds, pattern, allOf/anyOf/oneOf/not, and local $ref. Keywords it
+// doesn't know are ignored, as are patterns RE2 can't compile, so an
+// unusual schema errs toward calling arguments valid.
+
+// Schema limits: a schema is compiled from the request body, once per
+// request with a SchemaCache and per call without one. Combinators can revisit a value through $ref cycles, so validation is
+// bounded by depth and by total steps.
+const (
+	maxSchemaNodes = 2000
+	maxSchemaDepth = 64
+	maxSchemaSteps = 100_000
+)
+
+// SchemaCache holds the compiled tool schemas of one request, keyed by
+// the declared tool name. Create one per request with NewSchemaCache and
+// set it on CallMeta.Schemas. Safe for concurrent use.
+type SchemaCache struct {
+	mu       sync.Mutex
+	compiled map[string]cachedSchema
+}
+
+// cachedSchema is a compiled schema, or why it couldn't be compiled.
+type cachedSchema struct {
+	node *schemaNode
+	err  error
+}
+
+// NewSchemaCache returns an empty cache.
+func NewSchemaCache() *SchemaCache {
+	return &SchemaCache{compiled: make(map[string]cachedSchema)}
+}
+
+// compile returns the compiled schema of the named tool, compiling raw on
+// first use. A nil cache compiles every time.
+func (c *SchemaCache) compile(name string, raw json.RawMessage) (*schemaNode, error) {
+	if c == nil {
+		return compileSchema(raw)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.compiled[name]; ok {
+		return s.node, s.err
+	}
+	node, err := compileSchema(raw)
+	c.compiled[name] = cachedSchema{node: node, err: err}
+	return node, err
+}
+
+// declaredSchema looks the called tool up in the request's tools. Names
+// compare case-insensitively, like the tool field; name is the one the
+// request declared. known is false without the request, and for a call
+// with no name (a tool type the extractors don't parse, like OpenAI
+// custom tools).
+func (cc *callContext) declaredSchema() (name string, schema json.RawMessage, declared, known bool) {
+	if cc.meta.Tools == nil || cc.tc.Name == "" {
+		return "", nil, false, false
+	}
+	if s, ok := cc.meta.Tools[cc.tc.Name]; ok {
+		return cc.tc.Name, s, true, true
+	}
+	for name, s := range cc.meta.Tools {
+		if strings.EqualFold(name, cc.tc.Name) {
+			return name, s, true, true
+		}
+	}
+	return "", nil, false, true
+}
+
+// toolDeclared reports whether the request declared the called tool, and
+// whether that is known.
+func (cc *callContext) toolDeclared() (declared, known bool) {
+	_, _, declared, known = cc.declaredSchema()
+	return declared, known
+}
+
+// argsSchemaViolation validates the arguments against the declared tool's
+// schema on first use, compiled through cc.meta.Schemas. It returns the
+// first violation, "" if there is none; known is false when there is no
+// schema to check against.
+func (cc *callContext) argsSchemaViolation() (violation string, known bool) {
+	if !cc.schemaDone {
+		cc.schemaDone = true
+		if name, raw, declared, _ := cc.declaredSchema(); declared && raw != nil {
+			schema, err := cc.meta.Schemas.compile(name, raw)
+			if err != nil {
+				slog.Debug("tool schema not checked", "tool", cc.tc.Name, "error", err)
+			} else {
+				cc.schemaKnown = true
+				cc.schemaViolation = validateArgs(schema, cc.tc)
+			}
+		}
+	}
+	return cc.schemaViolation, cc.schemaKnown
+}
+
+// validateArgs checks a call's arguments as sent, so non-object arguments
+// are caught too. A call without arguments is checked as {}.
+func validateArgs(schema *schemaNode, tc extractor.ToolCall) string {
+	var args any = map[string]any{}
+	if len(tc.RawJSON) > 0 {
+		var v any
+		if err := json.Unmarshal(tc.RawJSON, &v); err != nil {
+			return "arguments: not valid JSON"
+		}
+		args = v
+	} else if tc.Arguments != nil {
+		args = tc.Arguments
+	}
+	return schema.validate(args)
+}
+
+// schemaNode is a compiled JSON Schema.
+type schemaNode struct {
+	never bool // The schema `false`.
+
+	types        []string
+	properties   map[string]*schemaNode
+	required     []string
+	additional   *schemaNode // nil: any additional property is allowed.
+	items        *schemaNode
+	enum         []any
+	constVal     any
+	hasConst     bool
+	pattern      *regexp.Regexp
+	minLength    *float64
+	maxLength    *float64
+	minimum      *float64
+	maximum      *float64
+	exclusiveMin *float64
+	exclusiveMax *float64
+	minItems     *float64
+	maxItems     *float64
+	allOf        []*schemaNode
+	anyOf        []*schemaNode
+	oneOf        []*schemaNode
+	not          *schemaNode
+}
+
+// schemaCompiler compiles one schema document.
+type schemaCompiler struct {
+	root  any
+	refs  map[string]*schemaNode
+	nodes int
+}
+
+// compileSchema compiles a tool's raw JSON Schema.
+func compileSchema(raw json.RawMessage) (*schemaNode, error) {
+	var doc any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("parsing schema: %w", err)
+	}
+	sc := &schemaCompiler{root: doc, refs: make(map[string]*schemaNode)}
+	return sc.compile(doc)
+}
+
+func (sc *schemaCompiler) compile(v any) (*schemaNode, error) {
+	sc.nodes++
+	if sc.nodes > maxSchemaNodes {
+		return nil, fmt.Errorf("schema has more than %d subschemas", maxSchemaNodes)
+	}
+
+	switch s := v.(type) {
+	case bool:
+		return &schemaNode{never: !s}, nil
+	case map[string]any:
+		if ref, ok := s["$ref"].(string); ok {
+			return sc.ref(ref)
+		}
+		return sc.compileObject(s)
+	}
+	return nil, fmt.Errorf("schema must be an object or boolean, not %T", v)
+}
+
+// ref resolves a local reference ("#", "#/$defs/x", "#/definitions/x").
+// The node is registered before it is compiled, so cycles resolve to it.
+func (sc *schemaCompiler) ref(ref string) (*schemaNode, error) {
+	if n, ok := sc.refs[ref]; ok {
+		return n, nil
+	}
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("unsupported $ref %q (only local references)", ref)
+	}
+	target := sc.root
+	if p := strings.TrimPrefix(ref, "#"); p != "" {
+		for _, tok := range strings.Split(strings.TrimPrefix(p, "/"), "/") {
+			tok = strings.ReplaceAll(strings.ReplaceAll(tok, "~1", "/"), "~0", "~")
+			switch t := target.(type) {
+			case map[string]any:
+				target = t[tok]
+			case []any:
+				i, err := strconv.Atoi(tok)
+				if err != nil || i < 0 || i >= len(t) {
+					return nil, fmt.Errorf("unresolved $ref %q", ref)
+				}
+				target = t[i]
+			default:
+				target = nil
+			}
+			if target == nil {
+				return nil, fmt.Errorf("unresolved $ref %q", ref)
+			}
+		}
+	}
+
+	n := &schemaNode{}
+	sc.refs[ref] = n
+	compiled, err := sc.compile(target)
+	if err != nil {
+		return nil, err
+	}
+	*n = *compiled
+	return n, nil
+}
+
+func (sc *schemaCompiler) compileObject(s map[string]any) (*schemaNode, error) {
+	n := &schemaNode{}
+	var err error
+
+	switch t := s["type"].(type) {
+	case string:
+		n.types = []string{t}
+	case []any:
+		for _, x := range t {
+			if name, ok := x.(string); ok {
+				n.types = append(n.types, name)
+			}
+		}
+	}
+
+	if props, ok := s["properties"].(map[string]any); ok {
+		n.properties = make(map[string]*schemaNode, len(props))
+		for name, p := range props {
+			if n.properties[name], err = sc.compile(p); err != nil {
+				return nil, fmt.Errorf("properties.%s: %w", name, err)
+			}
+		}
+	}
+	if req, ok := s["required"].([]any); ok {
+		for _, r := range req {
+			if name, ok := r.(string); ok {
+				n.required = append(n.required, name)
+			}
+		}
+	}
+	if a, ok := s["additionalProperties"]; ok {
+		if n.additional, err = sc.compile(a); err != nil {
+			return nil, fmt.Errorf("additionalProperties: %w", err)
+		}
+	}
+	if it, ok := s["items"]; ok {
+		if _, isList := it.([]any); !isList { // Draft 4 tuple items aren't checked.
+			if n.items, err = sc.compile(it); err != nil {
+				return nil, fmt.Errorf("items: %w", err)
+			}
+		}
+	}
+
+	if e, ok := s["enum"].([]any); ok {
+		n.enum = e
+	}
+	if c, ok := s["const"]; ok {
+		n.constVal, n.hasConst = c, true
+	}
+	if p, ok := s["pattern"].(string); ok {
+		n.pattern, _ = regexp.Compile(p)
+	}
+
+	num := func(key string) *float64 {
+		if f, ok := s[key].(float64); ok {
+			return &f
+		}
+		return nil
+	}
+	n.minLength, n.maxLength = num("minLength"), num("maxLength")
+	n.minimum, n.maximum = num("minimum"), num("maximum")
+	n.exclusiveMin, n.exclusiveMax = num("exclusiveMinimum"), num("exclusiveMaximum")
+	n.minItems, n.maxItems = num("minItems"), num("maxItems")
+	// Draft 4: exclusiveMinimum/Maximum are booleans modifying the bounds.
+	if b, ok := s["exclusiveMinimum"].(bool); ok && b {
+		n.exclusiveMin, n.minimum = n.minimum, nil
+	}
+	if b, ok := s["exclusiveMaximum"].(bool); ok && b {
+		n.exclusiveMax, n.maximum = n.maximum, nil
+	}
+
+	for _, key := range []string{"allOf", "anyOf", "oneOf"} {
+		list, ok := s[key].([]any)
+		if !ok {
+			continue
+		}
+		var subs []*schemaNode
+		for i, x := range list {
+			sub, err := sc.compile(x)
+			if err != nil {
+				return nil, fmt.Errorf("%s[%d]: %w", key, i, err)
+			}
+			subs = append(subs, sub)
+		}
+		switch key {
+		case "allOf":
+			n.allOf = subs
+		case "anyOf":
+			n.anyOf = subs
+		default:
+			n.oneOf = subs
+		}
+	}
+	if x, ok := s["not"]; ok {
+		if n.not, err = sc.compile(x); err != nil {
+			return nil, fmt.Errorf("not: %w", err)
+		}
+	}
+	return n, nil
+}
+
+// validate returns the first way v violates the schema, as a message
+// naming the offending argument, or "" if it conforms.
+func (n *schemaNode) validate(v any) string {
+	steps := maxSchemaSteps
+	msg := n.check(v, "arguments", 0, &steps)
+	if steps < 0 {
+		// not: could have turned a cut-off branch into a pass.
+		return "arguments: schema too complex to check"
+	}
+	return msg
+}
+
+func (n *schemaNode) check(v any, at string, depth int, steps *int) string {
+	*steps--
+	if *steps < 0 {
+		return at + ": schema too complex to check"
+	}
+	if depth > maxSchemaDepth {
+		return at + ": schema nested too deeply"
+	}
+	if n.never {
+		return at + ": not allowed"
+	}
+
+	if len(n.types) > 0 {
+		ok := false
+		for _, t := range n.types {
+			if schemaTypeOf(v, t) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Sprintf("%s: expected %s, got %s", at, strings.Join(n.types, " or "), jsonTypeName(v))
+		}
+	}
+
+	if n.hasConst && !jsonEqual(v, n.constVal) {
+		return fmt.Sprintf("%s: must be %s", at, compactJSON(n.constVal))
+	}
+	if n.enum != nil {
+		ok := false
+		for _, e := range n.enum {
+			if jsonEqual(v, e) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return fmt.Sprintf("%s: must be one of %s", at, compactJSON(n.enum))
+		}
+	}
+
+	switch x := v.(type) {
+	case string:
+		length := float64(utf8.RuneCountInString(x))
+		if n.minLength != nil && length < *n.minLength {
+			return fmt.Sprintf("%s: shorter than %v characters", at, *n.minLength)
+		}
+		if n.maxLength != nil && length > *n.maxLength {
+			return fmt.Sprintf("%s: longer than %v characters", at, *n.maxLength)
+		}
+		if n.pattern != nil && !n.pattern.MatchString(x) {
+			return fmt.Sprintf("%s: does not match pattern %q", at, n.pattern.String())
+		}
+	case float64:
+		switch {
+		case n.minimum != nil && x < *n.minimum:
+			return fmt.Sprintf("%s: less than %v", at, *n.minimum)
+		case n.maximum != nil && x > *n.maximum:
+			return fmt.Sprintf("%s: greater than %v", at, *n.maximum)
+		case n.exclusiveMin != nil && x <= *n.exclusiveMin:
+			return fmt.Sprintf("%s: must be greater than %v", at, *n.exclusiveMin)
+		case n.exclusiveMax != nil && x >= *n.exclusiveMax:
+			return fmt.Sprintf("%s: must be less than %v", at, *n.exclusiveMax)
+		}
+	case []any:
+		if n.minItems != nil && float64(len(x)) < *n.minItems {
+			return fmt.Sprintf("%s: fewer than %v items", at, *n.minItems)
+		}
+		if n.maxItems != nil && float64(len(x)) > *n.maxItems {
+			return fmt.Sprintf("%s: more than %v items", at, *n.maxItems)
+		}
+		if n.items != nil {
+			for i, item := range x {
+				if msg := n.items.check(item, fmt.Sprintf("%s[%d]", at, i), depth+1, steps); msg != "" {
+					return msg
+				}
+			}
+		}
+	case map[string]any:
+		for _, name := range n.required {
+			if _, ok := x[name]; !ok {
+				return fmt.Sprintf("%s: missing required property %q", at, name)
+			}
+		}
+		// Sorted so the reported violation is stable.
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			sub, declared := n.properties[k]
+			switch {
+			case declared:
+			case n.additional != nil:
+				sub = n.additional
+			default:
+				continue
+			}
+			if sub.never && !declared {
+				return fmt.Sprintf("%s: unexpected property %q", at, k)
+			}
+			if msg := sub.check(x[k], at+"."+k, depth+1, steps); msg != "" {
+				return msg
+			}
+		}
+	}
+
+	for _, sub := range n.allOf {
+		if msg := sub.check(v, at, depth+1, steps); msg != "" {
+			return msg
+		}
+	}
+	if len(n.anyOf) > 0 {
+		var first string
+		for _, sub := range n.anyOf {
+			msg := sub.check(v, at, depth+1, steps)
+			if msg == "" {
+				first = ""
+				break
+			}
+			if first == "" {
+				first = msg
+			}
+		}
+		if first != "" {
+			return fmt.Sprintf("%s: matches none of anyOf (%s)", at, first)
+		}
+	}
+	if len(n.oneOf) > 0 {
+		matched := 0
+		for _, sub := range n.oneOf {
+			if sub.check(v, at, depth+1, steps) == "" {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return fmt.Sprintf("%s: matches %d of oneOf, want exactly 1", at, matched)
+		}
+	}
+	if n.not != nil && n.not.check(v, at, depth+1, steps) == "" {
+		return at + ": matches a schema it must not"
+	}
+	return ""
+}
+
+// schemaTypeOf reports whether v is of the JSON Schema type t.
+func schemaTypeOf(v any, t string) bool {
+	switch t {
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f) && !math.IsInf(f, 0)
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "null":
+		return v == nil
+	}
+	return true // Unknown type names don't constrain.
+}
+
+func compactJSON(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
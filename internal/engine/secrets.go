@@ -0,0 +1,341 @@
+package engine
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Finding is one secret or piece of personal data found in a tool call's
+// arguments: which detector found it and where. The value itself is never
+// kept.
+type Finding struct {
+	Type   string `json:"type"`   // Detector name, e.g. "github_token" or "email".
+	Arg    string `json:"arg"`    // Argument path, e.g. "command" or "headers.Authorization".
+	Offset int    `json:"offset"` // Byte offset of the value within that argument.
+	Length int    `json:"length"` // Length of the value in bytes.
+}
+
+// secretDetector recognizes one credential format.
+type secretDetector struct {
+	name string
+	re   *regexp.Regexp
+	// skip rejects a regex match that belongs to another format.
+	skip func(match string) bool
+}
+
+// Secret detector names accepted by contains_secret.
+const (
+	SecretAWSAccessKey = "aws_access_key"
+	SecretGitHubToken  = "github_token"
+	SecretGitLabToken  = "gitlab_token"
+	SecretSlackToken   = "slack_token"
+	SecretAnthropicKey = "anthropic_api_key"
+	SecretOpenAIKey    = "openai_api_key"
+	SecretJWT          = "jwt"
+	SecretPrivateKey   = "private_key"
+	SecretHighEntropy  = "high_entropy"
+)
+
+// secretDetectors are the pattern detectors, in reporting order. The
+// high_entropy detector is separate: it runs over whatever they left.
+var secretDetectors = []secretDetector{
+	{name: SecretAWSAccessKey, re: regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`)},
+	{name: SecretGitHubToken, re: regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{82})\b`)},
+	{name: SecretGitLabToken, re: regexp.MustCompile(`\bgl(?:pat|dt|rt|ptt)-[A-Za-z0-9_-]{20,}`)},
+	{name: SecretSlackToken, re: regexp.MustCompile(`\bxox[abposr]-[A-Za-z0-9-]{10,}|https://hooks\.slack\.com/services/T[A-Z0-9]+/B[A-Z0-9]+/[A-Za-z0-9]+`)},
+	{name: SecretAnthropicKey, re: regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_-]{32,}`)},
+	{
+		name: SecretOpenAIKey,
+		re:   regexp.MustCompile(`\bsk-(?:proj-|svcacct-|admin-)?[A-Za-z0-9_-]{32,}`),
+		skip: func(m string) bool { return strings.HasPrefix(m, "sk-ant-") },
+	},
+	{name: SecretJWT, re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{8,}\.eyJ[A-Za-z0-9_-]{8,}\.[A-Za-z0-9_-]{8,}`)},
+	{name: SecretPrivateKey, re: regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY-----`)},
+}
+
+// High-entropy tokens: base64/base62-looking runs of at least
+// minEntropyLen characters mixing upper case, lower case and digits, whose
+// Shannon entropy is close to the most their length allows: within
+// entropySlack bits of log2(length), capped at maxEntropyBar bits per
+// character. Hex strings (commit SHAs, hashes), paths and identifiers
+// fall short.
+const (
+	minEntropyLen = 24
+	entropySlack  = 0.5
+	maxEntropyBar = 4.5
+)
+
+var entropyTokenRe = regexp.MustCompile(`[A-Za-z0-9+/=_-]{` + strconv.Itoa(minEntropyLen) + `,}`)
+
+// allDetectors is the internal spelling of `contains_secret: true` and
+// `contains_pii: true`.
+const allDetectors = "*"
+
+// detectorSelector is a contains_secret or contains_pii field: true for
+// every detector, or one or more detector names.
+//
+//	contains_secret: true
+//	contains_secret: [aws_access_key, github_token]
+type detectorSelector []string
+
+// UnmarshalYAML accepts a boolean, a detector name, or a list of names.
+func (s *detectorSelector) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode && value.Tag == "!!bool" {
+		var b bool
+		if err := value.Decode(&b); err != nil {
+			return err
+		}
+		*s = nil
+		if b {
+			*s = detectorSelector{allDetectors}
+		}
+		return nil
+	}
+	var list stringOrList
+	if err := list.UnmarshalYAML(value); err != nil {
+		return err
+	}
+	*s = detectorSelector(list)
+	return nil
+}
+
+// MarshalYAML writes `true` back for the all-detectors form.
+func (s detectorSelector) MarshalYAML() (any, error) {
+	if s.all() {
+		return true, nil
+	}
+	return []string(s), nil
+}
+
+// all reports whether every detector is selected.
+func (s detectorSelector) all() bool {
+	return len(s) == 1 && s[0] == allDetectors
+}
+
+// selects reports whether findings of the named detector count.
+func (s detectorSelector) selects(name string) bool {
+	if s.all() {
+		return true
+	}
+	for _, n := range s {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// covers reports whether s selects every detector other does.
+func (s detectorSelector) covers(other detectorSelector) bool {
+	if s.all() {
+		return true
+	}
+	if other.all() {
+		return false
+	}
+	for _, n := range other {
+		if !s.selects(n) {
+			return false
+		}
+	}
+	return true
+}
+
+// validate checks the detector names against known, which must be sorted.
+// kind names the detector family for the error message.
+func (s detectorSelector) validate(kind string, known []string) error {
+	if s.all() {
+		return nil
+	}
+	for _, n := range s {
+		if i := sort.SearchStrings(known, n); i == len(known) || known[i] != n {
+			return fmt.Errorf("unknown %s detector %q (want true or one of %s)", kind, n, strings.Join(known, ", "))
+		}
+	}
+	return nil
+}
+
+// SecretDetectorNames returns every detector name, sorted.
+func SecretDetectorNames() []string {
+	names := []string{SecretHighEntropy}
+	for _, d := range secretDetectors {
+		names = append(names, d.name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// scanSecrets runs every secret detector over the arguments.
+func scanSecrets(args map[string]any, raw []byte) []Finding {
+	return scanArgs(args, raw, scanSecretText)
+}
+
+// scanArgs runs scan over every string in the arguments (the raw JSON
+// when they didn't parse). Findings are ordered by argument path and
+// offset.
+func scanArgs(args map[string]any, raw []byte, scan func(arg, s string) []Finding) []Finding {
+	if args == nil {
+		return scan("", string(raw))
+	}
+
+	var out []Finding
+	var walk func(path string, v any)
+	walk = func(path string, v any) {
+		switch v := v.(type) {
+		case string:
+			out = append(out, scan(path, v)...)
+		case map[string]any:
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				walk(joinArgPath(path, k), v[k])
+			}
+		case []any:
+			for i, item := range v {
+				walk(path+"["+strconv.Itoa(i)+"]", item)
+			}
+		}
+	}
+	walk("", args)
+	return out
+}
+
+// joinArgPath appends an object key to an argument path.
+func joinArgPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// scanSecretText finds secrets in one string. A span claimed by a pattern
+// detector isn't reported again as high entropy.
+func scanSecretText(arg, s string) []Finding {
+	var out []Finding
+	var claimed [][]int
+	for _, d := range secretDetectors {
+		for _, loc := range d.re.FindAllStringIndex(s, -1) {
+			if d.skip != nil && d.skip(s[loc[0]:loc[1]]) {
+				continue
+			}
+			out = append(out, Finding{Type: d.name, Arg: arg, Offset: loc[0], Length: loc[1] - loc[0]})
+			claimed = append(claimed, loc)
+		}
+	}
+
+	for _, loc := range entropyTokenRe.FindAllStringIndex(s, -1) {
+		if overlaps(loc, claimed) || !highEntropy(s[loc[0]:loc[1]]) {
+			continue
+		}
+		out = append(out, Finding{Type: SecretHighEntropy, Arg: arg, Offset: loc[0], Length: loc[1] - loc[0]})
+	}
+
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Offset < out[j].Offset })
+	return out
+}
+
+// overlaps reports whether span intersects any of the claimed spans.
+func overlaps(span []int, claimed [][]int) bool {
+	for _, c := range claimed {
+		if span[0] < c[1] && c[0] < span[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// highEntropy reports whether a token looks like a random credential.
+func highEntropy(tok string) bool {
+	if len(tok) < minEntropyLen {
+		return false
+	}
+	var upper, lower, digit bool
+	counts := make(map[rune]int)
+	for _, r := range tok {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= '0' && r <= '9':
+			digit = true
+		}
+		counts[r]++
+	}
+	if !upper || !lower || !digit {
+		return false
+	}
+
+	n := float64(len(tok))
+	var bits float64
+	for _, c := range counts {
+		p := float64(c) / n
+		bits -= p * math.Log2(p)
+	}
+	return bits >= math.Min(maxEntropyBar, math.Log2(n)-entropySlack)
+}
+
+// secretFindings returns the call's secret findings, scanned on first use.
+func (cc *callContext) secretFindings() []Finding {
+	if !cc.secretsDone {
+		cc.secretsDone = true
+		cc.secrets = scanSecrets(cc.tc.Arguments, cc.tc.RawJSON)
+	}
+	return cc.secrets
+}
+
+// selectedFindings returns the findings the selector counts.
+func selectedFindings(s detectorSelector, findings []Finding) []Finding {
+	var out []Finding
+	for _, f := range findings {
+		if s.selects(f.Type) {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// mergeSelectors combines the selectors of every contains_secret (or
+// contains_pii) field in a rule.
+func mergeSelectors(sels []detectorSelector) detectorSelector {
+	var out detectorSelector
+	for _, s := range sels {
+		if s.all() {
+			return s
+		}
+		out = append(out, s...)
+	}
+	return out
+}
+
+// ruleSecrets returns the findings that made a contains_secret rule match:
+// those selected by any contains_secret field in its match block.
+func ruleSecrets(r *Rule, cc *callContext) []Finding {
+	if r.compiled == nil || len(r.compiled.secrets) == 0 {
+		return nil
+	}
+	return selectedFindings(mergeSelectors(r.compiled.secrets), cc.secretFindings())
+}
+
+// formatFindings renders findings for messages and traces, e.g.
+// "github_token in command at 14".
+func formatFindings(findings []Finding) string {
+	parts := make([]string, len(findings))
+	for i, f := range findings {
+		if f.Arg == "" {
+			parts[i] = fmt.Sprintf("%s at %d", f.Type, f.Offset)
+			continue
+		}
+		parts[i] = fmt.Sprintf("%s in %s at %d", f.Type, f.Arg, f.Offset)
+	}
+	return strings.Join(parts, ", ")
+}
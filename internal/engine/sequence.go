@@ -0,0 +1,234 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ctrlai/ctrlai/internal/extractor"
+)
+
+// SequenceStep is one step of a sequence rule: a match block for a single
+// tool call, and optionally how soon after the previous step it must come.
+//
+//	rules:
+//	  - name: no-exfil-after-export
+//	    sequence:
+//	      - tool: read
+//	        path: "**/exports/*.csv"
+//	      - tool: [web_fetch, exec]
+//	        within: 5
+//	    action: block
+//
+// Within is a number of calls ("5": at most 5 calls after the previous
+// step) or a Go duration ("10m"). Without it, the step may come anywhere
+// after the previous one within the agent's retained history.
+type SequenceStep struct {
+	RuleMatch `yaml:",inline"`
+	Within    string `yaml:"within,omitempty"`
+}
+
+// historySize is how many recent allowed calls are kept per agent. It
+// bounds both memory and how far back a sequence can reach.
+const historySize = 64
+
+// sequenceSpec is a compiled sequence. The last step is matched against
+// the call being evaluated, the earlier ones against the agent's history.
+type sequenceSpec struct {
+	steps []sequenceStep
+}
+
+// sequenceStep is a compiled SequenceStep.
+type sequenceStep struct {
+	match  *RuleMatch
+	c      *compiledMatcher
+	calls  int           // Max calls since the previous step; 0 = no limit.
+	window time.Duration // Max time since the previous step; 0 = no limit.
+}
+
+// compileSequence validates and compiles a rule's sequence: block.
+func compileSequence(r *Rule) (*sequenceSpec, error) {
+	if len(r.Sequence) < 2 {
+		return nil, fmt.Errorf("rule %q: sequence needs at least two steps", r.Name)
+	}
+	if !r.Match.isEmpty() {
+		return nil, fmt.Errorf("rule %q: use either match or sequence, not both", r.Name)
+	}
+
+	spec := &sequenceSpec{}
+	for i := range r.Sequence {
+		step := &r.Sequence[i]
+		where := fmt.Sprintf("sequence[%d]", i)
+		if step.RuleMatch.isEmpty() {
+			return nil, fmt.Errorf("rule %q: %s: empty step", r.Name, where)
+		}
+		c, err := compileMatch(r.Name, where, &step.RuleMatch, 1)
+		if err != nil {
+			return nil, err
+		}
+
+		s := sequenceStep{match: &step.RuleMatch, c: c}
+		if step.Within != "" {
+			if i == 0 {
+				return nil, fmt.Errorf("rule %q: %s: within applies to the steps after the first", r.Name, where)
+			}
+			if n, err := strconv.Atoi(step.Within); err == nil {
+				if n <= 0 || n > historySize {
+					return nil, fmt.Errorf("rule %q: %s: within must be between 1 and %d calls, got %d", r.Name, where, historySize, n)
+				}
+				s.calls = n
+			} else if d, err := time.ParseDuration(step.Within); err == nil && d > 0 {
+				s.window = d
+			} else {
+				return nil, fmt.Errorf("rule %q: %s: within must be a call count or a duration like 10m, got %q", r.Name, where, step.Within)
+			}
+		}
+		spec.steps = append(spec.steps, s)
+	}
+	return spec, nil
+}
+
+// historyEntry is one allowed tool call in an agent's history.
+type historyEntry struct {
+	tc  extractor.ToolCall
+	at  time.Time
+	seq uint64 // Audit sequence number; 0 when not audited.
+}
+
+// callHistory keeps each agent's most recent allowed tool calls for
+// sequence rules. It has its own lock and is kept across Reload.
+type callHistory struct {
+	mu     sync.Mutex
+	agents map[string][]historyEntry // Oldest first, at most historySize.
+}
+
+func newCallHistory() *callHistory {
+	return &callHistory{agents: make(map[string][]historyEntry)}
+}
+
+// record appends a call to the agent's history, dropping the oldest entry
+// once the history is full.
+func (h *callHistory) record(agentID string, e historyEntry) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	entries := h.agents[agentID]
+	if len(entries) == historySize {
+		copy(entries, entries[1:])
+		entries = entries[:historySize-1]
+	}
+	h.agents[agentID] = append(entries, e)
+}
+
+// snapshot returns a copy of the agent's history, oldest first.
+func (h *callHistory) snapshot(agentID string) []historyEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]historyEntry(nil), h.agents[agentID]...)
+}
+
+// RecordCall adds an allowed tool call to the agent's history, where
+// sequence rules look for their earlier steps. seq is the call's audit
+// sequence number, reported when a sequence fires (0 if not audited).
+// Called by the proxy after auditing each allowed call.
+func (e *Engine) RecordCall(agentID string, tc extractor.ToolCall, seq uint64) {
+	e.history.record(agentID, historyEntry{tc: tc, at: time.Now(), seq: seq})
+}
+
+// ranCall returns tc as the agent runs it under d, or false if d doesn't
+// let it run. Redacted and rewritten calls carry their new arguments. This
+// is what the proxy records as history.
+func ranCall(tc extractor.ToolCall, d Decision) (extractor.ToolCall, bool) {
+	switch {
+	case d.Action == "allow":
+		return tc, true
+	case d.Rewrites() && d.Args != nil:
+		tc.Arguments = d.Args
+		if raw, err := json.Marshal(d.Args); err == nil {
+			tc.RawJSON = raw
+		}
+		return tc, true
+	}
+	return tc, false
+}
+
+// matchSequence reports whether the call completes the sequence: the call
+// matches the last step and the agent's history holds the earlier steps,
+// in order and within their windows. On a match it returns the audit
+// sequence numbers of the earlier steps, oldest first.
+func matchSequence(s *sequenceSpec, cc *callContext) ([]uint64, bool) {
+	last := len(s.steps) - 1
+	if !matchesMatch(s.steps[last].match, s.steps[last].c, cc) || cc.history == nil {
+		return nil, false
+	}
+	entries := cc.history.snapshot(cc.agentID)
+	if len(entries) == 0 {
+		return nil, false
+	}
+
+	// Depth-first search backwards from the current call, preferring the
+	// most recent candidate for each step. failed[i][j] records that step
+	// i can't be completed with step i+1 at position j, so each pair is
+	// explored once.
+	contexts := make([]*callContext, len(entries))
+	entryContext := func(j int) *callContext {
+		if contexts[j] == nil {
+			contexts[j] = &callContext{agentID: cc.agentID, tc: entries[j].tc, now: entries[j].at, paths: cc.paths, lists: cc.lists}
+		}
+		return contexts[j]
+	}
+	failed := make([][]bool, last)
+	for i := range failed {
+		failed[i] = make([]bool, len(entries)+1)
+	}
+	seqs := make([]uint64, last)
+
+	var search func(i, next int, nextAt time.Time) bool
+	search = func(i, next int, nextAt time.Time) bool {
+		if i < 0 {
+			return true
+		}
+		if failed[i][next] {
+			return false
+		}
+		limit := s.steps[i+1]
+		for j := next - 1; j >= 0; j-- {
+			if limit.calls > 0 && next-j > limit.calls {
+				break
+			}
+			if limit.window > 0 && nextAt.Sub(entries[j].at) > limit.window {
+				break
+			}
+			if !matchesMatch(s.steps[i].match, s.steps[i].c, entryContext(j)) {
+				continue
+			}
+			if search(i-1, j, entries[j].at) {
+				seqs[i] = entries[j].seq
+				return true
+			}
+		}
+		failed[i][next] = true
+		return false
+	}
+
+	if !search(last-1, len(entries), cc.now) {
+		return nil, false
+	}
+	return seqs, true
+}
+
+// formatSeqs renders audit sequence numbers for messages, e.g. "#12, #15".
+func formatSeqs(seqs []uint64) string {
+	parts := make([]string, len(seqs))
+	for i, s := range seqs {
+		if s == 0 {
+			parts[i] = "(unaudited)"
+			continue
+		}
+		parts[i] = "#" + strconv.FormatUint(s, 10)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,747 @@
+package engine
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Shell analysis for exec commands.
+//
+// command_regex sees the raw command string, which is trivially evaded:
+// `r''m -rf /`, `rm  -r  -f /`, `bash -c "rm -rf /"`, or
+// `$(echo cm0gLXJmIC8= | base64 -d)` all slip past `rm\s+-rf\s+/`. The
+// parser below tokenizes the command the way a POSIX shell would — quotes,
+// escapes, pipelines, lists, subshells, command substitutions, env
+// assignments, redirections — and flattens every simple command it finds
+// into a list of argv vectors. Wrappers (sudo, env, xargs, bash -c, eval,
+// find -exec, ...) are unwrapped and obvious base64/hex payloads are
+// decoded and parsed as well, so `binary:` and `argv_regex:` can match the
+// command that would actually run.
+//
+// This is not a shell interpreter: variables are not expanded, globs are
+// not evaluated, and malformed input is parsed best-effort rather than
+// rejected. The goal is to see through common obfuscation, not to prove
+// a command safe.
+
+// shellCommand is one simple command found in an exec command string.
+type shellCommand struct {
+	Argv      []string // Words after env assignments, quotes removed.
+	Env       []string // Leading NAME=value assignments.
+	Redirects []string // Redirection targets (files, fds).
+	Decoded   bool     // Found inside a decoded base64/hex payload.
+}
+
+// binary returns the basename of argv[0], or "" for an empty command.
+func (c *shellCommand) binary() string {
+	if len(c.Argv) == 0 {
+		return ""
+	}
+	return shellBasename(c.Argv[0])
+}
+
+// argvString returns argv joined by single spaces — the subject for
+// argv_regex. Joining normalizes whitespace and quoting, so `rm  "-rf"  /`
+// and `rm -rf /` produce the same string.
+func (c *shellCommand) argvString() string {
+	return strings.Join(c.Argv, " ")
+}
+
+// shellScript is the flattened result of parsing a command string.
+type shellScript struct {
+	Commands []shellCommand
+	Decoded  []string // Decoded base64/hex payloads, in discovery order.
+}
+
+// Parser limits. Nested wrappers and payloads are recursive, so both the
+// depth and the total amount of work are bounded.
+const (
+	maxShellDepth    = 8
+	maxShellCommands = 256
+)
+
+// parseShell parses a command string into its simple commands.
+func parseShell(src string) *shellScript {
+	s := &shellScript{}
+	s.parse(src, 0, false)
+	return s
+}
+
+// reservedWords are shell keywords that can precede a command in a
+// compound statement (`if rm -rf /; then ...`). They are dropped from
+// the start of argv so the real command becomes argv[0].
+var reservedWords = map[string]bool{
+	"!": true, "{": true, "}": true, "if": true, "then": true, "else": true,
+	"elif": true, "fi": true, "do": true, "done": true, "while": true,
+	"until": true, "esac": true,
+}
+
+// parse tokenizes src and appends every simple command it contains.
+func (s *shellScript) parse(src string, depth int, decoded bool) {
+	if depth > maxShellDepth {
+		return
+	}
+
+	lx := &shellLexer{src: src}
+	var cur shellCommand
+	redirect := false // Next word is a redirection target.
+
+	flush := func() {
+		if len(cur.Argv) > 0 || len(cur.Env) > 0 {
+			cur.Decoded = decoded
+			s.add(cur, depth)
+		}
+		cur = shellCommand{}
+		redirect = false
+	}
+
+	for len(s.Commands) < maxShellCommands {
+		tok := lx.next()
+
+		// Command substitutions are commands in their own right, wherever
+		// they appear.
+		for _, sub := range tok.subs {
+			s.parse(sub, depth+1, decoded)
+		}
+
+		switch tok.kind {
+		case tokEOF:
+			flush()
+			return
+
+		case tokOp:
+			if isRedirectOp(tok.text) {
+				redirect = true
+				continue
+			}
+			// |, ||, &&, ;, &, newline, and subshell parens all end the
+			// current simple command.
+			flush()
+
+		case tokWord:
+			switch {
+			case redirect:
+				cur.Redirects = append(cur.Redirects, tok.text)
+				redirect = false
+			case len(cur.Argv) == 0 && isAssignment(tok.text):
+				cur.Env = append(cur.Env, tok.text)
+			case len(cur.Argv) == 0 && !tok.quoted && reservedWords[tok.text]:
+				// Skip keyword.
+			default:
+				cur.Argv = append(cur.Argv, tok.text)
+			}
+		}
+	}
+}
+
+// add records a simple command and then looks inside it: wrapped
+// commands, inline scripts, and encoded payloads in its arguments.
+func (s *shellScript) add(cmd shellCommand, depth int) {
+	if len(s.Commands) >= maxShellCommands {
+		return
+	}
+	s.Commands = append(s.Commands, cmd)
+
+	if len(cmd.Argv) == 0 || depth > maxShellDepth {
+		return
+	}
+
+	if script, ok := inlineScript(cmd.Argv); ok {
+		s.parse(script, depth+1, cmd.Decoded)
+	}
+
+	if inner := unwrapCommand(cmd.Argv); len(inner) > 0 {
+		s.add(shellCommand{Argv: inner, Decoded: cmd.Decoded}, depth+1)
+	}
+
+	for _, arg := range cmd.Argv[1:] {
+		if payload, ok := decodePayload(arg); ok {
+			s.Decoded = append(s.Decoded, payload)
+			s.parse(payload, depth+1, true)
+		}
+	}
+}
+
+// inlineScript returns the script passed to a shell with -c, to eval, or
+// to watch — commands that take shell source as an argument.
+func inlineScript(argv []string) (string, bool) {
+	switch shellBasename(argv[0]) {
+	case "sh", "bash", "zsh", "dash", "ksh", "ash", "fish":
+		for i := 1; i < len(argv)-1; i++ {
+			a := argv[i]
+			if !strings.HasPrefix(a, "-") || strings.HasPrefix(a, "--") {
+				continue
+			}
+			// -c, or a cluster containing it: -lc, -ec, -xc.
+			if strings.ContainsRune(a[1:], 'c') {
+				return argv[i+1], true
+			}
+		}
+	case "eval", "watch":
+		if len(argv) > 1 {
+			return strings.Join(argv[1:], " "), true
+		}
+	}
+	return "", false
+}
+
+// wrapperOptsWithValue lists, per wrapper, the short options that consume
+// the following word. Needed to find where the wrapped command starts:
+// in `sudo -u root rm -rf /` the command is rm, not root.
+var wrapperOptsWithValue = map[string]string{
+	"sudo":    "ughpCDrtU",
+	"doas":    "uC",
+	"nice":    "n",
+	"ionice":  "cnp",
+	"timeout": "sk",
+	"xargs":   "InPLdEsa",
+	"stdbuf":  "ioe",
+	"chroot":  "",
+	"env":     "uCS",
+	"nohup":   "",
+	"time":    "fo",
+	"exec":    "a",
+	"command": "",
+	"builtin": "",
+	"setsid":  "",
+	"strace":  "eoopsu",
+}
+
+// unwrapCommand returns the command run by a wrapper such as sudo, env,
+// xargs, or find -exec. Returns nil if argv is not a known wrapper or
+// wraps nothing.
+func unwrapCommand(argv []string) []string {
+	name := shellBasename(argv[0])
+
+	if name == "find" {
+		for i, a := range argv {
+			if a == "-exec" || a == "-execdir" || a == "-ok" || a == "-okdir" {
+				inner := argv[i+1:]
+				for j, w := range inner {
+					if w == ";" || w == "+" {
+						inner = inner[:j]
+						break
+					}
+				}
+				return inner
+			}
+		}
+		return nil
+	}
+
+	withValue, ok := wrapperOptsWithValue[name]
+	if !ok {
+		return nil
+	}
+
+	i := 1
+	for i < len(argv) {
+		a := argv[i]
+		if a == "--" {
+			i++
+			break
+		}
+		if name == "env" && isAssignment(a) {
+			i++
+			continue
+		}
+		if !strings.HasPrefix(a, "-") || a == "-" {
+			break
+		}
+		i++
+		// A short option taking a value consumes the next word unless the
+		// value is attached (-uroot).
+		if !strings.HasPrefix(a, "--") && len(a) == 2 && strings.IndexByte(withValue, a[1]) >= 0 {
+			i++
+		}
+	}
+
+	// timeout and chroot take a positional argument before the command.
+	if (name == "timeout" || name == "chroot") && i < len(argv) {
+		i++
+	}
+
+	if i >= len(argv) {
+		return nil
+	}
+	return argv[i:]
+}
+
+// decodePayload decodes an argument that is obviously an encoded command:
+// base64, a bare hex string, or \xNN escapes. The result must be printable
+// text — random words that happen to be valid base64 decode to binary
+// garbage and are ignored.
+func decodePayload(arg string) (string, bool) {
+	if strings.Contains(arg, `\x`) {
+		if out, ok := decodeHexEscapes(arg); ok && isPrintableText(out) {
+			return out, true
+		}
+	}
+
+	if len(arg) < 8 {
+		return "", false
+	}
+
+	if len(arg)%2 == 0 && isHexString(arg) {
+		if b, err := hex.DecodeString(arg); err == nil && isPrintableText(string(b)) {
+			return string(b), true
+		}
+	}
+
+	if isBase64String(arg) {
+		for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+			if b, err := enc.DecodeString(arg); err == nil {
+				if isPrintableText(string(b)) {
+					return string(b), true
+				}
+				break
+			}
+		}
+	}
+
+	return "", false
+}
+
+// decodeHexEscapes expands \xNN sequences, leaving other text as-is.
+// Requires at least two escapes so a stray "\x" in a path is ignored.
+func decodeHexEscapes(s string) (string, bool) {
+	var b strings.Builder
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) && s[i+1] == 'x' {
+			if v, err := strconv.ParseUint(s[i+2:i+4], 16, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				n++
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String(), n >= 2
+}
+
+// isPrintableText reports whether s looks like decoded shell source:
+// valid UTF-8, no control characters besides whitespace, and at least one
+// letter.
+func isPrintableText(s string) bool {
+	if len(s) < 2 || !utf8.ValidString(s) {
+		return false
+	}
+	letter := false
+	for _, r := range s {
+		switch {
+		case r == '\n' || r == '\t' || r == '\r':
+		case r < 0x20 || r == 0x7f || r == utf8.RuneError:
+			return false
+		case r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z':
+			letter = true
+		}
+	}
+	return letter
+}
+
+func isHexString(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func isBase64String(s string) bool {
+	body := strings.TrimRight(s, "=")
+	if len(s)-len(body) > 2 || body == "" {
+		return false
+	}
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' ||
+			c == '+' || c == '/' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// isAssignment reports whether a word is a NAME=value env assignment.
+func isAssignment(w string) bool {
+	eq := strings.IndexByte(w, '=')
+	if eq <= 0 {
+		return false
+	}
+	for i := 0; i < eq; i++ {
+		c := w[i]
+		if !(c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+func isRedirectOp(op string) bool {
+	return strings.ContainsAny(op, "<>")
+}
+
+// shellBasename returns the last path element of a command name, so
+// /usr/bin/rm and ./rm both match `binary: rm`.
+func shellBasename(name string) string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
+
+// --- Lexer ---
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokOp
+)
+
+// shellToken is a word or operator. subs holds the source of any command
+// substitutions ($(...), `...`, <(...)) found inside a word.
+type shellToken struct {
+	kind   tokenKind
+	text   string
+	quoted bool
+	subs   []string
+}
+
+type shellLexer struct {
+	src string
+	pos int
+}
+
+func (lx *shellLexer) peek(off int) byte {
+	if lx.pos+off < len(lx.src) {
+		return lx.src[lx.pos+off]
+	}
+	return 0
+}
+
+// next returns the next token.
+func (lx *shellLexer) next() shellToken {
+	// Skip blanks and line continuations.
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+		if c == ' ' || c == '\t' || c == '\r' {
+			lx.pos++
+		} else if c == '\\' && lx.peek(1) == '\n' {
+			lx.pos += 2
+		} else {
+			break
+		}
+	}
+	if lx.pos >= len(lx.src) {
+		return shellToken{kind: tokEOF}
+	}
+
+	c := lx.src[lx.pos]
+
+	// Comments run to end of line.
+	if c == '#' {
+		for lx.pos < len(lx.src) && lx.src[lx.pos] != '\n' {
+			lx.pos++
+		}
+		return lx.next()
+	}
+
+	// Process substitution <(...) / >(...) is a word, not a redirection.
+	if (c == '<' || c == '>') && lx.peek(1) == '(' {
+		lx.pos += 2
+		inner := lx.readParen()
+		return shellToken{kind: tokWord, text: string(c) + "(" + inner + ")", subs: []string{inner}}
+	}
+
+	if op := lx.readOp(); op != "" {
+		return shellToken{kind: tokOp, text: op}
+	}
+
+	return lx.readWord()
+}
+
+// shellOps lists operators longest first so the lexer is greedy.
+var shellOps = []string{
+	"&>>", "<<<", "<<-",
+	"&&", "||", ";;", "|&", ">>", "<<", "<&", ">&", "<>", ">|", "&>",
+	"|", "&", ";", "\n", "(", ")", "<", ">",
+}
+
+func (lx *shellLexer) readOp() string {
+	rest := lx.src[lx.pos:]
+	for _, op := range shellOps {
+		if strings.HasPrefix(rest, op) {
+			lx.pos += len(op)
+			return op
+		}
+	}
+	return ""
+}
+
+func isWordBreak(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '|', '&', ';', '(', ')', '<', '>':
+		return true
+	}
+	return false
+}
+
+// readWord reads one word, removing quotes and recording substitutions.
+func (lx *shellLexer) readWord() shellToken {
+	var b strings.Builder
+	tok := shellToken{kind: tokWord}
+	start := lx.pos
+
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+		if isWordBreak(c) {
+			break
+		}
+
+		switch c {
+		case '\\':
+			if lx.pos+1 < len(lx.src) {
+				if lx.src[lx.pos+1] != '\n' {
+					b.WriteByte(lx.src[lx.pos+1])
+				}
+				lx.pos += 2
+			} else {
+				lx.pos++
+			}
+
+		case '\'':
+			tok.quoted = true
+			end := strings.IndexByte(lx.src[lx.pos+1:], '\'')
+			if end < 0 {
+				b.WriteString(lx.src[lx.pos+1:])
+				lx.pos = len(lx.src)
+			} else {
+				b.WriteString(lx.src[lx.pos+1 : lx.pos+1+end])
+				lx.pos += end + 2
+			}
+
+		case '"':
+			tok.quoted = true
+			lx.pos++
+			lx.readDoubleQuoted(&b, &tok)
+
+		case '$':
+			lx.readDollar(&b, &tok)
+
+		case '`':
+			lx.pos++
+			inner := lx.readBacktick()
+			tok.subs = append(tok.subs, inner)
+			b.WriteString("`" + inner + "`")
+
+		default:
+			b.WriteByte(c)
+			lx.pos++
+		}
+	}
+
+	tok.text = b.String()
+
+	// A bare fd number directly before a redirection (2>, 1>&2) belongs to
+	// the operator, not argv.
+	if !tok.quoted && lx.pos < len(lx.src) && (lx.src[lx.pos] == '<' || lx.src[lx.pos] == '>') && isDigits(lx.src[start:lx.pos]) {
+		return shellToken{kind: tokOp, text: lx.readOp()}
+	}
+	return tok
+}
+
+// readDoubleQuoted reads up to the closing double quote. Only \, $, `,
+// and " are special inside.
+func (lx *shellLexer) readDoubleQuoted(b *strings.Builder, tok *shellToken) {
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+		switch c {
+		case '"':
+			lx.pos++
+			return
+		case '\\':
+			n := lx.peek(1)
+			if n == '"' || n == '\\' || n == '$' || n == '`' {
+				b.WriteByte(n)
+				lx.pos += 2
+			} else if n == '\n' {
+				lx.pos += 2
+			} else {
+				b.WriteByte(c)
+				lx.pos++
+			}
+		case '$':
+			lx.readDollar(b, tok)
+		case '`':
+			lx.pos++
+			inner := lx.readBacktick()
+			tok.subs = append(tok.subs, inner)
+			b.WriteString("`" + inner + "`")
+		default:
+			b.WriteByte(c)
+			lx.pos++
+		}
+	}
+}
+
+// readDollar handles $(...), $((...)), ${...}, and $'...' at lx.pos.
+// Substitutions are kept verbatim in the word (they are not evaluated)
+// and their source is recorded for parsing.
+func (lx *shellLexer) readDollar(b *strings.Builder, tok *shellToken) {
+	switch lx.peek(1) {
+	case '(':
+		if lx.peek(2) == '(' {
+			// Arithmetic expansion — not a command.
+			lx.pos += 3
+			inner := lx.readParen()
+			if lx.peek(0) == ')' {
+				lx.pos++
+			}
+			b.WriteString("$((" + inner + "))")
+			return
+		}
+		lx.pos += 2
+		inner := lx.readParen()
+		tok.subs = append(tok.subs, inner)
+		b.WriteString("$(" + inner + ")")
+
+	case '{':
+		end := strings.IndexByte(lx.src[lx.pos:], '}')
+		if end < 0 {
+			end = len(lx.src) - lx.pos - 1
+		}
+		b.WriteString(lx.src[lx.pos : lx.pos+end+1])
+		lx.pos += end + 1
+
+	case '\'':
+		// ANSI-C quoting: $'\x72\x6d' is "rm".
+		tok.quoted = true
+		lx.pos += 2
+		for lx.pos < len(lx.src) && lx.src[lx.pos] != '\'' {
+			if lx.src[lx.pos] == '\\' && lx.pos+1 < len(lx.src) {
+				lx.pos += lx.readANSIEscape(b)
+				continue
+			}
+			b.WriteByte(lx.src[lx.pos])
+			lx.pos++
+		}
+		lx.pos++
+
+	default:
+		b.WriteByte('$')
+		lx.pos++
+	}
+}
+
+// readANSIEscape decodes one backslash escape inside $'...' and returns
+// the number of source bytes consumed.
+func (lx *shellLexer) readANSIEscape(b *strings.Builder) int {
+	rest := lx.src[lx.pos+1:]
+	switch rest[0] {
+	case 'n':
+		b.WriteByte('\n')
+	case 't':
+		b.WriteByte('\t')
+	case 'r':
+		b.WriteByte('\r')
+	case 'x':
+		n := 0
+		for n < 2 && n+1 < len(rest) && isHexString(rest[1+n:2+n]) {
+			n++
+		}
+		if n == 0 {
+			b.WriteString(`\x`)
+			return 2
+		}
+		v, _ := strconv.ParseUint(rest[1:1+n], 16, 8)
+		b.WriteByte(byte(v))
+		return 2 + n
+	case '0', '1', '2', '3', '4', '5', '6', '7':
+		n := 0
+		for n < 3 && n < len(rest) && rest[n] >= '0' && rest[n] <= '7' {
+			n++
+		}
+		v, _ := strconv.ParseUint(rest[:n], 8, 8)
+		b.WriteByte(byte(v))
+		return 1 + n
+	default:
+		b.WriteByte(rest[0])
+	}
+	return 2
+}
+
+// readParen reads up to the ')' matching an already-consumed '(' and
+// returns the text in between, honoring quotes and nesting.
+func (lx *shellLexer) readParen() string {
+	start := lx.pos
+	depth := 1
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+		switch c {
+		case '\\':
+			lx.pos++
+		case '\'':
+			if end := strings.IndexByte(lx.src[lx.pos+1:], '\''); end >= 0 {
+				lx.pos += end + 1
+			}
+		case '"':
+			for lx.pos++; lx.pos < len(lx.src) && lx.src[lx.pos] != '"'; lx.pos++ {
+				if lx.src[lx.pos] == '\\' {
+					lx.pos++
+				}
+			}
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				inner := lx.src[start:lx.pos]
+				lx.pos++
+				return inner
+			}
+		}
+		lx.pos++
+	}
+	return lx.src[start:]
+}
+
+// readBacktick reads up to the closing backtick of an already-consumed
+// opening one. \` inside is an escaped backtick.
+func (lx *shellLexer) readBacktick() string {
+	var b strings.Builder
+	for lx.pos < len(lx.src) {
+		c := lx.src[lx.pos]
+		if c == '`' {
+			lx.pos++
+			return b.String()
+		}
+		if c == '\\' && lx.pos+1 < len(lx.src) {
+			b.WriteByte(lx.src[lx.pos+1])
+			lx.pos += 2
+			continue
+		}
+		b.WriteByte(c)
+		lx.pos++
+	}
+	return b.String()
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
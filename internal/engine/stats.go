@@ -0,0 +1,189 @@
+package engine
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Rule hit scopes. Built-in, custom and X-Ctrl-Rules runtime rules are
+// counted separately, so a header rule never adds to a file rule of the
+// same name.
+const (
+	ScopeBuiltin = "builtin"
+	ScopeCustom  = "custom"
+	ScopeRuntime = "runtime"
+)
+
+// RuleStats counts how often a rule has fired. Shown by `ctrlai rules
+// list`, /api/rules and the dashboard, and persisted to rule_stats.yaml.
+type RuleStats struct {
+	Matches   uint64    `yaml:"matches" json:"matches"` // Calls the rule matched, including monitor and within-budget matches.
+	Blocks    uint64    `yaml:"blocks" json:"blocks"`   // Calls the rule blocked.
+	LastMatch time.Time `yaml:"last_match,omitempty" json:"last_match,omitempty"`
+	LastAgent string    `yaml:"last_agent,omitempty" json:"last_agent,omitempty"`
+}
+
+// ruleStats holds the hit counters for every rule, keyed by scope and
+// rule name. Like the rate limiter it has its own lock, so evaluations
+// holding the engine's read lock can update it, and it is kept across
+// Reload.
+type ruleStats struct {
+	mu    sync.Mutex
+	hits  map[string]map[string]*RuleStats // scope -> rule name -> counters.
+	path  string                           // rule_stats.yaml; empty = not persisted.
+	dirty bool                             // Changed since the last save.
+}
+
+// statsFile is the YAML layout of rule_stats.yaml.
+type statsFile struct {
+	Builtin map[string]*RuleStats `yaml:"builtin,omitempty"`
+	Custom  map[string]*RuleStats `yaml:"custom,omitempty"`
+	Runtime map[string]*RuleStats `yaml:"runtime,omitempty"`
+}
+
+func newRuleStats() *ruleStats {
+	return &ruleStats{hits: make(map[string]map[string]*RuleStats)}
+}
+
+// record counts a match of the named rule in scope by agentID at now.
+func (s *ruleStats) record(scope, rule, agentID string, blocked bool, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byName := s.hits[scope]
+	if byName == nil {
+		byName = make(map[string]*RuleStats)
+		s.hits[scope] = byName
+	}
+	st := byName[rule]
+	if st == nil {
+		st = &RuleStats{}
+		byName[rule] = st
+	}
+	st.Matches++
+	if blocked {
+		st.Blocks++
+	}
+	st.LastMatch = now.UTC()
+	st.LastAgent = agentID
+	s.dirty = true
+}
+
+// get returns a copy of one rule's counters (zero if it never matched).
+func (s *ruleStats) get(scope, rule string) RuleStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if st := s.hits[scope][rule]; st != nil {
+		return *st
+	}
+	return RuleStats{}
+}
+
+// names returns the rule names with counters in scope, sorted.
+func (s *ruleStats) names(scope string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.hits[scope]))
+	for name := range s.hits[scope] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadStats reads persisted rule hit counters from path and remembers the
+// path for SaveStats. A missing file is not an error.
+func (e *Engine) LoadStats(path string) error {
+	s := e.stats
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.path = path
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("reading rule stats %s: %w", path, err)
+	}
+
+	var file statsFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("parsing rule stats %s: %w", path, err)
+	}
+	for scope, byName := range map[string]map[string]*RuleStats{
+		ScopeBuiltin: file.Builtin, ScopeCustom: file.Custom, ScopeRuntime: file.Runtime,
+	} {
+		for name, st := range byName {
+			if st == nil {
+				delete(byName, name)
+			}
+		}
+		if len(byName) > 0 {
+			s.hits[scope] = byName
+		}
+	}
+	return nil
+}
+
+// SaveStats writes the rule hit counters to the path given to LoadStats.
+// It does nothing when no path is set or nothing changed since the last
+// save, so it is cheap to call on a timer.
+func (e *Engine) SaveStats() error {
+	s := e.stats
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.path == "" || !s.dirty {
+		return nil
+	}
+	data, err := yaml.Marshal(&statsFile{
+		Builtin: s.hits[ScopeBuiltin],
+		Custom:  s.hits[ScopeCustom],
+		Runtime: s.hits[ScopeRuntime],
+	})
+	if err != nil {
+		return fmt.Errorf("marshaling rule stats: %w", err)
+	}
+	if err := os.WriteFile(s.path, data, 0o644); err != nil {
+		return fmt.Errorf("writing rule stats %s: %w", s.path, err)
+	}
+	s.dirty = false
+	return nil
+}
+
+// RuleStats returns the counters for one rule in the given scope.
+func (e *Engine) RuleStats(scope, rule string) RuleStats {
+	return e.stats.get(scope, rule)
+}
+
+// ruleScope returns the stats scope for a file-based rule.
+func ruleScope(r *Rule) string {
+	if r.Builtin {
+		return ScopeBuiltin
+	}
+	return ScopeCustom
+}
+
+// hit records a match of r for this call, unless hits aren't counted.
+func (cc *callContext) hit(r *Rule, blocked bool) {
+	if cc.stats == nil || cc.dryRun {
+		return
+	}
+	cc.stats.record(cc.scope(r), r.Name, cc.agentID, blocked, cc.now)
+}
+
+// scope returns the stats scope of r as evaluated in this call.
+func (cc *callContext) scope(r *Rule) string {
+	if cc.runtime {
+		return ScopeRuntime
+	}
+	return ruleScope(r)
+}
@@ -0,0 +1,122 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/ctrlai/ctrlai/internal/extractor"
+	"gopkg.in/yaml.v3"
+)
+
+// TestSuite is a policy test file (`ctrlai rules test --suite`): a list of
+// tool calls and the decision each one is expected to get.
+//
+//	cases:
+//	  - name: ssh keys are off limits
+//	    agent: main
+//	    tool: read
+//	    args: {path: ~/.ssh/id_rsa}
+//	    decision: block
+//	    rule: block_ssh_private_keys
+//	  - name: plain ls is fine
+//	    tool: exec
+//	    args: {command: ls -la}
+//	    decision: allow
+type TestSuite struct {
+	Cases []TestCase `yaml:"cases"`
+}
+
+// TestCase is one tool call in a TestSuite. Decision is "allow", "block",
+// "ask", "redact", "rewrite", "delegate", or "would_block" (allowed, but a
+// monitor rule would have blocked it). Rule, if set, must also match the
+// deciding rule's name.
+type TestCase struct {
+	Name     string         `yaml:"name,omitempty"`
+	Agent    string         `yaml:"agent,omitempty"`
+	Tool     string         `yaml:"tool"`
+	Args     map[string]any `yaml:"args,omitempty"`
+	Decision string         `yaml:"decision"`
+	Rule     string         `yaml:"rule,omitempty"`
+}
+
+// TestResult is the outcome of running one TestCase.
+type TestResult struct {
+	Case     TestCase
+	Decision string // Decision actually reached, in TestCase terms.
+	Rule     string // Rule that reached it ("" when allowed by no rule).
+	Pass     bool
+}
+
+// LoadTestSuite reads and validates a test suite file.
+func LoadTestSuite(path string) (*TestSuite, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading test suite: %w", err)
+	}
+
+	var suite TestSuite
+	if err := yaml.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("parsing test suite: %w", err)
+	}
+	if len(suite.Cases) == 0 {
+		return nil, fmt.Errorf("test suite %s has no cases", path)
+	}
+
+	for i := range suite.Cases {
+		c := &suite.Cases[i]
+		if c.Name == "" {
+			c.Name = fmt.Sprintf("case %d", i+1)
+		}
+		if c.Tool == "" {
+			return nil, fmt.Errorf("%s: tool is required", c.Name)
+		}
+		switch c.Decision {
+		case "allow", "block", "ask", "redact", "rewrite", "delegate", "would_block":
+		case "":
+			return nil, fmt.Errorf("%s: decision is required", c.Name)
+		default:
+			return nil, fmt.Errorf("%s: unknown decision %q (want allow, block, ask, redact, rewrite, delegate, or would_block)", c.Name, c.Decision)
+		}
+	}
+	return &suite, nil
+}
+
+// RunSuite evaluates every case through Evaluate, as the proxy would for
+// a tool call from that agent. Allowed, redacted and rewritten cases are
+// recorded in the agent's history, as the proxy records them, so sequence
+// rules see the earlier cases.
+func (e *Engine) RunSuite(suite *TestSuite) []TestResult {
+	results := make([]TestResult, 0, len(suite.Cases))
+	for _, c := range suite.Cases {
+		call := testCaseToolCall(c)
+		d := e.Evaluate(c.Agent, call)
+		if ran, ok := ranCall(call, d); ok {
+			e.RecordCall(c.Agent, ran, 0)
+		}
+
+		got, rule := d.Action, d.Rule
+		if got == "allow" && len(d.WouldBlock) > 0 {
+			got, rule = "would_block", d.WouldBlock[0].Rule
+		}
+
+		pass := got == c.Decision
+		if c.Rule != "" && rule != c.Rule {
+			pass = false
+		}
+		results = append(results, TestResult{Case: c, Decision: got, Rule: rule, Pass: pass})
+	}
+	return results
+}
+
+// testCaseToolCall builds the tool call for a case, with arguments in the
+// same shape the extractor produces from provider JSON.
+func testCaseToolCall(c TestCase) extractor.ToolCall {
+	args, _ := normalizeJSONValue(c.Args).(map[string]any)
+	if args == nil {
+		args = map[string]any{}
+	}
+	raw, _ := json.Marshal(args)
+	return extractor.ToolCall{Name: strings.TrimSpace(c.Tool), Arguments: args, RawJSON: raw}
+}
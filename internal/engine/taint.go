@@ -0,0 +1,358 @@
+package engine
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/ctrlai/ctrlai/internal/extractor"
+)
+
+// TaintSettings configures taint tracking (the taint: section of
+// rules.yaml).
+//
+//	taint:
+//	  ttl: 30m
+//
+// A rule with `sensitive: true` marks the calls it matches. When the
+// agent's next request carries their results, the proxy fingerprints the
+// result text; for TTL afterwards, `tainted: true` matches any call by the
+// same agent whose arguments contain a long enough piece of it.
+type TaintSettings struct {
+	TTL string `yaml:"ttl,omitempty"` // Go duration (default 1h).
+}
+
+const (
+	// defaultTaintTTL is how long a sensitive result taints an agent.
+	defaultTaintTTL = time.Hour
+
+	// taintGram is the length, in normalized characters, of the substrings
+	// that are hashed. taintWindow is the winnowing window: one hash per
+	// window is kept, which guarantees that any shared run of at least
+	// taintMinMatch normalized characters is detected.
+	taintGram     = 16
+	taintWindow   = 8
+	taintMinMatch = taintGram + taintWindow - 1
+
+	// maxTaintContent caps how much of one result is fingerprinted, and
+	// maxTaintSources how many results are kept per agent (oldest go first).
+	maxTaintContent = 256 << 10
+	maxTaintSources = 64
+
+	// taintBase is the rolling hash multiplier.
+	taintBase = 1099511628211
+)
+
+// compileTaintSettings validates the taint: section.
+func compileTaintSettings(s TaintSettings) (time.Duration, error) {
+	if s.TTL == "" {
+		return defaultTaintTTL, nil
+	}
+	d, err := time.ParseDuration(s.TTL)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("taint.ttl: must be a positive duration like 30m, got %q", s.TTL)
+	}
+	return d, nil
+}
+
+// taintSource is the fingerprinted result of one sensitive call.
+type taintSource struct {
+	callID  string
+	tool    string
+	seq     uint64 // Audit seq of the sensitive call; 0 if not audited.
+	expires time.Time
+	hashes  map[uint64]struct{} // Immutable once built.
+}
+
+// pendingResult is a sensitive call whose result hasn't been seen yet.
+type pendingResult struct {
+	tool    string
+	seq     uint64
+	expires time.Time
+}
+
+// taintTracker holds per-agent taint state. Like the rate limiter it has
+// its own lock and is kept across Reload.
+type taintTracker struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	pending map[string]map[string]pendingResult // agent -> call ID -> call.
+	sources map[string][]*taintSource           // agent -> results, oldest first.
+}
+
+func newTaintTracker() *taintTracker {
+	return &taintTracker{
+		ttl:     defaultTaintTTL,
+		pending: make(map[string]map[string]pendingResult),
+		sources: make(map[string][]*taintSource),
+	}
+}
+
+func (t *taintTracker) setTTL(ttl time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.ttl = ttl
+}
+
+// markSensitive remembers a sensitive call so its result is fingerprinted.
+func (t *taintTracker) markSensitive(agentID, callID, tool string, seq uint64, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	calls := t.pending[agentID]
+	if calls == nil {
+		calls = make(map[string]pendingResult)
+		t.pending[agentID] = calls
+	}
+	calls[callID] = pendingResult{tool: tool, seq: seq, expires: now.Add(t.ttl)}
+}
+
+// awaiting reports whether any of the agent's sensitive calls still wait
+// for their result.
+func (t *taintTracker) awaiting(agentID string, now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for id, p := range t.pending[agentID] {
+		if now.After(p.expires) {
+			delete(t.pending[agentID], id)
+		}
+	}
+	if len(t.pending[agentID]) == 0 {
+		delete(t.pending, agentID)
+		return false
+	}
+	return true
+}
+
+// observe fingerprints the results of pending sensitive calls. Returns how
+// many results were added.
+func (t *taintTracker) observe(agentID string, results []extractor.ToolResult, now time.Time) int {
+	t.mu.Lock()
+	calls := t.pending[agentID]
+	var found []extractor.ToolResult
+	var meta []pendingResult
+	for _, r := range results {
+		if p, ok := calls[r.ID]; ok {
+			found = append(found, r)
+			meta = append(meta, p)
+			delete(calls, r.ID)
+		}
+	}
+	ttl := t.ttl
+	t.mu.Unlock()
+
+	if len(found) == 0 {
+		return 0
+	}
+
+	// Hash outside the lock; results can be large.
+	var added []*taintSource
+	for i, r := range found {
+		hashes := winnow(normalizeTaint(r.Content, maxTaintContent))
+		if len(hashes) == 0 {
+			continue // Too short to fingerprint.
+		}
+		added = append(added, &taintSource{
+			callID: r.ID, tool: meta[i].tool, seq: meta[i].seq,
+			expires: now.Add(ttl), hashes: hashes,
+		})
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	sources := append(t.liveSources(agentID, now), added...)
+	if len(sources) > maxTaintSources {
+		sources = sources[len(sources)-maxTaintSources:]
+	}
+	t.sources[agentID] = sources
+	return len(added)
+}
+
+// liveSources drops the agent's expired sources and returns the rest.
+// Caller must hold the mutex.
+func (t *taintTracker) liveSources(agentID string, now time.Time) []*taintSource {
+	sources := t.sources[agentID]
+	live := sources[:0]
+	for _, s := range sources {
+		if now.Before(s.expires) {
+			live = append(live, s)
+		}
+	}
+	if len(live) == 0 {
+		delete(t.sources, agentID)
+		return nil
+	}
+	t.sources[agentID] = live
+	return live
+}
+
+// match returns the newest live source whose fingerprints appear in the
+// tool call's arguments, or nil.
+func (t *taintTracker) match(agentID string, tc extractor.ToolCall, now time.Time) *taintSource {
+	t.mu.Lock()
+	sources := append([]*taintSource(nil), t.liveSources(agentID, now)...)
+	t.mu.Unlock()
+	if len(sources) == 0 {
+		return nil
+	}
+
+	for _, text := range argumentTexts(tc) {
+		norm := normalizeTaint(text, maxTaintContent)
+		for _, h := range gramHashes(norm) {
+			for i := len(sources) - 1; i >= 0; i-- {
+				if _, ok := sources[i].hashes[h]; ok {
+					return sources[i]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// argumentTexts returns every string in a tool call's arguments, plus the
+// URL-decoded form of those that are percent-encoded, so content smuggled
+// in a query string is found too.
+func argumentTexts(tc extractor.ToolCall) []string {
+	var out []string
+	add := func(s string) {
+		out = append(out, s)
+		if strings.ContainsAny(s, "%+") {
+			if dec, err := url.QueryUnescape(s); err == nil && dec != s {
+				out = append(out, dec)
+			}
+		}
+	}
+	if tc.Arguments == nil {
+		add(string(tc.RawJSON))
+		return out
+	}
+
+	var walk func(v any)
+	walk = func(v any) {
+		switch v := v.(type) {
+		case string:
+			add(v)
+		case map[string]any:
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(tc.Arguments)
+	return out
+}
+
+// normalizeTaint keeps only letters and digits, lowercased, so the same
+// content matches across quoting, escaping, and reformatting. At most
+// limit bytes of s are read.
+func normalizeTaint(s string, limit int) []rune {
+	if len(s) > limit {
+		s = s[:limit]
+	}
+	out := make([]rune, 0, len(s))
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			out = append(out, unicode.ToLower(r))
+		}
+	}
+	return out
+}
+
+// gramHashes returns the rolling hash of every taintGram-long substring.
+func gramHashes(s []rune) []uint64 {
+	if len(s) < taintGram {
+		return nil
+	}
+	var pow uint64 = 1 // taintBase^(taintGram-1)
+	for i := 0; i < taintGram-1; i++ {
+		pow *= taintBase
+	}
+
+	hashes := make([]uint64, 0, len(s)-taintGram+1)
+	var h uint64
+	for i, r := range s {
+		if i >= taintGram {
+			h -= uint64(s[i-taintGram]) * pow
+		}
+		h = h*taintBase + uint64(r)
+		if i >= taintGram-1 {
+			hashes = append(hashes, h)
+		}
+	}
+	return hashes
+}
+
+// winnow selects fingerprints from s: the minimum gram hash of every
+// window of taintWindow consecutive grams. Returns nil when s is shorter
+// than taintMinMatch.
+func winnow(s []rune) map[uint64]struct{} {
+	if len(s) < taintMinMatch {
+		return nil
+	}
+	hashes := gramHashes(s)
+	out := make(map[uint64]struct{})
+	for i := 0; i+taintWindow <= len(hashes); i++ {
+		lo := hashes[i]
+		for _, h := range hashes[i+1 : i+taintWindow] {
+			if h < lo {
+				lo = h
+			}
+		}
+		out[lo] = struct{}{}
+	}
+	return out
+}
+
+// MarkSensitive records that a call matched a `sensitive: true` rule, so
+// its result is fingerprinted when the agent sends it back. seq is the
+// call's audit sequence number (0 if not audited).
+func (e *Engine) MarkSensitive(agentID string, tc extractor.ToolCall, seq uint64) {
+	if tc.ID == "" {
+		return // Results are matched by ID.
+	}
+	e.taint.markSensitive(agentID, tc.ID, tc.Name, seq, time.Now())
+}
+
+// AwaitingResults reports whether the agent has sensitive calls whose
+// results haven't been seen yet. The proxy checks this before parsing
+// tool results out of a request body.
+func (e *Engine) AwaitingResults(agentID string) bool {
+	return e.taint.awaiting(agentID, time.Now())
+}
+
+// ObserveToolResults fingerprints the results of the agent's pending
+// sensitive calls, tainting the agent for the configured TTL. Results of
+// other calls are ignored. Returns the number of results fingerprinted.
+func (e *Engine) ObserveToolResults(agentID string, results []extractor.ToolResult) int {
+	return e.taint.observe(agentID, results, time.Now())
+}
+
+// taintSource returns the taint source matched by this call's arguments,
+// computed on first use. nil when the call isn't tainted.
+func (cc *callContext) taintSource() *taintSource {
+	if !cc.taintDone {
+		cc.taintDone = true
+		if cc.taint != nil {
+			cc.taintSrc = cc.taint.match(cc.agentID, cc.tc, cc.now)
+		}
+	}
+	return cc.taintSrc
+}
+
+// usesTaint reports whether the rule has a tainted: condition, so a
+// decision by it should name the taint source.
+func (r *Rule) usesTaint() bool {
+	if r.seq != nil {
+		last := r.seq.steps[len(r.seq.steps)-1]
+		return last.c != nil && last.c.tainted
+	}
+	return r.compiled != nil && r.compiled.tainted
+}
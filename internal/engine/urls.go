@@ -0,0 +1,449 @@
+package engine
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+// callURL is a URL found in a tool call, parsed for the url_* match
+// fields. Matching on the parsed form sees through what url_regex misses:
+// userinfo (`https://evil.com@good.com` is good.com), letter case, and IP
+// literals in decimal, hex, octal or IPv4-mapped IPv6 form.
+type callURL struct {
+	raw    string
+	scheme string     // Lowercased.
+	host   string     // Lowercased, without brackets, port or trailing dot.
+	ip     netip.Addr // The host as an address, when it is an IP literal.
+	port   int        // Explicit port, else the scheme's default (0 if none).
+	path   string     // Decoded and cleaned; "/" when empty.
+}
+
+// defaultPorts are the ports implied by a URL scheme.
+var defaultPorts = map[string]int{"http": 80, "https": 443, "ws": 80, "wss": 443, "ftp": 21}
+
+// parseCallURL parses a URL argument. A URL without a scheme is read the
+// way curl and browsers read it, as http. Backslashes count as slashes in
+// http(s) URLs, as they do in browsers.
+func parseCallURL(raw string) (callURL, bool) {
+	s := strings.TrimSpace(raw)
+	if s == "" {
+		return callURL{}, false
+	}
+	lower := strings.ToLower(s)
+	if !strings.Contains(s, "://") && !strings.HasPrefix(lower, "file:") {
+		s = "http://" + s
+		lower = "http://" + lower
+	}
+	if strings.HasPrefix(lower, "http:") || strings.HasPrefix(lower, "https:") {
+		s = strings.ReplaceAll(s, `\`, "/")
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		// Go rejects percent-encoded ASCII in the host, which clients
+		// decode: http://%6c%6fcalhost/ is localhost.
+		u, err = url.Parse(unescapeAuthority(s))
+	}
+	if err != nil || u.Scheme == "" {
+		return callURL{}, false
+	}
+
+	out := callURL{raw: raw, scheme: strings.ToLower(u.Scheme)}
+	host := u.Hostname()
+	if dec, err := url.PathUnescape(host); err == nil {
+		host = dec
+	}
+	out.host = strings.TrimSuffix(strings.ToLower(host), ".")
+	out.ip, _ = parseHostIP(out.host)
+
+	out.port = defaultPorts[out.scheme]
+	if p := u.Port(); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n > 65535 {
+			return callURL{}, false
+		}
+		out.port = n
+	}
+
+	out.path = "/"
+	if u.Path != "" {
+		out.path = path.Clean("/" + u.Path)
+	}
+	return out, true
+}
+
+// unescapeAuthority decodes percent escapes in a URL's authority (the part
+// between "://" and the path). Returns s unchanged if it has none.
+func unescapeAuthority(s string) string {
+	i := strings.Index(s, "://")
+	if i < 0 {
+		return s
+	}
+	start := i + 3
+	end := len(s)
+	if j := strings.IndexAny(s[start:], "/?#"); j >= 0 {
+		end = start + j
+	}
+	dec, err := url.PathUnescape(s[start:end])
+	if err != nil {
+		return s
+	}
+	return s[:start] + dec + s[end:]
+}
+
+// parseHostIP reads a host as an IP address. Besides the standard forms
+// it accepts what inet_aton and most HTTP clients do: one to four
+// dot-separated parts, each decimal, 0x-prefixed hex, or 0-prefixed octal,
+// the last part filling the remaining bytes — so 2130706433, 0x7f000001,
+// 0177.0.0.1 and 127.1 are all 127.0.0.1. IPv4-mapped IPv6 addresses are
+// unmapped.
+func parseHostIP(host string) (netip.Addr, bool) {
+	if host == "" {
+		return netip.Addr{}, false
+	}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return ip.Unmap(), true
+	}
+
+	parts := strings.Split(host, ".")
+	if len(parts) > 4 {
+		return netip.Addr{}, false
+	}
+	var v uint64
+	for i, p := range parts {
+		n, ok := parseIPPart(p)
+		if !ok {
+			return netip.Addr{}, false
+		}
+		if i < len(parts)-1 {
+			if n > 0xff {
+				return netip.Addr{}, false
+			}
+			v |= n << (8 * (3 - i))
+			continue
+		}
+		// The last part fills the bytes the earlier ones didn't.
+		if n >= 1<<(8*(4-i)) {
+			return netip.Addr{}, false
+		}
+		v |= n
+	}
+	return netip.AddrFrom4([4]byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}), true
+}
+
+// parseIPPart parses one part of an inet_aton-style address.
+func parseIPPart(p string) (uint64, bool) {
+	base := 10
+	switch {
+	case p == "":
+		return 0, false
+	case len(p) > 2 && (p[:2] == "0x" || p[:2] == "0X"):
+		base, p = 16, p[2:]
+	case len(p) > 1 && p[0] == '0':
+		base, p = 8, p[1:]
+	}
+	n, err := strconv.ParseUint(p, base, 32)
+	return n, err == nil
+}
+
+// sharedAddressSpace is RFC 6598 carrier-grade NAT space, and thisNetwork
+// 0.0.0.0/8, which reaches the local host on most systems.
+var (
+	sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+	thisNetwork        = netip.MustParsePrefix("0.0.0.0/8")
+)
+
+// isPrivateAddr reports whether an address is loopback, link-local (which
+// includes the 169.254.169.254 cloud metadata endpoint), private (RFC 1918,
+// IPv6 ULA), carrier-grade NAT or unspecified.
+func isPrivateAddr(ip netip.Addr) bool {
+	ip = ip.WithZone("").Unmap()
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() ||
+		sharedAddressSpace.Contains(ip) || thisNetwork.Contains(ip)
+}
+
+// privateHostnames name the local host or a cloud metadata service.
+var privateHostnames = []string{"localhost", "metadata", "metadata.google.internal", "instance-data"}
+
+// private reports whether the URL targets the local host, the private
+// network or a cloud metadata service. Hostnames are not resolved.
+func (u callURL) private() bool {
+	if u.ip.IsValid() {
+		return isPrivateAddr(u.ip)
+	}
+	for _, h := range privateHostnames {
+		if u.host == h {
+			return true
+		}
+	}
+	return strings.HasSuffix(u.host, ".localhost")
+}
+
+// hostSet is a compiled url_host list or host list file: domain suffixes
+// plus IP ranges. Domains are looked up one label suffix at a time and IPs
+// by binary search over sorted ranges, so matching costs one map lookup per
+// label, or log(n) comparisons, regardless of the list's size.
+type hostSet struct {
+	domains map[string]bool // Domain -> subdomains only ("*.example.com").
+	ranges  []addrRange     // Sorted and merged by finish.
+}
+
+// compileHostSet parses url_host entries:
+//
+//	example.com      example.com and every subdomain
+//	*.example.com    subdomains only
+//	10.0.0.0/8       IP hosts in the prefix
+//	127.0.0.1        that IP host, in any encoding
+func compileHostSet(entries []string) (*hostSet, error) {
+	hs := &hostSet{domains: make(map[string]bool)}
+	for _, e := range entries {
+		if err := hs.add(e); err != nil {
+			return nil, err
+		}
+	}
+	hs.finish()
+	return hs, nil
+}
+
+func (hs *hostSet) add(entry string) error {
+	e := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(entry)), ".")
+	switch {
+	case e == "":
+		return fmt.Errorf("empty host")
+	case strings.Contains(e, "/"):
+		p, err := netip.ParsePrefix(e)
+		if err != nil {
+			return fmt.Errorf("invalid CIDR %q: %w", entry, err)
+		}
+		hs.ranges = append(hs.ranges, prefixRange(p.Masked()))
+	case strings.HasPrefix(e, "*."):
+		if _, ok := hs.domains[e[2:]]; !ok {
+			hs.domains[e[2:]] = true
+		}
+	default:
+		if ip, ok := parseHostIP(strings.Trim(e, "[]")); ok {
+			hs.ranges = append(hs.ranges, addrRange{ip, ip})
+			return nil
+		}
+		hs.domains[e] = false
+	}
+	return nil
+}
+
+// match reports whether the URL's host is in the set.
+func (hs *hostSet) match(u callURL) bool {
+	if u.ip.IsValid() {
+		return hs.matchIP(u.ip.WithZone(""))
+	}
+	host := u.host
+	for i := 0; ; {
+		if subOnly, ok := hs.domains[host[i:]]; ok && (i > 0 || !subOnly) {
+			return true
+		}
+		dot := strings.IndexByte(host[i:], '.')
+		if dot < 0 {
+			return false
+		}
+		i += dot + 1
+	}
+}
+
+// portRange is one url_port entry: a port or an inclusive range.
+type portRange struct{ lo, hi int }
+
+// compilePorts parses url_port entries like "443" or "8000-8999".
+func compilePorts(entries []string) ([]portRange, error) {
+	var out []portRange
+	for _, e := range entries {
+		lo, hi, isRange := strings.Cut(strings.TrimSpace(e), "-")
+		if !isRange {
+			hi = lo
+		}
+		a, err1 := strconv.Atoi(lo)
+		b, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || a < 0 || b > 65535 || a > b {
+			return nil, fmt.Errorf("invalid port %q (want a port or a range like 8000-8999)", e)
+		}
+		out = append(out, portRange{a, b})
+	}
+	return out, nil
+}
+
+// compileURLMatch compiles a block's url_* fields.
+func compileURLMatch(m *RuleMatch, c *compiledMatcher) error {
+	if len(m.URLHost) > 0 {
+		hs, err := compileHostSet(m.URLHost)
+		if err != nil {
+			return fmt.Errorf("url_host: %w", err)
+		}
+		c.urlHosts = hs
+	}
+	for _, name := range m.URLHostInList {
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("url_host_in_list: empty file name")
+		}
+	}
+	if len(m.URLPort) > 0 {
+		ports, err := compilePorts(m.URLPort)
+		if err != nil {
+			return fmt.Errorf("url_port: %w", err)
+		}
+		c.urlPorts = ports
+	}
+	for _, p := range m.URLPath {
+		g, err := glob.Compile(p)
+		if err != nil {
+			return fmt.Errorf("invalid url_path glob %q: %w", p, err)
+		}
+		c.urlPathGlobs = append(c.urlPathGlobs, g)
+	}
+	return nil
+}
+
+// hasURLMatch reports whether the block has url_* conditions.
+func (m *RuleMatch) hasURLMatch() bool {
+	return len(m.URLHost) > 0 || len(m.URLHostInList) > 0 || len(m.URLScheme) > 0 || len(m.URLPort) > 0 ||
+		len(m.URLPath) > 0 || m.URLPrivate != nil
+}
+
+// matchesURL reports whether one URL satisfies every url_* condition of
+// the block. lists holds the loaded url_host_in_list files.
+func matchesURL(m *RuleMatch, c *compiledMatcher, u callURL, lists map[string]*hostList) bool {
+	if c.urlHosts != nil && !c.urlHosts.match(u) {
+		return false
+	}
+	if len(m.URLHostInList) > 0 {
+		matched := false
+		for _, name := range m.URLHostInList {
+			if l := lists[name]; l != nil && l.hosts.match(u) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(m.URLScheme) > 0 {
+		matched := false
+		for _, s := range m.URLScheme {
+			if strings.EqualFold(s, u.scheme) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(c.urlPorts) > 0 {
+		matched := false
+		for _, r := range c.urlPorts {
+			if u.port >= r.lo && u.port <= r.hi {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(c.urlPathGlobs) > 0 {
+		matched := false
+		for _, g := range c.urlPathGlobs {
+			if g.Match(u.path) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if m.URLPrivate != nil && u.private() != *m.URLPrivate {
+		return false
+	}
+	return true
+}
+
+// urlBinaries take bare hosts as URLs (`curl example.com`).
+var urlBinaries = map[string]bool{"curl": true, "wget": true, "http": true, "https": true, "xh": true}
+
+// callURLs returns the URLs in the call, parsed on first use: the "url"
+// and "targetUrl" arguments, and URLs in the parsed "command" argument.
+func (cc *callContext) callURLs() []callURL {
+	if cc.urlsDone {
+		return cc.urls
+	}
+	cc.urlsDone = true
+
+	add := func(raw string) {
+		if u, ok := parseCallURL(raw); ok {
+			cc.urls = append(cc.urls, u)
+		}
+	}
+	for _, key := range []string{"url", "targetUrl"} {
+		if s := getStringArg(cc.tc.Arguments, key); s != "" {
+			add(s)
+		}
+	}
+	if script := cc.shellScript(); script != nil {
+		for i := range script.Commands {
+			for _, raw := range commandURLs(&script.Commands[i]) {
+				add(raw)
+			}
+		}
+	}
+	return cc.urls
+}
+
+// commandURLs returns the URL-looking words of a parsed command: those
+// with a scheme (also as the value of --opt=URL), and for curl-like tools
+// bare hosts such as `localhost:8080/admin` or `169.254.169.254`.
+func commandURLs(cmd *shellCommand) []string {
+	if len(cmd.Argv) < 2 {
+		return nil
+	}
+	bare := urlBinaries[cmd.binary()]
+	var out []string
+	for _, w := range cmd.Argv[1:] {
+		if i := strings.Index(w, "://"); i >= 0 {
+			if eq := strings.IndexByte(w, '='); eq >= 0 && eq < i {
+				w = w[eq+1:]
+			}
+			out = append(out, w)
+			continue
+		}
+		if bare && !strings.HasPrefix(w, "-") && looksLikeHost(w) {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// looksLikeHost reports whether a word starts with something that could
+// be a host: a dotted name, localhost, or an IP in any encoding. A bare
+// number only counts when it is large enough to be a whole address, so
+// option values like `-m 30` aren't read as 0.0.0.30.
+func looksLikeHost(w string) bool {
+	host := w
+	if i := strings.IndexAny(host, "/:?#"); i >= 0 {
+		host = host[:i]
+	}
+	host = strings.ToLower(host)
+	if host == "" {
+		return false
+	}
+	if host == "localhost" || strings.Contains(host, ".") {
+		return true
+	}
+	ip, ok := parseHostIP(host)
+	return ok && ip.Is4() && ip.As4()[0] != 0
+}
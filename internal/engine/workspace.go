@@ -0,0 +1,197 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ctrlai/ctrlai/internal/extractor"
+)
+
+// WorkspaceRuleName prefixes the rule name of each agent's workspace
+// jail: "workspace_jail:<agent>", so stats and lint keep agents apart.
+const WorkspaceRuleName = "workspace_jail"
+
+// workspaceRuleName returns the jail rule name for agent.
+func workspaceRuleName(agent string) string {
+	return WorkspaceRuleName + ":" + agent
+}
+
+// jailedTools are the file tools confined by workspace_roots.
+var jailedTools = stringOrList{"read", "write", "edit", "apply_patch", "image"}
+
+// pathArgKeys are the argument fields that carry a file path.
+var pathArgKeys = []string{"path", "file_path", "filePath", "url_or_path", "image"}
+
+// patchArgKeys are the argument fields that carry apply_patch input.
+var patchArgKeys = []string{"input", "patch"}
+
+// workspaceJail confines one agent's file tools to its workspace roots.
+// Each jail is a synthesized rule placed after the built-ins, so it goes
+// through the same compiled tool/agent matchers as any other rule.
+type workspaceJail struct {
+	agent    string
+	roots    []string // Canonical roots.
+	resolver *pathResolver
+}
+
+// newWorkspaceJail builds the jail for one agent. Relative paths are
+// resolved against the first root. Symlinks are always resolved,
+// whatever paths.resolve_symlinks says: a link inside a root may point
+// anywhere.
+func newWorkspaceJail(agent string, roots []string, s PathSettings) *workspaceJail {
+	s.Workspace = roots[0]
+	s.ResolveSymlinks = true
+	resolver := newPathResolver(s)
+
+	j := &workspaceJail{agent: agent, resolver: resolver}
+	for _, root := range roots {
+		j.roots = append(j.roots, resolver.canonical(root))
+	}
+	return j
+}
+
+// escape returns the first path-bearing argument of tc that resolves
+// outside every root, along with its resolved form. A path whose
+// symlinks can't be resolved counts as escaping: the jail fails closed.
+func (j *workspaceJail) escape(tc extractor.ToolCall) (raw, resolved string, ok bool) {
+	for _, p := range toolCallPaths(tc) {
+		canon, resolvedOK := j.resolver.resolve(p)
+		if canon == "" || resolvedOK && j.contains(canon) {
+			continue
+		}
+		return p, canon, true
+	}
+	return "", "", false
+}
+
+// contains reports whether the canonical path lies under one of the roots.
+func (j *workspaceJail) contains(canon string) bool {
+	for _, root := range j.roots {
+		if canon == root || root == "/" || strings.HasPrefix(canon, strings.TrimSuffix(root, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// message builds the block notice, naming the offending resolved path.
+func (j *workspaceJail) message(raw, resolved string) string {
+	if j.contains(resolved) {
+		// Only reachable when a symlink in the path couldn't be followed.
+		return fmt.Sprintf("Path %q could not be resolved inside the workspace for agent %q (%s)",
+			raw, j.agent, strings.Join(j.roots, ", "))
+	}
+	if raw == resolved {
+		return fmt.Sprintf("Path %s is outside the workspace for agent %q (%s)",
+			resolved, j.agent, strings.Join(j.roots, ", "))
+	}
+	return fmt.Sprintf("Path %q resolves to %s, outside the workspace for agent %q (%s)",
+		raw, resolved, j.agent, strings.Join(j.roots, ", "))
+}
+
+// toolCallPaths returns every file path in a tool call's arguments: the
+// plain path fields, any "paths" list, and the files named in an
+// apply_patch input. URLs given to image are skipped.
+func toolCallPaths(tc extractor.ToolCall) []string {
+	var out []string
+	for _, key := range pathArgKeys {
+		if s, ok := tc.Arguments[key].(string); ok && s != "" && !isURL(s) {
+			out = append(out, s)
+		}
+	}
+	if list, ok := tc.Arguments["paths"].([]any); ok {
+		for _, v := range list {
+			if s, ok := v.(string); ok && s != "" {
+				out = append(out, s)
+			}
+		}
+	}
+	for _, key := range patchArgKeys {
+		if s, ok := tc.Arguments[key].(string); ok {
+			out = append(out, patchPaths(s)...)
+		}
+	}
+	return out
+}
+
+// patchPaths extracts file names from a patch: the `*** Add File:`,
+// `*** Update File:`, `*** Delete File:` and `*** Move to:` headers of the
+// apply_patch format, and the `---`/`+++` headers of a unified diff.
+func patchPaths(patch string) []string {
+	var out []string
+	for _, line := range strings.Split(patch, "\n") {
+		line = strings.TrimRight(line, "\r")
+		switch {
+		case strings.HasPrefix(line, "*** "):
+			for _, h := range []string{"Add File:", "Update File:", "Delete File:", "Move to:"} {
+				if rest, ok := strings.CutPrefix(line[4:], h); ok {
+					if p := strings.TrimSpace(rest); p != "" {
+						out = append(out, p)
+					}
+					break
+				}
+			}
+		case strings.HasPrefix(line, "--- "), strings.HasPrefix(line, "+++ "):
+			p := strings.TrimSpace(line[4:])
+			if i := strings.IndexByte(p, '\t'); i >= 0 {
+				p = p[:i] // Drop the timestamp.
+			}
+			if p == "" || p == "/dev/null" {
+				continue
+			}
+			if strings.HasPrefix(p, "a/") || strings.HasPrefix(p, "b/") {
+				p = p[2:]
+			}
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// isURL reports whether s looks like a URL rather than a file path.
+func isURL(s string) bool {
+	return strings.Contains(s, "://") || strings.HasPrefix(s, "data:")
+}
+
+// validateWorkspaceRoots checks an agent's workspace_roots. Roots must be
+// absolute (after ~ and $HOME expansion).
+func validateWorkspaceRoots(agent string, roots []string, s PathSettings) error {
+	s.Workspace = ""
+	resolver := newPathResolver(s)
+	for _, root := range roots {
+		if !isAbsPath(resolver.canonical(root)) {
+			return fmt.Errorf("agents.%s.workspace_roots: %q must be an absolute path", agent, root)
+		}
+	}
+	return nil
+}
+
+// workspaceRules synthesizes one jail rule per agent with workspace_roots,
+// sorted by agent ID.
+func workspaceRules(agents map[string]AgentPolicy, s PathSettings) []Rule {
+	ids := make([]string, 0, len(agents))
+	for id, p := range agents {
+		if len(p.WorkspaceRoots) > 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	rules := make([]Rule, 0, len(ids))
+	for _, id := range ids {
+		r := Rule{
+			Name:    workspaceRuleName(id),
+			Match:   RuleMatch{Tool: jailedTools, Agent: id},
+			Action:  "block",
+			Message: fmt.Sprintf("Agent %q is confined to its workspace", id),
+			Builtin: true,
+		}
+		if err := compileMatcher(&r); err != nil {
+			continue // Unreachable: the match has no patterns.
+		}
+		r.jail = newWorkspaceJail(id, agents[id].WorkspaceRoots, s)
+		rules = append(rules, r)
+	}
+	return rules
+}
@@ -75,6 +75,12 @@ type RequestMeta struct {
 	Model  string   // The model name from the request body.
 	Tools  []string // Tool names available to the LLM (from "tools" array).
 	Stream bool     // Whether the request asks for streaming (SSE).
+
+	// ToolSchemas maps each declared tool to the JSON Schema of its
+	// arguments, or to nil if it has none (e.g. provider-hosted tools).
+	// Non-nil whenever the body parsed, even with no tools: the model was
+	// then offered none. nil means the declared tools aren't known.
+	ToolSchemas map[string]json.RawMessage
 }
 
 // ExtractRequestMeta parses metadata from the request body.
@@ -87,10 +93,13 @@ func ExtractRequestMeta(body []byte, apiType APIType) RequestMeta {
 		Model  string `json:"model"`
 		Stream bool   `json:"stream"`
 		Tools  []struct {
-			Name     string `json:"name"`               // Anthropic format.
-			Function *struct {
-				Name string `json:"name"`
-			} `json:"function,omitempty"`               // OpenAI format.
+			Name        string          `json:"name"`         // Anthropic and OpenAI Responses format.
+			InputSchema json.RawMessage `json:"input_schema"` // Anthropic.
+			Parameters  json.RawMessage `json:"parameters"`   // OpenAI Responses.
+			Function    *struct {
+				Name       string          `json:"name"`
+				Parameters json.RawMessage `json:"parameters"`
+			} `json:"function,omitempty"` // OpenAI Chat Completions format.
 		} `json:"tools"`
 	}
 
@@ -100,14 +109,27 @@ func ExtractRequestMeta(body []byte, apiType APIType) RequestMeta {
 
 	meta.Model = raw.Model
 	meta.Stream = raw.Stream
+	meta.ToolSchemas = make(map[string]json.RawMessage, len(raw.Tools))
 
 	for _, t := range raw.Tools {
 		if t.Name != "" {
 			meta.Tools = append(meta.Tools, t.Name)
+			meta.ToolSchemas[t.Name] = schemaOrNil(t.InputSchema, t.Parameters)
 		} else if t.Function != nil && t.Function.Name != "" {
 			meta.Tools = append(meta.Tools, t.Function.Name)
+			meta.ToolSchemas[t.Function.Name] = schemaOrNil(t.Function.Parameters)
 		}
 	}
 
 	return meta
 }
+
+// schemaOrNil returns the first schema that is present and not JSON null.
+func schemaOrNil(schemas ...json.RawMessage) json.RawMessage {
+	for _, s := range schemas {
+		if len(s) > 0 && string(s) != "null" {
+			return s
+		}
+	}
+	return nil
+}
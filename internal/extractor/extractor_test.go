@@ -549,6 +549,40 @@ func TestExtractRequestMeta_Malformed(t *testing.T) {
 	if meta.Model != "" {
 		t.Errorf("malformed JSON should give zero meta, got %+v", meta)
 	}
+	if meta.ToolSchemas != nil {
+		t.Error("malformed JSON should leave the declared tools unknown")
+	}
+}
+
+func TestExtractRequestMeta_ToolSchemas(t *testing.T) {
+	anthropic := ExtractRequestMeta([]byte(`{
+		"tools": [
+			{"name": "exec", "input_schema": {"type": "object", "required": ["command"]}},
+			{"type": "web_search_20250305", "name": "web_search"}
+		]
+	}`), APITypeAnthropic)
+	if string(anthropic.ToolSchemas["exec"]) != `{"type": "object", "required": ["command"]}` {
+		t.Errorf("exec schema: got %s", anthropic.ToolSchemas["exec"])
+	}
+	if s, ok := anthropic.ToolSchemas["web_search"]; !ok || s != nil {
+		t.Errorf("web_search should be declared without a schema, got %s (declared %v)", s, ok)
+	}
+
+	chat := ExtractRequestMeta([]byte(`{"tools": [{"type": "function", "function": {"name": "read", "parameters": {"type": "object"}}}]}`), APITypeOpenAI)
+	if string(chat.ToolSchemas["read"]) != `{"type": "object"}` {
+		t.Errorf("chat completions schema: got %s", chat.ToolSchemas["read"])
+	}
+
+	responses := ExtractRequestMeta([]byte(`{"tools": [{"type": "function", "name": "write", "parameters": {"type": "object"}}]}`), APITypeOpenAIResponses)
+	if string(responses.ToolSchemas["write"]) != `{"type": "object"}` {
+		t.Errorf("responses schema: got %s", responses.ToolSchemas["write"])
+	}
+
+	// No tools: the model was offered none, which is known.
+	none := ExtractRequestMeta([]byte(`{"model": "m"}`), APITypeAnthropic)
+	if none.ToolSchemas == nil || len(none.ToolSchemas) != 0 {
+		t.Errorf("expected an empty, non-nil map, got %#v", none.ToolSchemas)
+	}
 }
 
 // ==========================================================================
@@ -1019,3 +1053,62 @@ func TestReplacePythonKeywords(t *testing.T) {
 		}
 	}
 }
+
+// --- Tool result extraction tests ---
+
+func TestExtractToolResults_Anthropic(t *testing.T) {
+	body := []byte(`{
+		"messages": [
+			{"role": "user", "content": "read my config"},
+			{"role": "assistant", "content": [{"type": "tool_use", "id": "toolu_01", "name": "read", "input": {}}]},
+			{"role": "user", "content": [
+				{"type": "tool_result", "tool_use_id": "toolu_01", "content": "db_password=hunter2"},
+				{"type": "tool_result", "tool_use_id": "toolu_02", "content": [{"type": "text", "text": "part one"}, {"type": "image"}, {"type": "text", "text": "part two"}]}
+			]}
+		]
+	}`)
+
+	results := ExtractToolResults(body, APITypeAnthropic)
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].ID != "toolu_01" || results[0].Content != "db_password=hunter2" {
+		t.Errorf("unexpected first result: %+v", results[0])
+	}
+	if results[1].Content != "part one\npart two" {
+		t.Errorf("text parts should be joined, got %q", results[1].Content)
+	}
+}
+
+func TestExtractToolResults_OpenAI(t *testing.T) {
+	body := []byte(`{
+		"messages": [
+			{"role": "assistant", "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "read", "arguments": "{}"}}]},
+			{"role": "tool", "tool_call_id": "call_1", "content": "secret contents"}
+		]
+	}`)
+
+	results := ExtractToolResults(body, APITypeOpenAI)
+	if len(results) != 1 || results[0].ID != "call_1" || results[0].Content != "secret contents" {
+		t.Errorf("unexpected results: %+v", results)
+	}
+}
+
+func TestExtractToolResults_OpenAIResponses(t *testing.T) {
+	body := []byte(`{
+		"input": [
+			{"type": "function_call", "call_id": "call_9", "name": "read", "arguments": "{}"},
+			{"type": "function_call_output", "call_id": "call_9", "output": "file body"}
+		]
+	}`)
+
+	results := ExtractToolResults(body, APITypeOpenAIResponses)
+	if len(results) != 1 || results[0].ID != "call_9" || results[0].Content != "file body" {
+		t.Errorf("unexpected results: %+v", results)
+	}
+
+	// A plain string input has no results.
+	if got := ExtractToolResults([]byte(`{"input": "hello"}`), APITypeOpenAIResponses); len(got) != 0 {
+		t.Errorf("expected no results, got %+v", got)
+	}
+}
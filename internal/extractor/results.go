@@ -0,0 +1,133 @@
+package extractor
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// ToolResult is the output of a tool call, as the agent sends it back to
+// the LLM in its next request.
+type ToolResult struct {
+	ID      string // ID of the tool call this result answers.
+	Content string // Text content, with multiple text parts joined by newlines.
+}
+
+// ExtractToolResults parses tool results from a request body. The whole
+// conversation is resent on every request, so results from earlier turns
+// are returned too; callers match them by ID.
+//
+//   - Anthropic Messages API: messages[].content[].type="tool_result"
+//   - OpenAI Chat Completions API: messages[].role="tool"
+//   - OpenAI Responses API: input[].type="function_call_output"
+func ExtractToolResults(body []byte, apiType APIType) []ToolResult {
+	switch apiType {
+	case APITypeAnthropic:
+		return extractAnthropicResults(body)
+	case APITypeOpenAI:
+		return extractOpenAIResults(body)
+	case APITypeOpenAIResponses:
+		return extractOpenAIResponsesResults(body)
+	default:
+		return nil
+	}
+}
+
+func extractAnthropicResults(body []byte) []ToolResult {
+	var req struct {
+		Messages []struct {
+			Content json.RawMessage `json:"content"` // String or list of blocks.
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil
+	}
+
+	var results []ToolResult
+	for _, msg := range req.Messages {
+		var blocks []struct {
+			Type      string          `json:"type"`
+			ToolUseID string          `json:"tool_use_id"`
+			Content   json.RawMessage `json:"content"`
+		}
+		if json.Unmarshal(msg.Content, &blocks) != nil {
+			continue // Plain string content.
+		}
+		for _, b := range blocks {
+			if b.Type == "tool_result" && b.ToolUseID != "" {
+				results = append(results, ToolResult{ID: b.ToolUseID, Content: resultText(b.Content)})
+			}
+		}
+	}
+	return results
+}
+
+func extractOpenAIResults(body []byte) []ToolResult {
+	var req struct {
+		Messages []struct {
+			Role       string          `json:"role"`
+			ToolCallID string          `json:"tool_call_id"`
+			Content    json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil
+	}
+
+	var results []ToolResult
+	for _, msg := range req.Messages {
+		if msg.Role == "tool" && msg.ToolCallID != "" {
+			results = append(results, ToolResult{ID: msg.ToolCallID, Content: resultText(msg.Content)})
+		}
+	}
+	return results
+}
+
+func extractOpenAIResponsesResults(body []byte) []ToolResult {
+	var req struct {
+		Input json.RawMessage `json:"input"` // String or list of items.
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil
+	}
+	var items []struct {
+		Type   string          `json:"type"`
+		CallID string          `json:"call_id"`
+		Output json.RawMessage `json:"output"`
+	}
+	if json.Unmarshal(req.Input, &items) != nil {
+		return nil
+	}
+
+	var results []ToolResult
+	for _, item := range items {
+		if item.Type == "function_call_output" && item.CallID != "" {
+			results = append(results, ToolResult{ID: item.CallID, Content: resultText(item.Output)})
+		}
+	}
+	return results
+}
+
+// resultText flattens result content: a plain string, or a list of parts
+// whose "text" fields are joined. Non-text parts (images) are skipped.
+func resultText(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var parts []struct {
+		Text string `json:"text"`
+	}
+	if json.Unmarshal(raw, &parts) != nil {
+		return string(raw)
+	}
+	texts := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p.Text != "" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,48 @@
+package proxy
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/ctrlai/ctrlai/internal/delegate"
+	"github.com/ctrlai/ctrlai/internal/engine"
+	"github.com/ctrlai/ctrlai/internal/extractor"
+)
+
+// delegateDecision replaces a "delegate" decision with the policy service's
+// answer and returns where that answer came from. Without a configured
+// service the call is blocked.
+func (p *Proxy) delegateDecision(ctx context.Context, route RouteInfo, meta extractor.RequestMeta, tc extractor.ToolCall, d engine.Decision) (engine.Decision, string) {
+	if p.delegate == nil {
+		slog.Warn("delegate rule matched but no delegate service configured, blocking",
+			"agent", route.AgentID, "tool", tc.Name, "rule", d.Rule)
+		d.Action = "block"
+		d.Message = strings.TrimSpace(d.Message + " (no delegate service configured)")
+		return d, delegate.SourceFallback
+	}
+
+	res := p.delegate.Decide(ctx, delegate.Request{
+		Rule:     d.Rule,
+		Agent:    route.AgentID,
+		Provider: route.ProviderKey,
+		Model:    meta.Model,
+		ToolCall: delegate.ToolCall{ID: tc.ID, Name: tc.Name, Arguments: tc.Arguments},
+		Request:  delegate.Metadata{APIPath: route.APIPath, Stream: meta.Stream, Tools: meta.Tools},
+	})
+	if res.Err != nil {
+		slog.Warn("delegate service failed, applying fallback",
+			"agent", route.AgentID, "tool", tc.Name, "rule", d.Rule,
+			"fallback", res.Decision, "error", res.Err)
+	}
+
+	d.Action = res.Decision
+	if res.Message != "" {
+		d.Message = res.Message
+	}
+	d.Args = nil
+	if res.Decision == "rewrite" {
+		d.Args = res.Arguments
+	}
+	return d, res.Source
+}
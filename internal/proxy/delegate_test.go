@@ -0,0 +1,40 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ctrlai/ctrlai/internal/delegate"
+	"github.com/ctrlai/ctrlai/internal/engine"
+	"github.com/ctrlai/ctrlai/internal/extractor"
+)
+
+func TestDelegateDecision(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(delegate.Response{Decision: "rewrite", Message: "pinned", Arguments: map[string]any{"command": "ls"}})
+	}))
+	defer srv.Close()
+
+	route := RouteInfo{ProviderKey: "anthropic", AgentID: "main", APIPath: "/v1/messages"}
+	tc := extractor.ToolCall{ID: "toolu_1", Name: "exec", Arguments: map[string]any{"command": "ls -R /"}}
+	d := engine.Decision{Action: "delegate", Rule: "policy-service", Message: "Ask the policy service"}
+
+	p := &Proxy{delegate: delegate.NewClient(srv.URL, time.Second, "block", 0)}
+	got, source := p.delegateDecision(context.Background(), route, extractor.RequestMeta{Model: "m"}, tc, d)
+	if got.Action != "rewrite" || got.Message != "pinned" || got.Args["command"] != "ls" || source != delegate.SourceService {
+		t.Errorf("unexpected decision %+v from %s", got, source)
+	}
+	if !got.Rewrites() {
+		t.Error("a delegated rewrite should rewrite the call")
+	}
+
+	// Without a service, delegate rules fail closed.
+	got, source = (&Proxy{}).delegateDecision(context.Background(), route, extractor.RequestMeta{}, tc, d)
+	if got.Action != "block" || source != delegate.SourceFallback {
+		t.Errorf("expected block without a service, got %+v from %s", got, source)
+	}
+}
@@ -1,19 +1,47 @@
 package proxy
 
 import (
-	"encoding/base64"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/ctrlai/ctrlai/internal/engine"
 )
 
-// extractRuntimeRules parses the X-Ctrl-Rules header if present.
-// The header value should be base64-encoded YAML containing rules and builtin toggles.
-// Returns fully merged rules (custom + enabled built-ins), or nil if header is missing or parsing fails.
+// runtimeRules is a verified X-Ctrl-Rules bundle and the rule set it
+// produces. A nil *runtimeRules means the file rules apply.
+type runtimeRules struct {
+	bundle *engine.RuleBundle
+	rules  []engine.Rule
+}
+
+// list returns the rules to evaluate with, nil for the file rules.
+func (rt *runtimeRules) list() []engine.Rule {
+	if rt == nil {
+		return nil
+	}
+	return rt.rules
+}
+
+// bundleID returns the ID recorded in the audit log, "" for the file rules.
+func (rt *runtimeRules) bundleID() string {
+	if rt == nil {
+		return ""
+	}
+	return rt.bundle.ID
+}
+
+// extractRuntimeRules verifies and parses the X-Ctrl-Rules header if present.
+// The header value is a signed rule bundle (see engine.VerifyRuleBundle)
+// whose rules are YAML containing rules and builtin toggles.
+// Returns the bundle with fully merged rules (custom + enabled built-ins), or
+// nil if the header is missing or fails verification or parsing.
 //
 // This allows per-org/per-request rule customization in enterprise deployments.
-func extractRuntimeRules(r *http.Request) []engine.Rule {
+// The bundle replaces the file rules, so it fails closed: anything short of
+// a valid, unexpired signature from a key in config.yaml leaves the file
+// rules in charge.
+func (p *Proxy) extractRuntimeRules(r *http.Request) *runtimeRules {
 	headerValue := r.Header.Get("X-Ctrl-Rules")
 	if headerValue == "" {
 		slog.Info("X-Ctrl-Rules header NOT found in request")
@@ -22,29 +50,32 @@ func extractRuntimeRules(r *http.Request) []engine.Rule {
 
 	slog.Info("X-Ctrl-Rules header found", "length", len(headerValue))
 
-	// Base64 decode
-	yamlData, err := base64.StdEncoding.DecodeString(headerValue)
+	if len(p.bundleKeys) == 0 {
+		slog.Warn("ignoring X-Ctrl-Rules header: no runtimeRules keys configured, using file rules")
+		return nil
+	}
+	bundle, err := engine.VerifyRuleBundle(headerValue, p.bundleKeys, time.Now())
 	if err != nil {
-		slog.Warn("failed to decode X-Ctrl-Rules header", "error", err)
+		slog.Warn("rejected X-Ctrl-Rules bundle, using file rules", "error", err)
 		return nil
 	}
 
-	slog.Info("X-Ctrl-Rules header decoded successfully", "yaml_length", len(yamlData))
-	slog.Info("Decoded YAML", "content", string(yamlData))
-
 	// Parse YAML - returns custom rules and builtin toggles
-	customRules, builtinToggles, err := engine.ParseRulesFromYAML(yamlData)
+	customRules, builtinToggles, err := engine.ParseRulesFromYAML([]byte(bundle.Rules))
 	if err != nil {
-		slog.Warn("failed to parse runtime rules from header", "error", err)
+		slog.Warn("failed to parse runtime rules from header, using file rules", "bundle", bundle.ID, "error", err)
 		return nil
 	}
 
-	slog.Info("✅ Parsed runtime rules", "custom_count", len(customRules), "builtin_toggles", builtinToggles)
+	slog.Info("✅ Parsed runtime rules", "bundle", bundle.ID, "org", bundle.Org, "key", bundle.KeyID,
+		"custom_count", len(customRules), "builtin_toggles", builtinToggles)
 
 	// Merge built-in rules with toggles.
 	// For rules in the org's toggles: use that value.
 	// For rules NOT in the org's toggles: use Go's default (some are off by default).
-	var mergedRules []engine.Rule
+	// Non-nil even when empty: an empty header rule set still replaces the
+	// file-based rules (nil would fall back to them).
+	mergedRules := []engine.Rule{}
 	defaults := engine.DefaultBuiltinToggles()
 
 	allBuiltins, err := engine.GetAllBuiltinRules()
@@ -72,10 +103,10 @@ func extractRuntimeRules(r *http.Request) []engine.Rule {
 	// Add custom rules after built-ins
 	mergedRules = append(mergedRules, customRules...)
 
-	slog.Info("✅ Merged runtime rules", "total_count", len(mergedRules))
+	slog.Info("✅ Merged runtime rules", "bundle", bundle.ID, "total_count", len(mergedRules))
 	for i, rule := range mergedRules {
 		slog.Info("  Merged rule", "index", i, "name", rule.Name, "action", rule.Action)
 	}
 
-	return mergedRules
+	return &runtimeRules{bundle: bundle, rules: mergedRules}
 }
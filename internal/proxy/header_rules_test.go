@@ -0,0 +1,58 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ctrlai/ctrlai/internal/engine"
+)
+
+func TestExtractRuntimeRules(t *testing.T) {
+	secret := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	key, err := engine.NewBundleKey("ci", "", engine.BundleHMACSHA256, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := "rules:\n  - name: no-exec\n    match: {tool: exec}\n    action: block\n"
+	sign := func(exp time.Time) string {
+		v, err := engine.SignRuleBundle(engine.RuleBundle{ID: "b7", KeyID: "ci", ExpiresAt: exp.Unix(), Rules: rules}, engine.BundleHMACSHA256, []byte(secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	request := func(header string) *runtimeRules {
+		r := httptest.NewRequest("POST", "/provider/anthropic/agent/a/v1/messages", nil)
+		if header != "" {
+			r.Header.Set("X-Ctrl-Rules", header)
+		}
+		p := &Proxy{bundleKeys: []engine.BundleKey{key}}
+		return p.extractRuntimeRules(r)
+	}
+
+	rt := request(sign(time.Now().Add(time.Hour)))
+	if rt == nil || rt.bundleID() != "b7" || rt.list()[len(rt.list())-1].Name != "no-exec" {
+		t.Fatalf("signed bundle not applied: %+v", rt)
+	}
+
+	// Everything else falls back to the file rules.
+	for name, header := range map[string]string{
+		"missing":  "",
+		"unsigned": base64.StdEncoding.EncodeToString([]byte(rules)),
+		"expired":  sign(time.Now().Add(-time.Minute)),
+	} {
+		if rt := request(header); rt != nil || rt.list() != nil || rt.bundleID() != "" {
+			t.Errorf("%s: expected file rules, got %+v", name, rt)
+		}
+	}
+
+	noKeys := &Proxy{}
+	r := httptest.NewRequest("POST", "/provider/anthropic/agent/a/v1/messages", nil)
+	r.Header.Set("X-Ctrl-Rules", sign(time.Now().Add(time.Hour)))
+	if rt := noKeys.extractRuntimeRules(r); rt != nil {
+		t.Error("header must be ignored when no keys are configured")
+	}
+}
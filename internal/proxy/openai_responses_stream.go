@@ -165,6 +165,64 @@ func buildModifiedOpenAIResponsesStream(events []SSEEvent, blocked []extractor.T
 	return modified
 }
 
+// rewriteOpenAIResponsesStreamArguments replaces the arguments of the
+// rewritten function calls (matched by call_id) in a Responses API stream.
+// The first arguments delta carries the whole new arguments string and
+// later ones are dropped; the .done and output_item.done events get the
+// new arguments too.
+func rewriteOpenAIResponsesStreamArguments(events []SSEEvent, rewritten []extractor.ToolCall) ([]SSEEvent, []extractor.ToolCall) {
+	args := make(map[string]string, len(rewritten))
+	for _, tc := range rewritten {
+		args[tc.ID] = string(tc.RawJSON)
+	}
+	sent := make(map[string]bool)
+	patched := make(map[string]bool)
+	failed := make(map[string]bool) // An event of the call went out unchanged.
+
+	var modified []SSEEvent
+	for _, evt := range events {
+		var field string
+		switch evt.Event {
+		case "response.function_call_arguments.delta":
+			field = "delta"
+		case "response.function_call_arguments.done", "response.output_item.done":
+			field = "arguments"
+		default:
+			modified = append(modified, evt)
+			continue
+		}
+
+		var raw map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(evt.Data), &raw); err != nil {
+			modified = append(modified, evt)
+			continue
+		}
+		callID := unquoteRaw(raw["call_id"])
+		a, ok := args[callID]
+		if !ok {
+			modified = append(modified, evt)
+			continue
+		}
+		if field == "delta" {
+			if sent[callID] {
+				continue // Already sent in full.
+			}
+			sent[callID] = true
+		}
+
+		raw[field] = safeMarshalRaw(a)
+		data, err := json.Marshal(raw)
+		if err != nil {
+			failed[callID] = true
+			modified = append(modified, evt)
+			continue
+		}
+		patched[callID] = true
+		modified = append(modified, SSEEvent{Event: evt.Event, Data: string(data)})
+	}
+	return modified, unpatchedCalls(rewritten, func(tc extractor.ToolCall) bool { return patched[tc.ID] && !failed[tc.ID] })
+}
+
 // isBlockedResponsesEvent checks if an SSE event belongs to a blocked function call.
 func isBlockedResponsesEvent(evt SSEEvent, blockedCallIDs map[string]bool) bool {
 	switch evt.Event {
@@ -3,18 +3,23 @@ package proxy
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/andybalholm/brotli"
 	"github.com/ctrlai/ctrlai/internal/agent"
+	"github.com/ctrlai/ctrlai/internal/approval"
 	"github.com/ctrlai/ctrlai/internal/audit"
 	"github.com/ctrlai/ctrlai/internal/config"
+	"github.com/ctrlai/ctrlai/internal/delegate"
 	"github.com/ctrlai/ctrlai/internal/engine"
 	"github.com/ctrlai/ctrlai/internal/extractor"
 )
@@ -28,10 +33,20 @@ type Options struct {
 	Registry       *agent.Registry
 	KillSwitch     *agent.KillSwitch
 	UpstreamClient *http.Client
+	// Approvals holds tool calls matched by "ask" rules until an operator
+	// decides. Optional — nil means "ask" fails closed to "block".
+	Approvals *approval.Queue
 	// OnAuditEvent is called after each audit entry is logged, allowing the
 	// dashboard to broadcast events to WebSocket clients in real time.
 	// Optional — nil means no broadcast.
 	OnAuditEvent func(audit.Entry)
+	// RuleBundleKeys verify X-Ctrl-Rules bundles, built from
+	// config.yaml's runtimeRules.keys. Optional — none means the header
+	// is ignored and the file rules always apply.
+	RuleBundleKeys []engine.BundleKey
+	// Delegate answers for tool calls matched by "delegate" rules.
+	// Optional — nil means "delegate" fails closed to "block".
+	Delegate *delegate.Client
 }
 
 // Proxy is the HTTP handler that intercepts LLM API calls, evaluates
@@ -47,7 +62,10 @@ type Proxy struct {
 	registry     *agent.Registry
 	killSwitch   *agent.KillSwitch
 	client       *http.Client
+	approvals    *approval.Queue
 	onAuditEvent func(audit.Entry)
+	bundleKeys   []engine.BundleKey
+	delegate     *delegate.Client
 }
 
 // New creates a new Proxy handler with the given dependencies.
@@ -59,7 +77,10 @@ func New(opts Options) *Proxy {
 		registry:     opts.Registry,
 		killSwitch:   opts.KillSwitch,
 		client:       opts.UpstreamClient,
+		approvals:    opts.Approvals,
 		onAuditEvent: opts.OnAuditEvent,
+		bundleKeys:   opts.RuleBundleKeys,
+		delegate:     opts.Delegate,
 	}
 }
 
@@ -101,8 +122,9 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	)
 
 	// --- Step 1.5: Extract runtime rules from X-Ctrl-Rules header ---
-	// Enterprise deployments can pass per-org rules via this header.
-	runtimeRules := extractRuntimeRules(r)
+	// Enterprise deployments can pass per-org rules via this header, as a
+	// bundle signed by a key in config.yaml.
+	runtime := p.extractRuntimeRules(r)
 
 	// --- Step 2: Read request body ---
 	// We read the body first to extract metadata (model, tools, stream flag).
@@ -133,6 +155,16 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Auto-register on first request, update last_seen and stats.
 	p.registry.Touch(route.AgentID, route.ProviderKey, reqMeta.Model)
 
+	// --- Step 4.5: Fingerprint results of sensitive tool calls ---
+	// The agent sends tool results back in its next request. Only parsed
+	// when a call tagged sensitive is still waiting for its result.
+	if p.engine.AwaitingResults(route.AgentID) {
+		results := extractor.ExtractToolResults(body, route.APIType)
+		if n := p.engine.ObserveToolResults(route.AgentID, results); n > 0 {
+			slog.Info("sensitive tool results fingerprinted", "agent", route.AgentID, "results", n)
+		}
+	}
+
 	// --- Step 5: Look up upstream URL ---
 	provider, ok := p.config.Providers[route.ProviderKey]
 	if !ok {
@@ -165,9 +197,9 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if reqMeta.Stream && p.config.Streaming.Buffer {
-		p.handleStreaming(w, resp, route, reqMeta, start, runtimeRules)
+		p.handleStreaming(r.Context(), w, resp, route, reqMeta, start, runtime)
 	} else {
-		p.handleNonStreaming(w, resp, route, reqMeta, start, runtimeRules)
+		p.handleNonStreaming(r.Context(), w, resp, route, reqMeta, start, runtime)
 	}
 }
 
@@ -176,7 +208,7 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 // and modifies the response if any are blocked.
 //
 // Design doc Section 13 — handleNonStreaming pseudocode.
-func (p *Proxy) handleNonStreaming(w http.ResponseWriter, resp *http.Response, route RouteInfo, meta extractor.RequestMeta, start time.Time, runtimeRules []engine.Rule) {
+func (p *Proxy) handleNonStreaming(ctx context.Context, w http.ResponseWriter, resp *http.Response, route RouteInfo, meta extractor.RequestMeta, start time.Time, runtime *runtimeRules) {
 	// Read the full response body.
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
@@ -258,52 +290,20 @@ func (p *Proxy) handleNonStreaming(w http.ResponseWriter, resp *http.Response, r
 		return
 	}
 
-	// Evaluate each tool call against the rule engine.
-	var blocked []extractor.ToolCall
-	var blockedDecisions []engine.Decision
-
-	for _, tc := range toolCalls {
-		evalStart := time.Now()
-		decision := p.engine.EvaluateWithRuntimeRules(route.AgentID, tc, runtimeRules)
-		latencyUs := time.Since(evalStart).Microseconds()
-
-		// Log to audit chain.
-		p.auditLog.LogToolCall(
-			route.AgentID, route.ProviderKey, meta.Model,
-			tc.Name, tc.Arguments,
-			decision.Action, decision.Rule, decision.Message,
-			latencyUs,
-		)
-
-		// Broadcast to dashboard WebSocket feed.
-		p.broadcastAuditEvent(audit.Entry{
-			Agent: route.AgentID, Provider: route.ProviderKey, Model: meta.Model,
-			Type: "tool_call", Tool: tc.Name, Decision: decision.Action,
-			Rule: decision.Rule, Message: decision.Message, LatencyUs: latencyUs,
-		})
-
-		// Update agent stats.
-		p.registry.RecordToolCall(route.AgentID, decision.Action == "block")
-
-		if decision.Action == "block" {
-			blocked = append(blocked, tc)
-			blockedDecisions = append(blockedDecisions, decision)
-
-			slog.Warn("tool call blocked",
-				"agent", route.AgentID,
-				"tool", tc.Name,
-				"rule", decision.Rule,
-				"message", decision.Message,
-			)
-		} else {
-			slog.Debug("tool call allowed",
-				"agent", route.AgentID,
-				"tool", tc.Name,
-			)
-		}
+	// Evaluate each tool call against the rule engine. A redact or rewrite
+	// the body can't carry is blocked before it is audited.
+	canPatch := patchChecker(func(calls []extractor.ToolCall) []extractor.ToolCall {
+		_, unpatched := rewriteToolArguments(body, route.APIType, calls)
+		return unpatched
+	})
+	blocked, blockedDecisions, rewritten := p.evaluateToolCalls(ctx, route, meta, toolCalls, runtime, canPatch)
+
+	// Swap in redacted or rewritten arguments, then strip blocked tool calls.
+	if len(rewritten) > 0 {
+		var unpatched []extractor.ToolCall
+		body, unpatched = rewriteToolArguments(body, route.APIType, rewritten)
+		blocked, blockedDecisions = blockUnpatched(unpatched, blocked, blockedDecisions)
 	}
-
-	// Modify response if any tool calls were blocked.
 	if len(blocked) > 0 {
 		body = modifyNonStreamingResponse(body, route.APIType, blocked, blockedDecisions)
 	}
@@ -328,7 +328,7 @@ func (p *Proxy) handleNonStreaming(w http.ResponseWriter, resp *http.Response, r
 //
 // Design doc Section 5.4: Buffer-Then-Forward strategy.
 // Design doc Section 13 — handleStreaming pseudocode.
-func (p *Proxy) handleStreaming(w http.ResponseWriter, resp *http.Response, route RouteInfo, meta extractor.RequestMeta, start time.Time, runtimeRules []engine.Rule) {
+func (p *Proxy) handleStreaming(ctx context.Context, w http.ResponseWriter, resp *http.Response, route RouteInfo, meta extractor.RequestMeta, start time.Time, runtime *runtimeRules) {
 	// Buffer all SSE events until message_stop / [DONE].
 	events, msg, err := bufferAll(resp.Body, p.config.Streaming.BufferTimeoutMs, route.APIType)
 	if err != nil {
@@ -338,42 +338,21 @@ func (p *Proxy) handleStreaming(w http.ResponseWriter, resp *http.Response, rout
 	}
 
 	// Evaluate tool calls from the reconstructed message.
-	var blocked []extractor.ToolCall
-	var blockMessages []string
-
-	for _, tc := range msg.ToolCalls {
-		evalStart := time.Now()
-		decision := p.engine.EvaluateWithRuntimeRules(route.AgentID, tc, runtimeRules)
-		latencyUs := time.Since(evalStart).Microseconds()
-
-		// Log to audit chain.
-		p.auditLog.LogToolCall(
-			route.AgentID, route.ProviderKey, meta.Model,
-			tc.Name, tc.Arguments,
-			decision.Action, decision.Rule, decision.Message,
-			latencyUs,
-		)
-
-		// Broadcast to dashboard WebSocket feed.
-		p.broadcastAuditEvent(audit.Entry{
-			Agent: route.AgentID, Provider: route.ProviderKey, Model: meta.Model,
-			Type: "tool_call", Tool: tc.Name, Decision: decision.Action,
-			Rule: decision.Rule, Message: decision.Message, LatencyUs: latencyUs,
-		})
-
-		// Update agent stats.
-		p.registry.RecordToolCall(route.AgentID, decision.Action == "block")
-
-		if decision.Action == "block" {
-			blocked = append(blocked, tc)
-			blockMessages = append(blockMessages, formatBlockNotice(tc.Name, decision.Rule, decision.Message))
+	canPatch := patchChecker(func(calls []extractor.ToolCall) []extractor.ToolCall {
+		_, unpatched := rewriteStreamArguments(events, route.APIType, calls)
+		return unpatched
+	})
+	blocked, blockedDecisions, rewritten := p.evaluateToolCalls(ctx, route, meta, msg.ToolCalls, runtime, canPatch)
+	if len(rewritten) > 0 {
+		var unpatched []extractor.ToolCall
+		events, unpatched = rewriteStreamArguments(events, route.APIType, rewritten)
+		blocked, blockedDecisions = blockUnpatched(unpatched, blocked, blockedDecisions)
+	}
 
-			slog.Warn("tool call blocked (streaming)",
-				"agent", route.AgentID,
-				"tool", tc.Name,
-				"rule", decision.Rule,
-			)
-		}
+	var blockMessages []string
+	for i, tc := range blocked {
+		d := blockedDecisions[i]
+		blockMessages = append(blockMessages, formatBlockNotice(tc.Name, d.Rule, d.Message))
 	}
 
 	// Prepare for SSE response to SDK.
@@ -412,6 +391,271 @@ func (p *Proxy) handleStreaming(w http.ResponseWriter, resp *http.Response, rout
 	}
 }
 
+// evaluateToolCalls evaluates each tool call against the rule engine, logs
+// every decision to the audit chain and dashboard feed, and updates agent
+// stats. Returns the tool calls that must be stripped from the response,
+// paired with their final decisions, and the tool calls whose arguments
+// must be replaced (redacted or rewritten), carrying the new arguments.
+//
+// Monitor-mode matches (Decision.WouldBlock) are audited and broadcast as
+// "would_block" but never affect the response or agent stats.
+//
+// Tool calls matched by an "ask" rule are submitted to the approval queue
+// and the response is held until an operator decides or the timeout
+// expires. Pending approvals are waited on concurrently, so several asks in
+// one response share a single timeout window. Without an approval queue,
+// "ask" fails closed to "block".
+//
+// A redacted or rewritten call that canPatch rejects can't have its new
+// arguments written into the response, so it is blocked — and audited,
+// counted and kept out of history as blocked. A nil canPatch accepts all.
+func (p *Proxy) evaluateToolCalls(ctx context.Context, route RouteInfo, meta extractor.RequestMeta, toolCalls []extractor.ToolCall, runtime *runtimeRules, canPatch func(extractor.ToolCall) bool) ([]extractor.ToolCall, []engine.Decision, []extractor.ToolCall) {
+	decisions := make([]engine.Decision, len(toolCalls))
+	approvalIDs := make([]string, len(toolCalls))
+	seqs := make([]uint64, len(toolCalls))
+	callMeta := engine.CallMeta{Provider: route.ProviderKey, Model: meta.Model, Tools: meta.ToolSchemas, Schemas: engine.NewSchemaCache()}
+
+	for i, tc := range toolCalls {
+		evalStart := time.Now()
+		decision := p.engine.EvaluateRequest(route.AgentID, callMeta, tc, runtime.list())
+		latencyUs := time.Since(evalStart).Microseconds()
+
+		// Monitor-mode matches are audited but never change the response.
+		for _, wb := range decision.WouldBlock {
+			wbEntry := audit.Entry{
+				Agent: route.AgentID, Provider: route.ProviderKey, Model: meta.Model,
+				Type: "tool_call", Tool: tc.Name, Decision: "would_block",
+				Rule: wb.Rule, Message: wb.Message, LatencyUs: latencyUs,
+				TaintSeq: wb.TaintSeq, RuleBundle: runtime.bundleID(),
+			}
+			if len(wb.Secrets) > 0 {
+				wbEntry.Secrets = wb.Secrets
+			}
+			logged := wbEntry
+			logged.Arguments = auditArgs(tc.Arguments, wb.Secrets, wb.PII)
+			p.auditLog.LogToolCallEntry(logged)
+			p.broadcastAuditEvent(wbEntry)
+			slog.Info("tool call would be blocked (monitor mode)",
+				"agent", route.AgentID,
+				"tool", tc.Name,
+				"rule", wb.Rule,
+			)
+		}
+
+		var source string
+		if decision.Action == "delegate" {
+			decision, source = p.delegateDecision(ctx, route, meta, tc, decision)
+		}
+
+		if decision.Rewrites() && canPatch != nil && !canPatch(tc) {
+			slog.Error("cannot replace tool call arguments in response, blocking",
+				"agent", route.AgentID, "tool", tc.Name, "rule", decision.Rule)
+			decision.Action = "block"
+			decision.Message = strings.TrimSpace(decision.Message + " (arguments could not be replaced in the response)")
+		}
+
+		if decision.Action == "ask" && p.approvals == nil {
+			slog.Warn("ask rule matched but no approval queue configured, blocking",
+				"agent", route.AgentID, "tool", tc.Name, "rule", decision.Rule)
+			decision.Action = "block"
+		}
+
+		entry := audit.Entry{
+			Agent: route.AgentID, Provider: route.ProviderKey, Model: meta.Model,
+			Type: "tool_call", Tool: tc.Name, Decision: decision.Action,
+			Rule: decision.Rule, Message: decision.Message, LatencyUs: latencyUs,
+			Default: decision.Default, CanonicalPath: decision.Path,
+			SequenceSeqs: decision.Sequence, TaintSeq: decision.TaintSeq,
+			RuleBundle: runtime.bundleID(), DecisionSource: source,
+		}
+		if len(decision.Secrets) > 0 {
+			entry.Secrets = decision.Secrets
+		}
+		if len(decision.PII) > 0 {
+			entry.PII = decision.PII
+		}
+
+		if decision.Action == "ask" {
+			req := p.approvals.Submit(approval.Request{
+				Agent:     route.AgentID,
+				Provider:  route.ProviderKey,
+				Model:     meta.Model,
+				Tool:      tc.Name,
+				Arguments: tc.Arguments,
+				Rule:      decision.Rule,
+				Message:   decision.Message,
+			})
+			approvalIDs[i] = req.ID
+			entry.ApprovalID = req.ID
+
+			slog.Info("tool call awaiting approval",
+				"agent", route.AgentID,
+				"tool", tc.Name,
+				"rule", decision.Rule,
+				"approval_id", req.ID,
+			)
+		}
+
+		// The audit chain gets the arguments, with any findings redacted
+		// (and, if enabled, the trace of why a call was blocked); the
+		// dashboard feed doesn't.
+		logged := entry
+		logged.Arguments = auditArgs(tc.Arguments, decision.Secrets, decision.PII)
+		if decision.Action == "block" && p.config != nil && p.config.Audit.ExplainBlocks {
+			logged.Explain = p.engine.ExplainRequest(route.AgentID, callMeta, tc, runtime.list())
+		}
+		if decision.Rewrites() {
+			logged.RewrittenArgs = decision.Args
+		}
+		seqs[i] = p.auditLog.LogToolCallEntry(logged)
+
+		// From here on the call is what the agent will actually run.
+		if decision.Rewrites() {
+			tc = withArguments(tc, decision.Args)
+			toolCalls[i] = tc
+		}
+
+		// Held calls are recorded once approved, below.
+		p.recordHistory(route.AgentID, tc, decision, seqs[i])
+
+		// Broadcast to dashboard WebSocket feed.
+		p.broadcastAuditEvent(entry)
+		decisions[i] = decision
+	}
+
+	// Hold the response until every pending approval is resolved.
+	var wg sync.WaitGroup
+	for i, id := range approvalIDs {
+		if id == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			out := p.approvals.Wait(ctx, id)
+			decisions[i] = p.resolveApproval(route, meta, toolCalls[i].Name, id, decisions[i], out)
+		}(i, id)
+	}
+	wg.Wait()
+
+	var blocked, rewritten []extractor.ToolCall
+	var blockedDecisions []engine.Decision
+
+	for i, tc := range toolCalls {
+		decision := decisions[i]
+		if approvalIDs[i] != "" {
+			p.recordHistory(route.AgentID, tc, decision, seqs[i])
+		}
+
+		// Update agent stats.
+		p.registry.RecordToolCall(route.AgentID, decision.Action == "block")
+
+		if decision.Rewrites() {
+			rewritten = append(rewritten, tc)
+			slog.Info("tool call arguments replaced",
+				"agent", route.AgentID,
+				"tool", tc.Name,
+				"action", decision.Action,
+				"rule", decision.Rule,
+			)
+		} else if decision.Action == "block" {
+			blocked = append(blocked, tc)
+			blockedDecisions = append(blockedDecisions, decision)
+
+			slog.Warn("tool call blocked",
+				"agent", route.AgentID,
+				"tool", tc.Name,
+				"rule", decision.Rule,
+				"message", decision.Message,
+			)
+		} else {
+			slog.Debug("tool call allowed",
+				"agent", route.AgentID,
+				"tool", tc.Name,
+			)
+		}
+	}
+
+	return blocked, blockedDecisions, rewritten
+}
+
+// auditArgs returns the arguments to write to the audit log: a copy with
+// every secret and PII finding redacted, so the log never holds what the
+// detectors found. Without findings the arguments are logged as they are.
+func auditArgs(args map[string]any, secrets, pii []engine.Finding) map[string]any {
+	if len(secrets) == 0 && len(pii) == 0 {
+		return args
+	}
+	return engine.RedactArgs(args, append(append([]engine.Finding(nil), secrets...), pii...))
+}
+
+// recordHistory feeds a call the agent will run into the engine's
+// per-agent state: it becomes history that sequence rules match against,
+// and if it was sensitive its result is fingerprinted from the next
+// request. Blocked and still-held calls are skipped.
+func (p *Proxy) recordHistory(agentID string, tc extractor.ToolCall, decision engine.Decision, seq uint64) {
+	if decision.Action != "allow" && !decision.Rewrites() {
+		return
+	}
+	p.engine.RecordCall(agentID, tc, seq)
+	if decision.Sensitive {
+		p.engine.MarkSensitive(agentID, tc, seq)
+	}
+}
+
+// withArguments returns tc with its arguments replaced, keeping its ID
+// and index so the response can be patched in place.
+func withArguments(tc extractor.ToolCall, args map[string]any) extractor.ToolCall {
+	tc.Arguments = args
+	if data, err := json.Marshal(args); err == nil {
+		tc.RawJSON = data
+	}
+	return tc
+}
+
+// resolveApproval records the outcome of an approval in the audit chain and
+// converts the held "ask" decision into a final allow/block decision.
+func (p *Proxy) resolveApproval(route RouteInfo, meta extractor.RequestMeta, tool, approvalID string, decision engine.Decision, out approval.Outcome) engine.Decision {
+	p.auditLog.LogApproval(
+		route.AgentID, route.ProviderKey, meta.Model,
+		tool, decision.Rule, approvalID,
+		out.Approved, out.Approver, out.Reason,
+	)
+
+	final := engine.Decision{
+		Action: "block", Rule: decision.Rule, Message: decision.Message,
+		Sensitive: decision.Sensitive, Path: decision.Path,
+	}
+	if out.Approved {
+		final.Action = "allow"
+	} else {
+		note := "approval " + out.Reason
+		if out.Reason == approval.ReasonDenied {
+			note = "denied by " + out.Approver
+		}
+		if final.Message == "" {
+			final.Message = "Approval required"
+		}
+		final.Message += " (" + note + ")"
+	}
+
+	p.broadcastAuditEvent(audit.Entry{
+		Agent: route.AgentID, Provider: route.ProviderKey, Model: meta.Model,
+		Type: "approval", Tool: tool, Decision: final.Action, Rule: decision.Rule,
+		Message: out.Reason, ApprovalID: approvalID, Approver: out.Approver,
+	})
+
+	slog.Info("approval resolved",
+		"agent", route.AgentID,
+		"tool", tool,
+		"approval_id", approvalID,
+		"approved", out.Approved,
+		"approver", out.Approver,
+		"reason", out.Reason,
+	)
+	return final
+}
+
 // respondKilled sends a fake LLM response for a killed agent.
 // The response looks like a normal "end_turn" so the SDK stops gracefully.
 // If the request asked for streaming (stream: true), we return a proper SSE
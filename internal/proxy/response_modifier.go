@@ -304,6 +304,153 @@ func modifyOpenAIResponsesResponse(body []byte, blocked []extractor.ToolCall, de
 	return modified
 }
 
+// rewriteToolArguments replaces the arguments of the rewritten tool calls
+// (matched by ID) in a non-streaming response body. Everything else,
+// including the calls' IDs and order, is left as it was.
+//
+// Also returns the rewritten calls it couldn't patch (body didn't parse,
+// or no call with that ID); the caller must strip them instead.
+func rewriteToolArguments(body []byte, apiType extractor.APIType, rewritten []extractor.ToolCall) ([]byte, []extractor.ToolCall) {
+	args := make(map[string]json.RawMessage, len(rewritten))
+	for _, tc := range rewritten {
+		args[tc.ID] = tc.RawJSON
+	}
+	patched := make(map[string]bool, len(rewritten))
+	unpatched := func() []extractor.ToolCall {
+		return unpatchedCalls(rewritten, func(tc extractor.ToolCall) bool { return patched[tc.ID] })
+	}
+
+	var resp map[string]json.RawMessage
+	if err := json.Unmarshal(body, &resp); err != nil {
+		slog.Error("failed to parse response for argument rewrite", "error", err)
+		return body, rewritten
+	}
+
+	switch apiType {
+	case extractor.APITypeAnthropic:
+		// content[]: {"type":"tool_use","id":...,"input":{...}}
+		var content []map[string]json.RawMessage
+		if err := json.Unmarshal(resp["content"], &content); err != nil {
+			return body, rewritten
+		}
+		for _, block := range content {
+			if unquoteRaw(block["type"]) != "tool_use" {
+				continue
+			}
+			id := unquoteRaw(block["id"])
+			if a, ok := args[id]; ok {
+				block["input"] = a
+				patched[id] = true
+			}
+		}
+		resp["content"] = safeMarshalRaw(content)
+
+	case extractor.APITypeOpenAI:
+		// choices[0].message.tool_calls[]: {"id":...,"function":{"arguments":"..."}}
+		var choices []map[string]json.RawMessage
+		if err := json.Unmarshal(resp["choices"], &choices); err != nil || len(choices) == 0 {
+			return body, rewritten
+		}
+		var message map[string]json.RawMessage
+		if err := json.Unmarshal(choices[0]["message"], &message); err != nil {
+			return body, rewritten
+		}
+		var toolCalls []map[string]json.RawMessage
+		if err := json.Unmarshal(message["tool_calls"], &toolCalls); err != nil {
+			return body, rewritten
+		}
+		for _, call := range toolCalls {
+			id := unquoteRaw(call["id"])
+			a, ok := args[id]
+			if !ok {
+				continue
+			}
+			var fn map[string]json.RawMessage
+			if err := json.Unmarshal(call["function"], &fn); err != nil {
+				continue
+			}
+			fn["arguments"] = safeMarshalRaw(string(a))
+			call["function"] = safeMarshalRaw(fn)
+			patched[id] = true
+		}
+		message["tool_calls"] = safeMarshalRaw(toolCalls)
+		choices[0]["message"] = safeMarshalRaw(message)
+		resp["choices"] = safeMarshalRaw(choices)
+
+	case extractor.APITypeOpenAIResponses:
+		// output[]: {"type":"function_call","call_id":...,"arguments":"..."}
+		var output []map[string]json.RawMessage
+		if err := json.Unmarshal(resp["output"], &output); err != nil {
+			return body, rewritten
+		}
+		for _, item := range output {
+			if unquoteRaw(item["type"]) != "function_call" {
+				continue
+			}
+			callID := unquoteRaw(item["call_id"])
+			if callID == "" {
+				callID = unquoteRaw(item["id"])
+			}
+			if a, ok := args[callID]; ok {
+				item["arguments"] = safeMarshalRaw(string(a))
+				patched[callID] = true
+			}
+		}
+		resp["output"] = safeMarshalRaw(output)
+
+	default:
+		return body, rewritten
+	}
+
+	modified, err := json.Marshal(resp)
+	if err != nil {
+		slog.Error("failed to marshal rewritten response", "error", err)
+		return body, rewritten
+	}
+	return modified, unpatched()
+}
+
+// unpatchedCalls returns the rewritten calls for which patched is false.
+func unpatchedCalls(rewritten []extractor.ToolCall, patched func(extractor.ToolCall) bool) []extractor.ToolCall {
+	var out []extractor.ToolCall
+	for _, tc := range rewritten {
+		if !patched(tc) {
+			out = append(out, tc)
+		}
+	}
+	return out
+}
+
+// patchChecker returns a check of whether a call's arguments can be
+// replaced in the response: a dry run of rewrite, which returns the calls
+// it couldn't patch, with placeholder arguments. Only asked about redacted
+// and rewritten calls, so the response is only re-parsed for them. Lets
+// the proxy block an unpatchable call before it is audited.
+func patchChecker(rewrite func([]extractor.ToolCall) []extractor.ToolCall) func(extractor.ToolCall) bool {
+	return func(tc extractor.ToolCall) bool {
+		tc.RawJSON = json.RawMessage(`{}`)
+		return len(rewrite([]extractor.ToolCall{tc})) == 0
+	}
+}
+
+// blockUnpatched moves the rewritten calls whose new arguments couldn't be
+// written into the response to the blocked list. Letting them through
+// would run them with the arguments the rule meant to replace. patchChecker
+// already blocked such calls before auditing them, so this only guards
+// against the real rewrite failing where the dry run succeeded.
+func blockUnpatched(unpatched, blocked []extractor.ToolCall, decisions []engine.Decision) ([]extractor.ToolCall, []engine.Decision) {
+	for _, tc := range unpatched {
+		slog.Error("could not replace tool call arguments in response, blocking",
+			"tool", tc.Name, "id", tc.ID, "index", tc.Index)
+		blocked = append(blocked, tc)
+		decisions = append(decisions, engine.Decision{
+			Action:  "block",
+			Message: "Tool call arguments could not be replaced in the response",
+		})
+	}
+	return blocked, decisions
+}
+
 // formatBlockNotice creates the user-visible block notice.
 // Format: [CtrlAI] Blocked: <message> (rule: <rule_name>)
 func formatBlockNotice(toolName, ruleName, message string) string {
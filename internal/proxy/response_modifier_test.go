@@ -426,3 +426,100 @@ func TestFormatBlockNotice(t *testing.T) {
 		})
 	}
 }
+
+// --- rewriteToolArguments ---
+
+func TestRewriteToolArguments(t *testing.T) {
+	redacted := json.RawMessage(`{"to":"[REDACTED:email]"}`)
+	tests := []struct {
+		name    string
+		apiType extractor.APIType
+		body    string
+	}{
+		{
+			name:    "anthropic",
+			apiType: extractor.APITypeAnthropic,
+			body: `{"content":[
+				{"type":"text","text":"Sending."},
+				{"type":"tool_use","id":"call_1","name":"message","input":{"to":"bob@example.com"}},
+				{"type":"tool_use","id":"call_2","name":"exec","input":{"command":"ls"}}
+			],"stop_reason":"tool_use"}`,
+		},
+		{
+			name:    "openai",
+			apiType: extractor.APITypeOpenAI,
+			body: `{"choices":[{"message":{"role":"assistant","tool_calls":[
+				{"id":"call_1","type":"function","function":{"name":"message","arguments":"{\"to\":\"bob@example.com\"}"}},
+				{"id":"call_2","type":"function","function":{"name":"exec","arguments":"{\"command\":\"ls\"}"}}
+			]},"finish_reason":"tool_calls"}]}`,
+		},
+		{
+			name:    "openai responses",
+			apiType: extractor.APITypeOpenAIResponses,
+			body: `{"output":[
+				{"type":"function_call","call_id":"call_1","name":"message","arguments":"{\"to\":\"bob@example.com\"}"},
+				{"type":"function_call","call_id":"call_2","name":"exec","arguments":"{\"command\":\"ls\"}"}
+			],"status":"completed"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rewritten := []extractor.ToolCall{{ID: "call_1", Name: "message", RawJSON: redacted}}
+			modified, unpatched := rewriteToolArguments([]byte(tt.body), tt.apiType, rewritten)
+			if len(unpatched) != 0 {
+				t.Errorf("expected every call patched, got unpatched %+v", unpatched)
+			}
+
+			calls := extractor.Extract(modified, tt.apiType)
+			if len(calls) != 2 {
+				t.Fatalf("expected 2 tool calls, got %d", len(calls))
+			}
+			if calls[0].ID != "call_1" || calls[0].Arguments["to"] != "[REDACTED:email]" {
+				t.Errorf("call_1 not rewritten: %+v", calls[0])
+			}
+			if calls[1].ID != "call_2" || calls[1].Arguments["command"] != "ls" {
+				t.Errorf("call_2 should be unchanged: %+v", calls[1])
+			}
+		})
+	}
+}
+
+func TestRewriteToolArguments_InvalidJSON(t *testing.T) {
+	body := []byte(`not json`)
+	rewritten := []extractor.ToolCall{{ID: "call_1", RawJSON: json.RawMessage(`{}`)}}
+	got, unpatched := rewriteToolArguments(body, extractor.APITypeAnthropic, rewritten)
+	if string(got) != string(body) {
+		t.Errorf("invalid body should pass through unchanged, got %s", got)
+	}
+	if len(unpatched) != 1 {
+		t.Errorf("a body that didn't parse should report the call unpatched, got %+v", unpatched)
+	}
+}
+
+func TestRewriteToolArguments_FailsClosed(t *testing.T) {
+	// call_2's ID isn't in the response: it can't be patched, so it is
+	// moved to the blocked list and stripped with the real blocks.
+	body := []byte(`{"content":[
+		{"type":"tool_use","id":"call_1","name":"message","input":{"to":"bob@example.com"}},
+		{"type":"tool_use","id":"call_3","name":"exec","input":{"command":"cat .env"}}
+	],"stop_reason":"tool_use"}`)
+	rewritten := []extractor.ToolCall{
+		{ID: "call_1", Name: "message", RawJSON: json.RawMessage(`{"to":"[REDACTED:email]"}`)},
+		{ID: "call_2", Name: "message", RawJSON: json.RawMessage(`{"to":"[REDACTED:email]"}`)},
+	}
+	body, unpatched := rewriteToolArguments(body, extractor.APITypeAnthropic, rewritten)
+	if len(unpatched) != 1 || unpatched[0].ID != "call_2" {
+		t.Fatalf("expected call_2 unpatched, got %+v", unpatched)
+	}
+
+	blocked := []extractor.ToolCall{{ID: "call_3", Name: "exec"}}
+	decisions := []engine.Decision{{Action: "block", Rule: "no-env"}}
+	blocked, decisions = blockUnpatched(unpatched, blocked, decisions)
+	if len(blocked) != 2 || blocked[1].ID != "call_2" || decisions[1].Action != "block" {
+		t.Errorf("unpatched call not moved to the blocked list: %+v %+v", blocked, decisions)
+	}
+	if calls := extractor.Extract(body, extractor.APITypeAnthropic); len(calls) != 2 || calls[0].Arguments["to"] != "[REDACTED:email]" {
+		t.Errorf("patched call lost its new arguments: %s", body)
+	}
+}
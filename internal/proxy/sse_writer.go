@@ -282,6 +282,164 @@ func buildModifiedOpenAIStream(events []SSEEvent, blocked []extractor.ToolCall,
 	return modified
 }
 
+// rewriteStreamArguments replaces the arguments of the rewritten tool calls
+// in a buffered SSE stream. Each call's argument fragments are collapsed
+// into a single fragment carrying the new arguments; block indexes, call
+// IDs and every other event are left as they were.
+//
+// Also returns the rewritten calls it found no events for; the caller
+// must strip them instead.
+func rewriteStreamArguments(events []SSEEvent, apiType extractor.APIType, rewritten []extractor.ToolCall) ([]SSEEvent, []extractor.ToolCall) {
+	switch apiType {
+	case extractor.APITypeAnthropic:
+		return rewriteAnthropicStreamArguments(events, rewritten)
+	case extractor.APITypeOpenAI:
+		return rewriteOpenAIStreamArguments(events, rewritten)
+	case extractor.APITypeOpenAIResponses:
+		return rewriteOpenAIResponsesStreamArguments(events, rewritten)
+	default:
+		return events, rewritten
+	}
+}
+
+// rewriteAnthropicStreamArguments replaces the input_json_delta events of
+// the rewritten tool_use blocks (matched by block index). The first delta
+// carries the whole new input and later ones are dropped; a block that had
+// no deltas gets one before its content_block_stop.
+func rewriteAnthropicStreamArguments(events []SSEEvent, rewritten []extractor.ToolCall) ([]SSEEvent, []extractor.ToolCall) {
+	args := make(map[int]string, len(rewritten))
+	for _, tc := range rewritten {
+		args[tc.Index] = string(tc.RawJSON)
+	}
+	sent := make(map[int]bool)
+
+	var modified []SSEEvent
+	for _, evt := range events {
+		if evt.Event != "content_block_delta" && evt.Event != "content_block_stop" {
+			modified = append(modified, evt)
+			continue
+		}
+		var block struct {
+			Index int `json:"index"`
+			Delta struct {
+				Type string `json:"type"`
+			} `json:"delta"`
+		}
+		if err := json.Unmarshal([]byte(evt.Data), &block); err != nil {
+			modified = append(modified, evt)
+			continue
+		}
+		a, ok := args[block.Index]
+		if !ok {
+			modified = append(modified, evt)
+			continue
+		}
+
+		if evt.Event == "content_block_stop" {
+			if !sent[block.Index] {
+				sent[block.Index] = true
+				modified = append(modified, buildInputJSONDelta(block.Index, a))
+			}
+			modified = append(modified, evt)
+			continue
+		}
+		if block.Delta.Type != "input_json_delta" {
+			modified = append(modified, evt)
+			continue
+		}
+		if !sent[block.Index] {
+			sent[block.Index] = true
+			modified = append(modified, buildInputJSONDelta(block.Index, a))
+		}
+	}
+	return modified, unpatchedCalls(rewritten, func(tc extractor.ToolCall) bool { return sent[tc.Index] })
+}
+
+// buildInputJSONDelta generates an Anthropic input_json_delta event.
+func buildInputJSONDelta(index int, partialJSON string) SSEEvent {
+	data, _ := json.Marshal(map[string]any{
+		"type":  "content_block_delta",
+		"index": index,
+		"delta": map[string]any{"type": "input_json_delta", "partial_json": partialJSON},
+	})
+	return SSEEvent{Event: "content_block_delta", Data: string(data)}
+}
+
+// rewriteOpenAIStreamArguments replaces the argument fragments of the
+// rewritten tool calls (matched by tool_calls[].index). The first fragment
+// carries the whole new arguments string and later ones are emptied.
+func rewriteOpenAIStreamArguments(events []SSEEvent, rewritten []extractor.ToolCall) ([]SSEEvent, []extractor.ToolCall) {
+	args := make(map[int]string, len(rewritten))
+	for _, tc := range rewritten {
+		args[tc.Index] = string(tc.RawJSON)
+	}
+	sent := make(map[int]bool)
+	failed := make(map[int]bool) // A fragment of the call went out unchanged.
+
+	modified := make([]SSEEvent, 0, len(events))
+	for _, evt := range events {
+		if evt.Data == "" || evt.Data == "[DONE]" {
+			modified = append(modified, evt)
+			continue
+		}
+
+		var chunk map[string]json.RawMessage
+		var choices []map[string]json.RawMessage
+		var delta map[string]json.RawMessage
+		var toolCalls []map[string]json.RawMessage
+		if json.Unmarshal([]byte(evt.Data), &chunk) != nil ||
+			json.Unmarshal(chunk["choices"], &choices) != nil || len(choices) == 0 ||
+			json.Unmarshal(choices[0]["delta"], &delta) != nil ||
+			json.Unmarshal(delta["tool_calls"], &toolCalls) != nil {
+			modified = append(modified, evt)
+			continue
+		}
+
+		var changed []int
+		for _, tc := range toolCalls {
+			var idx int
+			json.Unmarshal(tc["index"], &idx)
+			a, ok := args[idx]
+			if !ok {
+				continue
+			}
+			var fn map[string]json.RawMessage
+			if err := json.Unmarshal(tc["function"], &fn); err != nil {
+				continue
+			}
+			if _, has := fn["arguments"]; !has && sent[idx] {
+				continue
+			}
+			if sent[idx] {
+				fn["arguments"] = json.RawMessage(`""`)
+			} else {
+				sent[idx] = true
+				fn["arguments"] = safeMarshalRaw(a)
+			}
+			tc["function"] = safeMarshalRaw(fn)
+			changed = append(changed, idx)
+		}
+		if len(changed) == 0 {
+			modified = append(modified, evt)
+			continue
+		}
+
+		delta["tool_calls"] = safeMarshalRaw(toolCalls)
+		choices[0]["delta"] = safeMarshalRaw(delta)
+		chunk["choices"] = safeMarshalRaw(choices)
+		data, err := json.Marshal(chunk)
+		if err != nil {
+			for _, idx := range changed {
+				failed[idx] = true
+			}
+			modified = append(modified, evt)
+			continue
+		}
+		modified = append(modified, SSEEvent{Event: evt.Event, Data: string(data)})
+	}
+	return modified, unpatchedCalls(rewritten, func(tc extractor.ToolCall) bool { return sent[tc.Index] && !failed[tc.Index] })
+}
+
 // reindexEvent creates a new SSE event with the index field remapped.
 func reindexEvent(evt SSEEvent, oldIndex int, indexMap map[int]int) SSEEvent {
 	newIdx, ok := indexMap[oldIndex]
@@ -470,3 +470,89 @@ func TestBuildBlockNoticeText(t *testing.T) {
 		t.Error("multi message should not be empty")
 	}
 }
+
+// --- rewriteStreamArguments ---
+
+func TestRewriteStreamArguments(t *testing.T) {
+	redacted := json.RawMessage(`{"command":"[REDACTED:email]"}`)
+	tests := []struct {
+		name    string
+		apiType extractor.APIType
+		events  []SSEEvent
+		call    extractor.ToolCall
+	}{
+		{"anthropic", extractor.APITypeAnthropic, anthropicTestEvents(), extractor.ToolCall{ID: "toolu_01", Index: 1, RawJSON: redacted}},
+		{"openai", extractor.APITypeOpenAI, openaiTestEvents(), extractor.ToolCall{ID: "call_1", Index: 0, RawJSON: redacted}},
+		{"openai responses", extractor.APITypeOpenAIResponses, responsesTestEvents(), extractor.ToolCall{ID: "call_bad", Index: 0, RawJSON: redacted}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			modified, unpatched := rewriteStreamArguments(tt.events, tt.apiType, []extractor.ToolCall{tt.call})
+			if len(unpatched) != 0 {
+				t.Errorf("expected every call patched, got unpatched %+v", unpatched)
+			}
+
+			msg := reconstruct(modified, tt.apiType)
+			if len(msg.ToolCalls) != 1 {
+				t.Fatalf("expected 1 tool call, got %d", len(msg.ToolCalls))
+			}
+			tc := msg.ToolCalls[0]
+			if tc.ID != tt.call.ID {
+				t.Errorf("ID: expected %q, got %q", tt.call.ID, tc.ID)
+			}
+			if string(tc.RawJSON) != string(redacted) {
+				t.Errorf("arguments: expected %s, got %s", redacted, tc.RawJSON)
+			}
+		})
+	}
+}
+
+func TestRewriteStreamArguments_ReportsUnpatched(t *testing.T) {
+	args := json.RawMessage(`{"command":"ls"}`)
+	tests := []struct {
+		name    string
+		apiType extractor.APIType
+		events  []SSEEvent
+		call    extractor.ToolCall
+	}{
+		{"anthropic unknown index", extractor.APITypeAnthropic, anthropicTestEvents(), extractor.ToolCall{ID: "toolu_09", Index: 9, RawJSON: args}},
+		{"openai unknown index", extractor.APITypeOpenAI, openaiTestEvents(), extractor.ToolCall{ID: "call_9", Index: 9, RawJSON: args}},
+		{"openai responses unknown call_id", extractor.APITypeOpenAIResponses, responsesTestEvents(), extractor.ToolCall{ID: "call_missing", RawJSON: args}},
+		{"unknown api", extractor.APITypeUnknown, anthropicTestEvents(), extractor.ToolCall{ID: "toolu_01", Index: 1, RawJSON: args}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, unpatched := rewriteStreamArguments(tt.events, tt.apiType, []extractor.ToolCall{tt.call})
+			if len(unpatched) != 1 || unpatched[0].ID != tt.call.ID {
+				t.Errorf("expected %s reported unpatched, got %+v", tt.call.ID, unpatched)
+			}
+		})
+	}
+}
+
+func TestRewriteAnthropicStreamArguments_NoDeltas(t *testing.T) {
+	// A tool_use block with no input_json_delta events gets one.
+	events := []SSEEvent{
+		{Event: "content_block_start", Data: `{"type":"content_block_start","index":0,"content_block":{"type":"tool_use","id":"toolu_01","name":"exec"}}`},
+		{Event: "content_block_stop", Data: `{"type":"content_block_stop","index":0}`},
+		{Event: "message_stop", Data: `{"type":"message_stop"}`},
+	}
+	rewritten := []extractor.ToolCall{{ID: "toolu_01", Index: 0, RawJSON: json.RawMessage(`{"a":"b"}`)}}
+
+	modified, unpatched := rewriteStreamArguments(events, extractor.APITypeAnthropic, rewritten)
+	if len(unpatched) != 0 {
+		t.Errorf("injected delta should count as patched, got unpatched %+v", unpatched)
+	}
+	if len(modified) != 4 {
+		t.Fatalf("expected 4 events, got %d", len(modified))
+	}
+	if modified[1].Event != "content_block_delta" {
+		t.Errorf("expected injected delta before content_block_stop, got %q", modified[1].Event)
+	}
+	msg := reconstruct(modified, extractor.APITypeAnthropic)
+	if len(msg.ToolCalls) != 1 || msg.ToolCalls[0].Arguments["a"] != "b" {
+		t.Errorf("unexpected reconstructed calls: %+v", msg.ToolCalls)
+	}
+}